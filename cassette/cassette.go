@@ -0,0 +1,313 @@
+// Package cassette 는 모델 호출을 카세트 파일에 녹화하고 재생하는 전송 계층.
+//
+// 녹화 모드에서는 실제 Vertex AI 로 가는 요청과 응답 쌍을 그대로 기록하고,
+// 재생 모드에서는 네트워크 없이 기록된 응답을 돌려준다. 재생 중 요청이 기록과
+// 다르면 차이를 담은 *MismatchError 로 실패하므로, 프롬프트가 바뀐 것을 테스트가 잡아낸다.
+//
+// gRPC(cloud.google.com/go/vertexai/genai 의 GenerateContent·SendMessage,
+// aiplatform.PredictionClient 의 Predict)는 ClientOptions 로,
+// REST(google.golang.org/genai 의 GenerateContent·GenerateImages)는 GenAIConfig 로 연결한다.
+//
+//	rec := cassette.Start(t, "function_call") // testdata/function_call.json
+//	client, err := genai.NewClient(ctx, projectID, location, rec.ClientOptions()...)
+//
+// 카세트를 실제 API 로 다시 녹화하려면 자격 증명을 준비하고 VERTEX_CASSETTE=record 로 테스트를 실행한다.
+//
+//	gcloud auth application-default login
+//	VERTEX_CASSETTE=record go test ./function_call ./image2text ./parallel ./rag
+//
+// 요청·응답 헤더(Authorization, x-goog-api-key 등)는 기록하지 않으며, REST URL 의 key·access_token
+// 인자는 지우고 기록한다. 프로젝트 ID 와 프롬프트는 요청에 그대로 남으므로 커밋하기 전에 카세트를
+// 훑어본다. 녹화 모드는 응답을 바꾸지 않으므로 실제 모델의 답이 테스트의 기대와 다르면 테스트가 실패한다.
+//
+// VERTEX_CASSETTE=fake 이면 테스트가 연결한 가짜 서버(vertextest)의 스크립트 응답을 기록한다.
+// 이렇게 만든 카세트는 Source 가 "vertextest" 이며 요청(프롬프트·도구 선언)이 바뀐 것만 잡아낼 뿐,
+// 실제 모델이 어떻게 답하는지는 보여 주지 않는다. 카세트의 응답은 손으로 고치지 말고 다시 기록한다.
+package cassette
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// EnvMode 는 녹화 여부를 정하는 환경 변수.
+const EnvMode = "VERTEX_CASSETTE"
+
+// Mode 는 Recorder 의 동작 방식.
+type Mode int
+
+const (
+	// Replay 는 기록된 응답을 돌려주고 실제 서버에는 접속하지 않는다.
+	Replay Mode = iota
+	// Record 는 실제 서버를 호출하고 요청과 응답을 기록한다.
+	Record
+	// Fake 는 Record 처럼 기록하지만, 테스트가 vertextest 가짜 서버를 연결해 스크립트 응답을 받는다.
+	Fake
+)
+
+// 카세트의 Source 값
+const (
+	SourceVertex = "vertex"
+	SourceFake   = "vertextest"
+)
+
+func (m Mode) String() string {
+	switch m {
+	case Record:
+		return "record"
+	case Fake:
+		return "fake"
+	}
+	return "replay"
+}
+
+// ModeFromEnv 는 VERTEX_CASSETTE 가 record 이면 Record, fake 이면 Fake, 그 밖에는 Replay.
+func ModeFromEnv() Mode {
+	switch strings.ToLower(os.Getenv(EnvMode)) {
+	case "record":
+		return Record
+	case "fake":
+		return Fake
+	}
+	return Replay
+}
+
+// Interaction 은 요청 하나와 그 결과.
+type Interaction struct {
+	// Method 는 gRPC 메서드 전체 이름, 또는 REST 의 "POST /v1beta1/...:generateContent".
+	Method  string          `json:"method"`
+	Request json.RawMessage `json:"request,omitempty"`
+	// Response 는 응답 본문. ContentType 이 JSON 이 아니면 본문을 담은 JSON 문자열.
+	Response    json.RawMessage `json:"response,omitempty"`
+	ContentType string          `json:"contentType,omitempty"`
+	// StatusCode 는 REST 응답의 HTTP 상태 코드.
+	StatusCode int `json:"statusCode,omitempty"`
+	// Error 는 gRPC 호출이 실패했을 때의 상태.
+	Error *Status `json:"error,omitempty"`
+}
+
+// Status 는 실패한 gRPC 호출의 상태 코드와 메시지.
+type Status struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// Cassette 는 카세트 파일의 내용.
+type Cassette struct {
+	// Source 는 응답을 받은 곳. 실제 API 면 SourceVertex, 가짜 서버면 SourceFake.
+	Source       string        `json:"source,omitempty"`
+	Interactions []Interaction `json:"interactions"`
+}
+
+// Load 는 path 의 카세트를 읽는다.
+func Load(path string) (*Cassette, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var c Cassette
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("cassette: parse %s: %w", path, err)
+	}
+	// 파일 안에서는 들여쓰기가 달라지므로 비교할 수 있게 다시 정규화한다
+	for i, in := range c.Interactions {
+		req, err := canonical(in.Request)
+		if err != nil {
+			return nil, fmt.Errorf("cassette: parse %s: interaction %d: %w", path, i, err)
+		}
+		c.Interactions[i].Request = req
+	}
+	return &c, nil
+}
+
+// Save 는 카세트를 path 에 쓴다. 디렉터리가 없으면 만든다.
+func (c *Cassette) Save(path string) error {
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o644)
+}
+
+// Recorder 는 카세트 하나를 녹화하거나 재생한다. 여러 고루틴에서 함께 써도 된다.
+type Recorder struct {
+	mode Mode
+	path string
+
+	mu       sync.Mutex
+	cassette *Cassette
+	used     []bool
+}
+
+// New 는 path 의 카세트에 대한 Recorder 를 만든다.
+// Replay 모드에서는 카세트 파일이 있어야 하고, Record·Fake 모드에서는 Close 할 때 파일을 새로 쓴다.
+func New(path string, mode Mode) (*Recorder, error) {
+	r := &Recorder{mode: mode, path: path, cassette: &Cassette{Source: SourceVertex}}
+	switch mode {
+	case Fake:
+		r.cassette.Source = SourceFake
+	case Replay:
+		c, err := Load(path)
+		if err != nil {
+			return nil, fmt.Errorf("cassette: %w (record it with %s=record)", err, EnvMode)
+		}
+		r.cassette = c
+		r.used = make([]bool, len(c.Interactions))
+	}
+	return r, nil
+}
+
+// Start 는 testdata/<name>.json 카세트로 ModeFromEnv 모드의 Recorder 를 연다.
+// 테스트가 끝나면 녹화 내용을 저장하고, 재생 모드에서 쓰이지 않은 기록이 남았으면 테스트를 실패시킨다.
+func Start(t testing.TB, name string) *Recorder {
+	t.Helper()
+	r, err := New(filepath.Join("testdata", name+".json"), ModeFromEnv())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if err := r.Close(); err != nil {
+			t.Error(err)
+		}
+		if unused := r.Unused(); len(unused) > 0 && !t.Failed() {
+			t.Errorf("cassette %s: %d recorded interactions were not replayed, first %s", r.path, len(unused), unused[0].Method)
+		}
+	})
+	return r
+}
+
+// Mode 는 Recorder 의 모드.
+func (r *Recorder) Mode() Mode { return r.mode }
+
+// Close 는 Record·Fake 모드이면 카세트를 저장한다.
+func (r *Recorder) Close() error {
+	if r.mode == Replay {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.cassette.Save(r.path)
+}
+
+// Unused 는 재생 모드에서 아직 요청되지 않은 기록들.
+func (r *Recorder) Unused() []Interaction {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var out []Interaction
+	for i, u := range r.used {
+		if !u {
+			out = append(out, r.cassette.Interactions[i])
+		}
+	}
+	return out
+}
+
+func (r *Recorder) record(in Interaction) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.cassette.Interactions = append(r.cassette.Interactions, in)
+}
+
+// match 는 method 와 요청이 같은 첫 번째 미사용 기록을 찾는다.
+// 병렬 호출처럼 순서가 바뀌어도 재생되도록 순서가 아니라 내용으로 맞춘다.
+func (r *Recorder) match(method string, req json.RawMessage) (Interaction, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	candidate := -1
+	for i, in := range r.cassette.Interactions {
+		if r.used[i] || in.Method != method {
+			continue
+		}
+		if bytes.Equal(in.Request, req) {
+			r.used[i] = true
+			return in, nil
+		}
+		if candidate < 0 {
+			candidate = i
+		}
+	}
+	merr := &MismatchError{Method: method}
+	if candidate >= 0 {
+		merr.Diff = diffLines(string(r.cassette.Interactions[candidate].Request), string(req))
+	}
+	return Interaction{}, merr
+}
+
+// ErrMismatch 는 재생 중 요청에 맞는 기록이 없을 때의 오류. errors.Is 로 확인한다.
+var ErrMismatch = errors.New("cassette: request does not match recording")
+
+// MismatchError 는 어떤 요청이 기록과 달랐는지 알려준다.
+type MismatchError struct {
+	Method string
+	// Diff 는 같은 메서드의 가장 가까운 미사용 기록과의 차이. 남은 기록이 없으면 빈 문자열.
+	Diff string
+}
+
+func (e *MismatchError) Error() string {
+	if e.Diff == "" {
+		return fmt.Sprintf("cassette: no recorded %s interaction left", e.Method)
+	}
+	return fmt.Sprintf("cassette: %s request differs from recording (-recorded +actual):\n%s", e.Method, e.Diff)
+}
+
+func (e *MismatchError) Is(target error) bool { return target == ErrMismatch }
+
+// canonical 은 JSON 을 키 정렬·들여쓰기된 형태로 바꾼다.
+// protojson 출력은 일부러 공백이 흔들리므로 비교 전에 항상 거친다.
+func canonical(data []byte) (json.RawMessage, error) {
+	if len(bytes.TrimSpace(data)) == 0 {
+		return nil, nil
+	}
+	var v any
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	return json.MarshalIndent(v, "", "  ")
+}
+
+// diffLines 는 두 텍스트의 줄 단위 차이를 "-"/"+" 접두어로 보여준다.
+func diffLines(a, b string) string {
+	x, y := strings.Split(a, "\n"), strings.Split(b, "\n")
+	// 최장 공통 부분열 표
+	lcs := make([][]int, len(x)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(y)+1)
+	}
+	for i := len(x) - 1; i >= 0; i-- {
+		for j := len(y) - 1; j >= 0; j-- {
+			if x[i] == y[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+	var sb strings.Builder
+	i, j := 0, 0
+	for i < len(x) || j < len(y) {
+		switch {
+		case i < len(x) && j < len(y) && x[i] == y[j]:
+			i++
+			j++
+		case j < len(y) && (i == len(x) || lcs[i][j+1] >= lcs[i+1][j]):
+			fmt.Fprintf(&sb, "+ %s\n", y[j])
+			j++
+		default:
+			fmt.Fprintf(&sb, "- %s\n", x[i])
+			i++
+		}
+	}
+	return sb.String()
+}
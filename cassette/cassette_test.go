@@ -0,0 +1,252 @@
+package cassette
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	aiplatform "cloud.google.com/go/aiplatform/apiv1"
+	"cloud.google.com/go/aiplatform/apiv1/aiplatformpb"
+	vertexai "cloud.google.com/go/vertexai/genai"
+	"google.golang.org/api/option"
+	"google.golang.org/genai"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/protobuf/types/known/structpb"
+
+	"vertex/vertextest"
+)
+
+// exercise 는 녹화와 재생에서 똑같이 실행할 gRPC 호출들.
+// 생성 응답 텍스트, 두 번째 SendMessage 의 오류, 임베딩 차원을 돌려준다.
+func exercise(t *testing.T, ctx context.Context, opts []option.ClientOption) (text string, sendErr error, dim int) {
+	t.Helper()
+	client, err := vertexai.NewClient(ctx, "test-project", "us-central1", opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	model := client.GenerativeModel("gemini-2.0-flash")
+	resp, err := model.GenerateContent(ctx, vertexai.Text("hello"))
+	if err != nil {
+		t.Fatalf("GenerateContent() error = %v", err)
+	}
+	text = string(resp.Candidates[0].Content.Parts[0].(vertexai.Text))
+	_, sendErr = model.StartChat().SendMessage(ctx, vertexai.Text("again"))
+
+	pc, err := aiplatform.NewPredictionClient(ctx, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pc.Close()
+	presp, err := pc.Predict(ctx, &aiplatformpb.PredictRequest{
+		Endpoint: "projects/test-project/locations/us-central1/publishers/google/models/text-multilingual-embedding-002",
+		Instances: []*structpb.Value{structpb.NewStructValue(&structpb.Struct{Fields: map[string]*structpb.Value{
+			"content": structpb.NewStringValue("문서"),
+		}})},
+		Parameters: structpb.NewStructValue(&structpb.Struct{Fields: map[string]*structpb.Value{
+			"outputDimensionality": structpb.NewNumberValue(4),
+		}}),
+	})
+	if err != nil {
+		t.Fatalf("Predict() error = %v", err)
+	}
+	values := presp.Predictions[0].GetStructValue().GetFields()["embeddings"].GetStructValue().GetFields()["values"]
+	return text, sendErr, len(values.GetListValue().GetValues())
+}
+
+func TestGRPCRecordReplay(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "grpc.json")
+
+	// 가짜 서버를 상대로 녹화
+	srv, err := vertextest.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Close()
+	srv.AddText("첫 번째 답")
+	srv.AddError(codes.ResourceExhausted, "quota exceeded")
+
+	rec, err := New(path, Fake)
+	if err != nil {
+		t.Fatal(err)
+	}
+	text, sendErr, dim := exercise(t, ctx, append(rec.ClientOptions(), srv.ClientOptions()...))
+	if text != "첫 번째 답" || sendErr == nil || dim != 4 {
+		t.Fatalf("recording: text=%q err=%v dim=%d", text, sendErr, dim)
+	}
+	if err := rec.Close(); err != nil {
+		t.Fatal(err)
+	}
+	srv.Close()
+	// 가짜 서버에서 기록한 카세트는 출처로 구별된다
+	if c, err := Load(path); err != nil || c.Source != SourceFake {
+		t.Fatalf("Load() = %+v, %v, want source %q", c, err, SourceFake)
+	}
+
+	// 서버 없이 재생
+	rep, err := New(path, Replay)
+	if err != nil {
+		t.Fatal(err)
+	}
+	text, sendErr, dim = exercise(t, ctx, rep.ClientOptions())
+	if text != "첫 번째 답" || dim != 4 {
+		t.Errorf("replay: text=%q dim=%d", text, dim)
+	}
+	if sendErr == nil || !strings.Contains(sendErr.Error(), "quota exceeded") {
+		t.Errorf("replay SendMessage() error = %v, want recorded quota error", sendErr)
+	}
+	if n := len(rep.Unused()); n != 0 {
+		t.Errorf("%d interactions unused", n)
+	}
+
+	// 프롬프트가 바뀌면 차이를 보여주며 실패
+	rep, err = New(path, Replay)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client, err := vertexai.NewClient(ctx, "test-project", "us-central1", rep.ClientOptions()...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	_, err = client.GenerativeModel("gemini-2.0-flash").GenerateContent(ctx, vertexai.Text("hello, world"))
+	var merr *MismatchError
+	if !errors.As(err, &merr) || !errors.Is(err, ErrMismatch) {
+		t.Fatalf("GenerateContent() error = %v, want *MismatchError", err)
+	}
+	if !strings.Contains(merr.Diff, `- `) || !strings.Contains(merr.Diff, `"text": "hello, world"`) {
+		t.Errorf("diff =\n%s", merr.Diff)
+	}
+	if n := len(rep.Unused()); n != 3 {
+		t.Errorf("after mismatch %d interactions unused, want 3", n)
+	}
+}
+
+func TestRESTRecordReplay(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "rest.json")
+
+	srv, err := vertextest.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Close()
+	srv.AddText("서울은 대한민국의 수도입니다.")
+
+	run := func(cfg *genai.ClientConfig) (string, int) {
+		t.Helper()
+		client, err := genai.NewClient(ctx, cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		result, err := client.Models.GenerateContent(ctx, "gemini-2.0-flash", genai.Text("서울에 대해 알려줘"), nil)
+		if err != nil {
+			t.Fatalf("GenerateContent() error = %v", err)
+		}
+		images, err := client.Models.GenerateImages(ctx, "imagen-3.0-generate-002", "고래", &genai.GenerateImagesConfig{NumberOfImages: 2})
+		if err != nil {
+			t.Fatalf("GenerateImages() error = %v", err)
+		}
+		return result.Text(), len(images.GeneratedImages)
+	}
+
+	rec, err := New(path, Fake)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := rec.GenAIConfig(srv.GenAIConfig("test-project", "us-central1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantText, wantImages := run(cfg)
+	if err := rec.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	rep, err := New(path, Replay)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 재생할 때는 서버 주소만 같으면 되고 서버는 없어도 된다
+	cfg, err = rep.GenAIConfig(&genai.ClientConfig{
+		Backend:     genai.BackendVertexAI,
+		Project:     "test-project",
+		Location:    "us-central1",
+		HTTPOptions: genai.HTTPOptions{BaseURL: srv.URL},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv.Close()
+	if text, images := run(cfg); text != wantText || images != wantImages || images != 2 {
+		t.Errorf("replay = (%q, %d), want (%q, %d)", text, images, wantText, wantImages)
+	}
+}
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) { return f(req) }
+
+// 자격 증명은 헤더와 URL 어디에 있어도 카세트에 남지 않고, 재생은 다른 자격 증명으로도 맞는다.
+func TestRecordScrubsCredentials(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "secret.json")
+	base := roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{"Content-Type": {"application/json"}, "Set-Cookie": {"session=cookie-secret"}},
+			Body:       io.NopCloser(strings.NewReader(`{"ok": true}`)),
+		}, nil
+	})
+	send := func(rt http.RoundTripper, key string) *http.Response {
+		t.Helper()
+		req, err := http.NewRequest(http.MethodPost, "https://example.com/v1/models/m:generateContent?alt=sse&key="+key, strings.NewReader(`{}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer token-"+key)
+		req.Header.Set("x-goog-api-key", key)
+		resp, err := rt.RoundTrip(req)
+		if err != nil {
+			t.Fatalf("RoundTrip() error = %v", err)
+		}
+		return resp
+	}
+
+	rec, err := New(path, Record)
+	if err != nil {
+		t.Fatal(err)
+	}
+	send(rec.Transport(base), "recording-secret")
+	if err := rec.Close(); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := string(data); strings.Contains(s, "secret") || !strings.Contains(s, "?alt=sse") {
+		t.Errorf("cassette = %s, want credentials scrubbed and other parameters kept", s)
+	}
+
+	rep, err := New(path, Replay)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp := send(rep.Transport(nil), "other-key"); resp.StatusCode != http.StatusOK {
+		t.Errorf("replay status = %d", resp.StatusCode)
+	}
+}
+
+func TestDiffLines(t *testing.T) {
+	got := diffLines("a\nb\nc", "a\nx\nc\nd")
+	want := "+ x\n- b\n+ d\n"
+	if got != want {
+		t.Errorf("diffLines() =\n%s\nwant\n%s", got, want)
+	}
+}
@@ -0,0 +1,72 @@
+package cassette
+
+import (
+	"context"
+	"fmt"
+
+	"google.golang.org/api/option"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// ClientOptions 는 genai.NewClient 나 aiplatform.NewPredictionClient 의 단항 gRPC 호출을
+// 이 Recorder 로 보내는 옵션. 재생 모드에서는 인증도 끈다.
+// Fake 모드에서는 vertextest.Server.ClientOptions 와 함께 넘겨 가짜 서버로 보낸다.
+// 스트리밍 호출(SendMessageStream 등)은 기록하지 않는다.
+func (r *Recorder) ClientOptions() []option.ClientOption {
+	opts := []option.ClientOption{
+		option.WithGRPCDialOption(grpc.WithUnaryInterceptor(r.unaryInterceptor)),
+	}
+	if r.mode == Replay {
+		opts = append(opts, option.WithoutAuthentication())
+	}
+	return opts
+}
+
+func (r *Recorder) unaryInterceptor(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, callOpts ...grpc.CallOption) error {
+	reqMsg, ok1 := req.(proto.Message)
+	replyMsg, ok2 := reply.(proto.Message)
+	if !ok1 || !ok2 {
+		return invoker(ctx, method, req, reply, cc, callOpts...)
+	}
+	reqJSON, err := marshalProto(reqMsg)
+	if err != nil {
+		return err
+	}
+
+	if r.mode != Replay {
+		callErr := invoker(ctx, method, req, reply, cc, callOpts...)
+		in := Interaction{Method: method, Request: reqJSON}
+		if callErr != nil {
+			st := status.Convert(callErr)
+			in.Error = &Status{Code: int(st.Code()), Message: st.Message()}
+		} else if in.Response, err = marshalProto(replyMsg); err != nil {
+			return err
+		}
+		r.record(in)
+		return callErr
+	}
+
+	in, err := r.match(method, reqJSON)
+	if err != nil {
+		return err
+	}
+	if in.Error != nil {
+		return status.Error(codes.Code(in.Error.Code), in.Error.Message)
+	}
+	if err := (protojson.UnmarshalOptions{DiscardUnknown: true}).Unmarshal(in.Response, replyMsg); err != nil {
+		return fmt.Errorf("cassette: decode recorded %s response: %w", method, err)
+	}
+	return nil
+}
+
+func marshalProto(m proto.Message) ([]byte, error) {
+	data, err := protojson.Marshal(m)
+	if err != nil {
+		return nil, fmt.Errorf("cassette: %w", err)
+	}
+	return canonical(data)
+}
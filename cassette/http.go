@@ -0,0 +1,186 @@
+package cassette
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+
+	"cloud.google.com/go/auth"
+	"cloud.google.com/go/auth/credentials"
+	"cloud.google.com/go/auth/httptransport"
+	"google.golang.org/genai"
+)
+
+// GenAIConfig 는 cfg 를 복사해 google.golang.org/genai 클라이언트의 REST 호출이
+// 이 Recorder 를 거치도록 한다. 녹화 모드에서 cfg.HTTPClient 가 없으면
+// 기본 자격 증명으로 인증된 클라이언트를 만들고, 재생 모드에서는 가짜 자격 증명을 넣는다.
+func (r *Recorder) GenAIConfig(cfg *genai.ClientConfig) (*genai.ClientConfig, error) {
+	out := *cfg
+	var base http.RoundTripper
+	switch {
+	case r.mode == Replay:
+		out.Credentials = auth.NewCredentials(&auth.CredentialsOptions{TokenProvider: replayToken{}})
+	case cfg.HTTPClient != nil:
+		base = cfg.HTTPClient.Transport
+		if base == nil {
+			base = http.DefaultTransport
+		}
+	default:
+		if out.Credentials == nil {
+			creds, err := credentials.DetectDefault(&credentials.DetectOptions{
+				Scopes: []string{"https://www.googleapis.com/auth/cloud-platform"},
+			})
+			if err != nil {
+				return nil, fmt.Errorf("cassette: find default credentials: %w", err)
+			}
+			out.Credentials = creds
+		}
+		client, err := httptransport.NewClient(&httptransport.Options{Credentials: out.Credentials})
+		if err != nil {
+			return nil, fmt.Errorf("cassette: create HTTP client: %w", err)
+		}
+		base = client.Transport
+	}
+	out.HTTPClient = &http.Client{Transport: r.Transport(base)}
+	return &out, nil
+}
+
+type replayToken struct{}
+
+func (replayToken) Token(context.Context) (*auth.Token, error) {
+	return &auth.Token{Value: "cassette-replay", Type: "Bearer"}, nil
+}
+
+// Transport 는 REST 요청을 녹화하거나 재생하는 http.RoundTripper.
+// base 는 녹화 모드에서 실제 요청을 보낼 전송 계층이며, 재생 모드에서는 쓰이지 않는다.
+func (r *Recorder) Transport(base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &transport{r: r, base: base}
+}
+
+type transport struct {
+	r    *Recorder
+	base http.RoundTripper
+}
+
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		body, err = io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	reqJSON, err := encodeBody(body, req.Header.Get("Content-Type"))
+	if err != nil {
+		return nil, err
+	}
+	method := recordedMethod(req)
+
+	if t.r.mode != Replay {
+		out := req.Clone(req.Context())
+		out.Body = io.NopCloser(bytes.NewReader(body))
+		resp, err := t.base.RoundTrip(out)
+		if err != nil {
+			return nil, err
+		}
+		respBody, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		in := Interaction{
+			Method:      method,
+			Request:     reqJSON,
+			ContentType: resp.Header.Get("Content-Type"),
+			StatusCode:  resp.StatusCode,
+		}
+		if in.Response, err = encodeBody(respBody, in.ContentType); err != nil {
+			return nil, err
+		}
+		t.r.record(in)
+		resp.Body = io.NopCloser(bytes.NewReader(respBody))
+		return resp, nil
+	}
+
+	in, err := t.r.match(method, reqJSON)
+	if err != nil {
+		return nil, err
+	}
+	respBody, err := decodeBody(in.Response)
+	if err != nil {
+		return nil, err
+	}
+	header := http.Header{}
+	if in.ContentType != "" {
+		header.Set("Content-Type", in.ContentType)
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", in.StatusCode, http.StatusText(in.StatusCode)),
+		StatusCode:    in.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(respBody)),
+		ContentLength: int64(len(respBody)),
+		Request:       req,
+	}, nil
+}
+
+// secretParams 는 카세트에 남기지 않는 URL 인자들.
+var secretParams = []string{"key", "access_token"}
+
+// recordedMethod 는 기록과 맞춰 볼 "메서드 경로?인자". 자격 증명을 담는 인자는 뺀다.
+func recordedMethod(req *http.Request) string {
+	method := req.Method + " " + req.URL.Path
+	q := req.URL.Query()
+	for _, p := range secretParams {
+		q.Del(p)
+	}
+	if len(q) > 0 {
+		method += "?" + q.Encode()
+	}
+	return method
+}
+
+// encodeBody 는 JSON 본문은 정규화하고, 그 밖의 본문(SSE 등)은 JSON 문자열로 감싼다.
+func encodeBody(body []byte, contentType string) (json.RawMessage, error) {
+	if len(body) == 0 {
+		return nil, nil
+	}
+	if isJSON(contentType) {
+		if c, err := canonical(body); err == nil {
+			return c, nil
+		}
+	}
+	return json.Marshal(string(body))
+}
+
+// decodeBody 는 encodeBody 의 역.
+func decodeBody(data json.RawMessage) ([]byte, error) {
+	if len(data) == 0 {
+		return nil, nil
+	}
+	if data[0] == '"' {
+		var s string
+		if err := json.Unmarshal(data, &s); err != nil {
+			return nil, fmt.Errorf("cassette: decode recorded body: %w", err)
+		}
+		return []byte(s), nil
+	}
+	return data, nil
+}
+
+func isJSON(contentType string) bool {
+	mt, _, _ := mime.ParseMediaType(contentType)
+	return mt == "application/json" || mt == ""
+}
@@ -0,0 +1,263 @@
+// Package chunker 는 긴 문서를 임베딩 모델의 입력 한도 안에 드는 조각(chunk)으로 나눈다.
+//
+// 고정 길이, 문장, 문단, 마크다운 제목 단위의 네 가지 방식이 있고, 모두 앞 조각의 끝부분을
+// Overlap 만큼 다음 조각에 겹쳐 넣을 수 있다. 길이는 룬(글자) 수로 센다.
+// 한국어는 대략 글자당 한 토큰이므로 글자 수가 토큰 수의 보수적인 근사가 된다.
+package chunker
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// 기본 조각 크기와 겹침 (룬 단위).
+const (
+	DefaultSize    = 500
+	DefaultOverlap = 50
+)
+
+// Chunk 는 문서 조각 하나.
+type Chunk struct {
+	// DocID 는 원래 문서의 ID.
+	DocID string
+	// Index 는 문서 안에서 몇 번째 조각인지 (0부터).
+	Index int
+	Text  string
+	// Start, End 는 원래 문서 안에서 Text 의 바이트 위치. Text == doc[Start:End].
+	Start, End int
+	// Heading 은 Markdown 방식에서 조각이 속한 제목 경로 ("설치 > 요구 사항"). 그 밖에는 빈 문자열.
+	Heading string
+}
+
+// ID 는 "문서ID#번호" 형식의 조각 ID.
+func (c Chunk) ID() string {
+	return c.DocID + "#" + strconv.Itoa(c.Index)
+}
+
+// Chunker 는 문서를 조각으로 나눈다.
+type Chunker interface {
+	Split(docID, text string) []Chunk
+}
+
+// 설정에서 방식을 고를 때 쓰는 이름.
+const (
+	StrategyFixed     = "fixed"
+	StrategySentence  = "sentence"
+	StrategyParagraph = "paragraph"
+	StrategyMarkdown  = "markdown"
+)
+
+// New 는 strategy 이름에 맞는 Chunker 를 만든다. size 나 overlap 이 0 이하이면 기본값을 쓴다.
+func New(strategy string, size, overlap int) (Chunker, error) {
+	switch strategy {
+	case StrategyFixed:
+		return &FixedSize{Size: size, Overlap: overlap}, nil
+	case StrategySentence:
+		return &Sentences{Size: size, Overlap: overlap}, nil
+	case StrategyParagraph:
+		return &Paragraphs{Size: size, Overlap: overlap}, nil
+	case StrategyMarkdown:
+		return &Markdown{Size: size, Overlap: overlap}, nil
+	}
+	return nil, fmt.Errorf("chunker: unknown strategy %q", strategy)
+}
+
+// Span 은 문서 안의 바이트 구간 [Start, End).
+type Span struct {
+	Start, End int
+}
+
+func limits(size, overlap int) (int, int) {
+	if size <= 0 {
+		size = DefaultSize
+	}
+	if overlap < 0 {
+		overlap = 0
+	}
+	if overlap >= size {
+		overlap = size / 2
+	}
+	return size, overlap
+}
+
+// FixedSize 는 Size 룬마다 자르는 Chunker. 단어 중간에서도 자른다.
+type FixedSize struct {
+	Size, Overlap int
+}
+
+// Split 은 Chunker 를 구현한다.
+func (f *FixedSize) Split(docID, text string) []Chunk {
+	size, overlap := limits(f.Size, f.Overlap)
+	return build(docID, text, windows(text, trim(text, Span{0, len(text)}), size, overlap), "")
+}
+
+// Sentences 는 문장 단위로 나눈 뒤 Size 룬을 넘지 않게 이어 붙이는 Chunker.
+type Sentences struct {
+	Size, Overlap int
+}
+
+// Split 은 Chunker 를 구현한다.
+func (s *Sentences) Split(docID, text string) []Chunk {
+	size, overlap := limits(s.Size, s.Overlap)
+	return build(docID, text, pack(text, SentenceSpans(text), size, overlap), "")
+}
+
+// Paragraphs 는 빈 줄로 나뉜 문단 단위로 이어 붙이는 Chunker.
+// Size 를 넘는 문단은 문장 단위로 다시 나눈다.
+type Paragraphs struct {
+	Size, Overlap int
+}
+
+// Split 은 Chunker 를 구현한다.
+func (p *Paragraphs) Split(docID, text string) []Chunk {
+	size, overlap := limits(p.Size, p.Overlap)
+	return build(docID, text, pack(text, paragraphUnits(text, Span{0, len(text)}, size), size, overlap), "")
+}
+
+// build 는 구간들로 조각을 만든다.
+func build(docID, text string, spans []Span, heading string) []Chunk {
+	chunks := make([]Chunk, len(spans))
+	for i, s := range spans {
+		chunks[i] = Chunk{DocID: docID, Index: i, Text: text[s.Start:s.End], Start: s.Start, End: s.End, Heading: heading}
+	}
+	return chunks
+}
+
+func runeLen(text string, s Span) int {
+	return utf8.RuneCountInString(text[s.Start:s.End])
+}
+
+// trim 은 구간 앞뒤의 공백을 뺀다.
+func trim(text string, s Span) Span {
+	for s.Start < s.End {
+		r, n := utf8.DecodeRuneInString(text[s.Start:])
+		if !unicode.IsSpace(r) {
+			break
+		}
+		s.Start += n
+	}
+	for s.End > s.Start {
+		r, n := utf8.DecodeLastRuneInString(text[:s.End])
+		if !unicode.IsSpace(r) {
+			break
+		}
+		s.End -= n
+	}
+	return s
+}
+
+// windows 는 구간 s 를 size 룬 창으로 나누고 창마다 overlap 룬씩 겹친다.
+func windows(text string, s Span, size, overlap int) []Span {
+	if s.Start >= s.End {
+		return nil
+	}
+	// 룬 경계의 바이트 위치
+	var bounds []int
+	for i := range text[s.Start:s.End] {
+		bounds = append(bounds, s.Start+i)
+	}
+	bounds = append(bounds, s.End)
+	n := len(bounds) - 1
+
+	var out []Span
+	for lo := 0; ; lo += size - overlap {
+		hi := min(lo+size, n)
+		if w := trim(text, Span{bounds[lo], bounds[hi]}); w.Start < w.End {
+			out = append(out, w)
+		}
+		if hi == n {
+			return out
+		}
+	}
+}
+
+// pack 은 문장이나 문단 같은 단위들을 size 룬을 넘지 않게 이어 붙인다.
+// 다음 조각은 앞 조각 끝의 단위들 중 overlap 룬 이내를 다시 포함한다.
+// size 보다 긴 단위는 고정 길이로 자른다.
+func pack(text string, units []Span, size, overlap int) []Span {
+	var split []Span
+	for _, u := range units {
+		if runeLen(text, u) > size {
+			split = append(split, windows(text, u, size, overlap)...)
+		} else {
+			split = append(split, u)
+		}
+	}
+	units = split
+
+	var out []Span
+	for i := 0; i < len(units); {
+		// 단위 사이의 공백까지 원문 구간 그대로 센다
+		j := i + 1
+		for j < len(units) && runeLen(text, Span{units[i].Start, units[j].End}) <= size {
+			j++
+		}
+		out = append(out, Span{units[i].Start, units[j-1].End})
+		if j == len(units) {
+			break
+		}
+		// 겹칠 단위를 고르되, 다음 단위가 함께 들어갈 자리는 남긴다
+		k := j
+		for k-1 > i &&
+			runeLen(text, Span{units[k-1].Start, units[j-1].End}) <= overlap &&
+			runeLen(text, Span{units[k-1].Start, units[j].End}) <= size {
+			k--
+		}
+		i = k
+	}
+	return out
+}
+
+// paragraphUnits 는 s 안의 문단들을 돌려준다. size 보다 긴 문단은 문장들로 나눈다.
+func paragraphUnits(text string, s Span, size int) []Span {
+	var out []Span
+	add := func(p Span) {
+		p = trim(text, p)
+		if p.Start >= p.End {
+			return
+		}
+		if runeLen(text, p) <= size {
+			out = append(out, p)
+			return
+		}
+		for _, sent := range SentenceSpans(text[p.Start:p.End]) {
+			out = append(out, Span{p.Start + sent.Start, p.Start + sent.End})
+		}
+	}
+
+	start := s.Start
+	for i := s.Start; i < s.End; {
+		// 빈 줄(공백만 있는 줄 포함)에서 문단을 나눈다
+		if text[i] == '\n' {
+			j := i + 1
+			for j < s.End && (text[j] == ' ' || text[j] == '\t' || text[j] == '\r') {
+				j++
+			}
+			if j < s.End && text[j] == '\n' {
+				add(Span{start, i})
+				for j < s.End && unicode.IsSpace(rune(text[j])) {
+					j++
+				}
+				start, i = j, j
+				continue
+			}
+		}
+		i++
+	}
+	add(Span{start, s.End})
+	return out
+}
+
+// headingPath 는 제목 스택을 "A > B" 로 잇는다.
+func headingPath(stack []string) string {
+	var parts []string
+	for _, h := range stack {
+		if h != "" {
+			parts = append(parts, h)
+		}
+	}
+	return strings.Join(parts, " > ")
+}
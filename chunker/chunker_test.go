@@ -0,0 +1,137 @@
+package chunker
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+// checkChunks 는 모든 조각이 원문의 구간과 일치하고 크기 한도를 지키는지 확인한다.
+func checkChunks(t *testing.T, docID, text string, chunks []Chunk, size int) {
+	t.Helper()
+	if len(chunks) == 0 {
+		t.Fatal("no chunks")
+	}
+	for i, c := range chunks {
+		if c.DocID != docID || c.Index != i {
+			t.Errorf("chunk %d: DocID=%q Index=%d", i, c.DocID, c.Index)
+		}
+		if text[c.Start:c.End] != c.Text {
+			t.Errorf("chunk %d: offsets [%d:%d] do not match text %q", i, c.Start, c.End, c.Text)
+		}
+		if n := utf8.RuneCountInString(c.Text); n > size {
+			t.Errorf("chunk %d has %d runes, limit %d", i, n, size)
+		}
+		if strings.TrimSpace(c.Text) != c.Text {
+			t.Errorf("chunk %d has surrounding whitespace: %q", i, c.Text)
+		}
+	}
+}
+
+func TestFixedSize(t *testing.T) {
+	text := strings.Repeat("가나다라마바사아자차", 5) // 50 runes
+	chunks := (&FixedSize{Size: 20, Overlap: 5}).Split("doc", text)
+	checkChunks(t, "doc", text, chunks, 20)
+	// 0-20, 15-35, 30-50
+	if len(chunks) != 3 {
+		t.Fatalf("got %d chunks, want 3", len(chunks))
+	}
+	if !strings.HasSuffix(chunks[0].Text, chunks[1].Text[:len("바사아자차")]) {
+		t.Errorf("no overlap between %q and %q", chunks[0].Text, chunks[1].Text)
+	}
+	if chunks[2].End != len(text) {
+		t.Errorf("last chunk ends at %d, want %d", chunks[2].End, len(text))
+	}
+}
+
+func TestSentences(t *testing.T) {
+	text := "첫 문장입니다. 두 번째 문장이에요. 세 번째 문장입니다. 네 번째입니다."
+	chunks := (&Sentences{Size: 24, Overlap: 12}).Split("doc", text)
+	checkChunks(t, "doc", text, chunks, 24)
+	want := []string{
+		"첫 문장입니다. 두 번째 문장이에요.",
+		"두 번째 문장이에요. 세 번째 문장입니다.",
+		"세 번째 문장입니다. 네 번째입니다.",
+	}
+	if len(chunks) != len(want) {
+		t.Fatalf("got %d chunks %v, want %d", len(chunks), chunks, len(want))
+	}
+	for i, c := range chunks {
+		if c.Text != want[i] {
+			t.Errorf("chunk %d = %q, want %q", i, c.Text, want[i])
+		}
+	}
+
+	// 겹침 없이
+	chunks = (&Sentences{Size: 24}).Split("doc", text)
+	if len(chunks) != 2 || chunks[1].Text != "세 번째 문장입니다. 네 번째입니다." {
+		t.Errorf("without overlap got %v", chunks)
+	}
+}
+
+func TestParagraphs(t *testing.T) {
+	long := strings.Repeat("긴 문단의 문장입니다. ", 6) // 문장 6개, 72 runes
+	text := "짧은 문단 하나.\n\n짧은 문단 둘.\n  \n" + long + "\n\n마지막."
+	chunks := (&Paragraphs{Size: 30}).Split("doc", text)
+	checkChunks(t, "doc", text, chunks, 30)
+	if chunks[0].Text != "짧은 문단 하나.\n\n짧은 문단 둘." {
+		t.Errorf("first chunk = %q", chunks[0].Text)
+	}
+	if last := chunks[len(chunks)-1]; !strings.HasSuffix(last.Text, "마지막.") {
+		t.Errorf("last chunk = %q", last.Text)
+	}
+}
+
+func TestMarkdown(t *testing.T) {
+	text := `도입 문단.
+
+# 설치
+
+설치 방법입니다.
+
+## 요구 사항
+
+Go 1.23 이 필요합니다.
+
+` + "```sh\n# 이것은 제목이 아님\ngo build\n```" + `
+
+# 사용법
+
+실행하세요.
+`
+	chunks := (&Markdown{Size: 200}).Split("readme", text)
+	checkChunks(t, "readme", text, chunks, 200)
+
+	want := []struct{ heading, prefix string }{
+		{"", "도입 문단."},
+		{"설치", "# 설치"},
+		{"설치 > 요구 사항", "## 요구 사항"},
+		{"사용법", "# 사용법"},
+	}
+	if len(chunks) != len(want) {
+		t.Fatalf("got %d chunks: %+v", len(chunks), chunks)
+	}
+	for i, w := range want {
+		if chunks[i].Heading != w.heading || !strings.HasPrefix(chunks[i].Text, w.prefix) {
+			t.Errorf("chunk %d = (%q, %q), want heading %q prefix %q", i, chunks[i].Heading, chunks[i].Text, w.heading, w.prefix)
+		}
+	}
+	if !strings.Contains(chunks[2].Text, "# 이것은 제목이 아님") {
+		t.Errorf("code block was split as heading: %q", chunks[2].Text)
+	}
+}
+
+func TestNew(t *testing.T) {
+	for _, s := range []string{StrategyFixed, StrategySentence, StrategyParagraph, StrategyMarkdown} {
+		c, err := New(s, 0, 0)
+		if err != nil {
+			t.Fatalf("New(%q) error = %v", s, err)
+		}
+		if chunks := c.Split("d", "짧은 문서입니다."); len(chunks) != 1 || chunks[0].ID() != "d#0" {
+			t.Errorf("New(%q).Split() = %v", s, chunks)
+		}
+	}
+	if _, err := New("words", 0, 0); err == nil {
+		t.Error("New(unknown) error = nil")
+	}
+}
@@ -0,0 +1,74 @@
+package chunker
+
+import "strings"
+
+// Markdown 은 마크다운 제목(#~######)으로 절을 나누고, 절마다 문단 단위로 이어 붙이는 Chunker.
+// 조각은 절의 경계를 넘지 않으며 Heading 에 제목 경로가 들어간다.
+// 코드 블록(```, ~~~) 안의 # 은 제목으로 보지 않는다.
+type Markdown struct {
+	Size, Overlap int
+}
+
+// Split 은 Chunker 를 구현한다.
+func (m *Markdown) Split(docID, text string) []Chunk {
+	size, overlap := limits(m.Size, m.Overlap)
+
+	var (
+		chunks []Chunk
+		stack  []string // 수준별 제목, stack[0] 이 # 제목
+		start  int      // 현재 절의 시작
+		fence  string   // 열린 코드 블록의 표시
+	)
+	flush := func(end int) {
+		heading := headingPath(stack)
+		for _, s := range pack(text, paragraphUnits(text, Span{start, end}, size), size, overlap) {
+			chunks = append(chunks, Chunk{
+				DocID: docID, Index: len(chunks), Text: text[s.Start:s.End],
+				Start: s.Start, End: s.End, Heading: heading,
+			})
+		}
+	}
+
+	for pos := 0; pos < len(text); {
+		end := strings.IndexByte(text[pos:], '\n')
+		if end < 0 {
+			end = len(text)
+		} else {
+			end += pos
+		}
+		line := strings.TrimSpace(text[pos:end])
+
+		switch {
+		case fence != "":
+			if strings.HasPrefix(line, fence) {
+				fence = ""
+			}
+		case strings.HasPrefix(line, "```") || strings.HasPrefix(line, "~~~"):
+			fence = line[:3]
+		default:
+			if level, title := parseHeading(line); level > 0 {
+				flush(pos)
+				start = pos
+				for len(stack) < level {
+					stack = append(stack, "")
+				}
+				stack = append(stack[:level-1], title)
+			}
+		}
+		pos = end + 1
+	}
+	flush(len(text))
+	return chunks
+}
+
+// parseHeading 은 "## 제목" 형식의 줄에서 수준과 제목을 읽는다. 제목이 아니면 0.
+func parseHeading(line string) (int, string) {
+	level := 0
+	for level < len(line) && line[level] == '#' {
+		level++
+	}
+	if level == 0 || level > 6 || level == len(line) || line[level] != ' ' {
+		return 0, ""
+	}
+	return level, strings.TrimSpace(strings.TrimRight(line[level:], "#"))
+}
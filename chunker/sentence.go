@@ -0,0 +1,153 @@
+package chunker
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// abbreviations 는 뒤에 마침표가 와도 문장이 끝나지 않는 영어 약어 (소문자).
+var abbreviations = map[string]bool{
+	"mr": true, "mrs": true, "ms": true, "dr": true, "prof": true, "st": true,
+	"vs": true, "etc": true, "e.g": true, "i.e": true, "no": true, "fig": true,
+	"inc": true, "ltd": true, "co": true, "jr": true, "sr": true,
+}
+
+// koreanEndings 는 마침표 없이 줄이 바뀌어도 문장 끝으로 보는 한국어 종결 어미의 마지막 글자.
+// 목록이나 개조식 문서("~함", "~됨")에서 흔하다.
+var koreanEndings = map[rune]bool{
+	'다': true, '요': true, '죠': true, '까': true, '음': true, '함': true, '됨': true, '임': true,
+}
+
+func isTerminator(r rune) bool {
+	switch r {
+	case '.', '!', '?', '。', '！', '？', '…':
+		return true
+	}
+	return false
+}
+
+func isCloser(r rune) bool {
+	switch r {
+	case '"', '\'', ')', ']', '”', '’', '」', '』', '）':
+		return true
+	}
+	return false
+}
+
+func isHangul(r rune) bool {
+	return unicode.Is(unicode.Hangul, r)
+}
+
+// SentenceSpans 는 text 를 문장 구간들로 나눈다. 각 구간은 앞뒤 공백이 없다.
+//
+// 마침표·물음표·느낌표(전각 포함)와 말줄임표 뒤에 공백이나 글 끝이 오면 문장이 끝난다.
+// 한글 뒤의 마침표("했다.", "해요.")는 공백 없이 다음 글자가 붙어도 문장 끝으로 본다.
+// 영어 약어("Dr.", "e.g.")와 이니셜("U.S."), 소수점("3.14")에서는 나누지 않는다.
+// 빈 줄과, 한국어 종결 어미("다", "요", "음" 등) 뒤의 줄바꿈에서도 나눈다.
+func SentenceSpans(text string) []Span {
+	var out []Span
+	emit := func(s Span) {
+		if s = trim(text, s); s.Start < s.End {
+			out = append(out, s)
+		}
+	}
+
+	start := 0
+	prev := rune(-1) // 직전의 공백 아닌 글자
+	for i := 0; i < len(text); {
+		r, n := utf8.DecodeRuneInString(text[i:])
+		switch {
+		case r == '\n':
+			if koreanEndings[prev] || isBlankLineAhead(text, i+n) {
+				emit(Span{start, i})
+				start = i + n
+				prev = -1
+			}
+		case isTerminator(r):
+			// 이어지는 부호와 닫는 따옴표·괄호까지 문장에 넣는다
+			j := i + n
+			for j < len(text) {
+				r2, n2 := utf8.DecodeRuneInString(text[j:])
+				if !isTerminator(r2) && !isCloser(r2) {
+					break
+				}
+				j += n2
+			}
+			if sentenceEnds(text, i, j, r, prev) {
+				emit(Span{start, j})
+				start = j
+				prev = -1
+				i = j
+				continue
+			}
+		}
+		if !unicode.IsSpace(r) {
+			prev = r
+		}
+		i += n
+	}
+	emit(Span{start, len(text)})
+	return out
+}
+
+// sentenceEnds 는 text[i] 의 부호 r (부호와 닫는 문자는 j 까지)에서 문장이 끝나는지 정한다.
+func sentenceEnds(text string, i, j int, r, prev rune) bool {
+	next, _ := utf8.DecodeRuneInString(text[j:])
+	atEnd := j >= len(text)
+	if r != '.' {
+		return atEnd || unicode.IsSpace(next) || isHangul(prev)
+	}
+	if isHangul(prev) {
+		// "했다.그리고" 처럼 붙어 있어도 끝, 단 "다.5" 같은 숫자는 제외
+		return atEnd || !unicode.IsDigit(next)
+	}
+	if !atEnd && !unicode.IsSpace(next) {
+		// 소수점, URL, "U.S." 의 중간 등
+		return false
+	}
+	word := strings.ToLower(lastWord(text[:i]))
+	if abbreviations[word] || strings.Contains(word, ".") {
+		// "Dr.", 점이 든 약어 "U.S.", "e.g."
+		return false
+	}
+	// 한 글자 대문자 이니셜 ("J. K. Rowling")
+	if w := lastWord(text[:i]); utf8.RuneCountInString(w) == 1 && unicode.IsUpper([]rune(w)[0]) {
+		return false
+	}
+	// 다음 단어가 소문자로 시작하면 문장이 이어지는 것으로 본다
+	if k := skipSpaces(text, j); k < len(text) {
+		if r2, _ := utf8.DecodeRuneInString(text[k:]); unicode.IsLower(r2) {
+			return false
+		}
+	}
+	return true
+}
+
+// lastWord 는 s 끝의 글자·마침표로 된 단어 ("e.g" 포함).
+func lastWord(s string) string {
+	end := len(s)
+	start := end
+	for start > 0 {
+		r, n := utf8.DecodeLastRuneInString(s[:start])
+		if !unicode.IsLetter(r) && r != '.' {
+			break
+		}
+		start -= n
+	}
+	return strings.Trim(s[start:end], ".")
+}
+
+// skipSpaces 는 i 부터 줄바꿈이 아닌 공백을 건너뛴다.
+func skipSpaces(text string, i int) int {
+	for i < len(text) && (text[i] == ' ' || text[i] == '\t' || text[i] == '\r') {
+		i++
+	}
+	return i
+}
+
+// isBlankLineAhead 는 i 부터 다음 줄바꿈까지 공백뿐인지 (빈 줄인지).
+func isBlankLineAhead(text string, i int) bool {
+	i = skipSpaces(text, i)
+	return i < len(text) && text[i] == '\n'
+}
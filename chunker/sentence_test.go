@@ -0,0 +1,61 @@
+package chunker
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestSentenceSpans(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want []string
+	}{
+		{
+			name: "korean endings",
+			text: "Vertex AI는 ML 플랫폼입니다. RAG를 지원해요! 정말 그런가요? 네.",
+			want: []string{"Vertex AI는 ML 플랫폼입니다.", "RAG를 지원해요!", "정말 그런가요?", "네."},
+		},
+		{
+			name: "no space after korean period",
+			text: "비가 왔다.그래서 집에 있었다.",
+			want: []string{"비가 왔다.", "그래서 집에 있었다."},
+		},
+		{
+			name: "full-width and ellipsis",
+			text: "그렇군요…그럼 갈까요？ 좋아요！",
+			want: []string{"그렇군요…", "그럼 갈까요？", "좋아요！"},
+		},
+		{
+			name: "quotes stay with sentence",
+			text: "그는 \"알겠다.\" 라고 말했다. 끝.",
+			want: []string{"그는 \"알겠다.\"", "라고 말했다.", "끝."},
+		},
+		{
+			name: "english abbreviations and decimals",
+			text: "Dr. Kim paid $3.14 for it, e.g. a coffee. The U.S. team won. J. K. Rowling wrote it.",
+			want: []string{"Dr. Kim paid $3.14 for it, e.g. a coffee.", "The U.S. team won.", "J. K. Rowling wrote it."},
+		},
+		{
+			name: "line breaks after korean endings",
+			text: "- 설치가 간단함\n- 속도 개선됨\n이 줄은\n이어진다",
+			want: []string{"- 설치가 간단함", "- 속도 개선됨", "이 줄은\n이어진다"},
+		},
+		{
+			name: "blank line",
+			text: "제목 없는 문단\n\n다음 문단",
+			want: []string{"제목 없는 문단", "다음 문단"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, s := range SentenceSpans(tt.text) {
+				got = append(got, tt.text[s.Start:s.End])
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("SentenceSpans() =\n%q\nwant\n%q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,228 @@
+package embedding
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+)
+
+// Key 는 캐시된 벡터 하나를 가리킨다. 같은 텍스트라도 모델, 용도, 차원이 다르면 다른 벡터다.
+type Key struct {
+	Model          string
+	TaskType       TaskType
+	Dimensionality int
+	// Hash 는 텍스트의 sha256.
+	Hash [sha256.Size]byte
+}
+
+// NewKey 는 text 의 키를 만든다.
+func NewKey(model string, taskType TaskType, dimensionality int, text string) Key {
+	return Key{Model: model, TaskType: taskType, Dimensionality: dimensionality, Hash: sha256.Sum256([]byte(text))}
+}
+
+// String 은 키 전체를 담은 문자열. 파일 이름이나 DB 키로 쓸 수 있다.
+func (k Key) String() string {
+	sum := sha256.Sum256([]byte(k.Model + "\x00" + string(k.TaskType) + "\x00" + strconv.Itoa(k.Dimensionality)))
+	return hex.EncodeToString(sum[:8]) + "-" + hex.EncodeToString(k.Hash[:])
+}
+
+// Store 는 임베딩 캐시 저장소.
+type Store interface {
+	// Get 은 keys 와 같은 순서로 벡터를 돌려준다. 없는 항목은 nil.
+	Get(ctx context.Context, keys []Key) ([][]float32, error)
+	// Put 은 벡터들을 저장한다.
+	Put(ctx context.Context, keys []Key, vecs [][]float32) error
+}
+
+// Cached 는 Store 를 앞에 둔 Embedder. 캐시에 없는 텍스트만 Embedder 로 보낸다.
+//
+// 벡터만 저장하므로 입력이 잘렸다는 *TruncatedError 는 처음 임베딩할 때만 보고된다.
+type Cached struct {
+	Embedder Embedder
+	Store    Store
+	// Model 과 Dimensionality 는 캐시 키에 들어간다. 모델이나 차원을 바꾸면 캐시가 자연히 갈린다.
+	Model          string
+	Dimensionality int
+}
+
+// NewCached 는 e 앞에 store 를 둔다.
+func NewCached(e Embedder, store Store, model string, dimensionality int) *Cached {
+	return &Cached{Embedder: e, Store: store, Model: model, Dimensionality: dimensionality}
+}
+
+// Embed 는 Embedder 를 구현한다.
+func (c *Cached) Embed(ctx context.Context, texts []string, taskType TaskType) ([][]float32, error) {
+	keys := make([]Key, len(texts))
+	for i, text := range texts {
+		keys[i] = NewKey(c.Model, taskType, c.Dimensionality, text)
+	}
+	out, err := c.Store.Get(ctx, keys)
+	if err != nil {
+		return nil, fmt.Errorf("embedding cache: %w", err)
+	}
+
+	// 캐시에 없는 텍스트를 중복 없이 모은다
+	var (
+		missTexts []string
+		missKeys  []Key
+		missAt    = map[Key][]int{}
+	)
+	for i, vec := range out {
+		if vec != nil {
+			continue
+		}
+		if _, seen := missAt[keys[i]]; !seen {
+			missTexts = append(missTexts, texts[i])
+			missKeys = append(missKeys, keys[i])
+		}
+		missAt[keys[i]] = append(missAt[keys[i]], i)
+	}
+	if len(missTexts) == 0 {
+		return out, nil
+	}
+
+	vecs, err := c.Embedder.Embed(ctx, missTexts, taskType)
+	var truncErr *TruncatedError
+	if err != nil && !errors.As(err, &truncErr) {
+		return nil, err
+	}
+	if err := c.Store.Put(ctx, missKeys, vecs); err != nil {
+		return nil, fmt.Errorf("embedding cache: %w", err)
+	}
+	for j, k := range missKeys {
+		for _, i := range missAt[k] {
+			out[i] = vecs[j]
+		}
+	}
+	if truncErr != nil {
+		// 잘린 위치를 원래 texts 기준으로 바꾼다
+		var indexes []int
+		for _, j := range truncErr.Indexes {
+			indexes = append(indexes, missAt[missKeys[j]]...)
+		}
+		return out, &TruncatedError{Indexes: indexes}
+	}
+	return out, nil
+}
+
+// LRU 는 최근에 쓴 capacity 개의 벡터를 메모리에 두는 Store.
+type LRU struct {
+	capacity int
+
+	mu    sync.Mutex
+	order *list.List // 앞쪽이 최근
+	items map[Key]*list.Element
+}
+
+type lruEntry struct {
+	key Key
+	vec []float32
+}
+
+// NewLRU 는 최대 capacity 개를 담는 LRU 를 만든다.
+func NewLRU(capacity int) *LRU {
+	return &LRU{capacity: capacity, order: list.New(), items: make(map[Key]*list.Element)}
+}
+
+// Len 은 담긴 벡터 수.
+func (c *LRU) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
+
+// Get 은 Store 를 구현한다.
+func (c *LRU) Get(ctx context.Context, keys []Key) ([][]float32, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	out := make([][]float32, len(keys))
+	for i, k := range keys {
+		if el, ok := c.items[k]; ok {
+			c.order.MoveToFront(el)
+			out[i] = el.Value.(*lruEntry).vec
+		}
+	}
+	return out, nil
+}
+
+// Put 은 Store 를 구현한다. 가득 차면 가장 오래 쓰지 않은 벡터를 버린다.
+func (c *LRU) Put(ctx context.Context, keys []Key, vecs [][]float32) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i, k := range keys {
+		if el, ok := c.items[k]; ok {
+			el.Value.(*lruEntry).vec = vecs[i]
+			c.order.MoveToFront(el)
+			continue
+		}
+		c.items[k] = c.order.PushFront(&lruEntry{key: k, vec: vecs[i]})
+		for c.capacity > 0 && c.order.Len() > c.capacity {
+			oldest := c.order.Back()
+			c.order.Remove(oldest)
+			delete(c.items, oldest.Value.(*lruEntry).key)
+		}
+	}
+	return nil
+}
+
+// Tiered 는 앞의 Store 부터 차례로 찾고, 뒤에서 찾은 벡터는 앞쪽에도 채워 넣는 Store.
+// 보통 NewLRU 를 DiskCache 나 PGCache 앞에 둔다.
+type Tiered []Store
+
+// Get 은 Store 를 구현한다.
+func (t Tiered) Get(ctx context.Context, keys []Key) ([][]float32, error) {
+	out := make([][]float32, len(keys))
+	missing := make([]int, len(keys))
+	for i := range missing {
+		missing[i] = i
+	}
+	for level, s := range t {
+		if len(missing) == 0 {
+			break
+		}
+		sub := make([]Key, len(missing))
+		for j, i := range missing {
+			sub[j] = keys[i]
+		}
+		vecs, err := s.Get(ctx, sub)
+		if err != nil {
+			return nil, err
+		}
+		var (
+			found     []Key
+			foundVecs [][]float32
+			next      []int
+		)
+		for j, i := range missing {
+			if vecs[j] == nil {
+				next = append(next, i)
+				continue
+			}
+			out[i] = vecs[j]
+			found = append(found, keys[i])
+			foundVecs = append(foundVecs, vecs[j])
+		}
+		for _, upper := range t[:level] {
+			if err := upper.Put(ctx, found, foundVecs); err != nil {
+				return nil, err
+			}
+		}
+		missing = next
+	}
+	return out, nil
+}
+
+// Put 은 Store 를 구현한다. 모든 단계에 저장한다.
+func (t Tiered) Put(ctx context.Context, keys []Key, vecs [][]float32) error {
+	for _, s := range t {
+		if err := s.Put(ctx, keys, vecs); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+package embedding
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	"vertex/vertextest"
+)
+
+// countingEmbedder 는 FakeEmbedding 을 돌려주고 임베딩한 텍스트를 기록한다.
+// truncate 에 든 텍스트는 잘린 것으로 보고한다.
+type countingEmbedder struct {
+	texts    []string
+	truncate map[string]bool
+}
+
+func (e *countingEmbedder) Embed(ctx context.Context, texts []string, taskType TaskType) ([][]float32, error) {
+	e.texts = append(e.texts, texts...)
+	out := make([][]float32, len(texts))
+	var truncated []int
+	for i, text := range texts {
+		out[i] = vertextest.FakeEmbedding(string(taskType)+text, 4)
+		if e.truncate[text] {
+			truncated = append(truncated, i)
+		}
+	}
+	if truncated != nil {
+		return out, &TruncatedError{Indexes: truncated}
+	}
+	return out, nil
+}
+
+func TestCached(t *testing.T) {
+	ctx := context.Background()
+	inner := &countingEmbedder{truncate: map[string]bool{"long": true}}
+	c := NewCached(inner, NewLRU(10), "text-multilingual-embedding-002", 4)
+
+	// 첫 호출: 중복 텍스트는 한 번만 임베딩하고, 잘린 위치는 원래 순서로 보고
+	vecs, err := c.Embed(ctx, []string{"a", "long", "a", "b"}, RetrievalDocument)
+	var terr *TruncatedError
+	if !errors.As(err, &terr) || !slices.Equal(terr.Indexes, []int{1}) {
+		t.Fatalf("Embed() error = %v, want truncated [1]", err)
+	}
+	if !slices.Equal(inner.texts, []string{"a", "long", "b"}) {
+		t.Errorf("embedded %v, want [a long b]", inner.texts)
+	}
+	if !slices.Equal(vecs[0], vecs[2]) || slices.Equal(vecs[0], vecs[3]) {
+		t.Errorf("vectors = %v", vecs)
+	}
+
+	// 두 번째 호출: 새 텍스트만 임베딩
+	inner.texts = nil
+	vecs2, err := c.Embed(ctx, []string{"b", "c", "a"}, RetrievalDocument)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(inner.texts, []string{"c"}) || !slices.Equal(vecs2[0], vecs[3]) {
+		t.Errorf("second call embedded %v", inner.texts)
+	}
+
+	// 용도가 다르면 다른 키
+	inner.texts = nil
+	if _, err := c.Embed(ctx, []string{"a"}, RetrievalQuery); err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(inner.texts, []string{"a"}) {
+		t.Errorf("task type did not separate cache keys: embedded %v", inner.texts)
+	}
+}
+
+func TestLRU(t *testing.T) {
+	ctx := context.Background()
+	c := NewLRU(2)
+	k := func(s string) Key { return NewKey("m", RetrievalDocument, 1, s) }
+	c.Put(ctx, []Key{k("a"), k("b")}, [][]float32{{1}, {2}})
+	c.Get(ctx, []Key{k("a")}) // a 를 최근으로
+	c.Put(ctx, []Key{k("c")}, [][]float32{{3}})
+
+	got, _ := c.Get(ctx, []Key{k("a"), k("b"), k("c")})
+	if got[0] == nil || got[1] != nil || got[2] == nil || c.Len() != 2 {
+		t.Errorf("after eviction got %v (len %d), want b evicted", got, c.Len())
+	}
+}
+
+func TestTiered(t *testing.T) {
+	ctx := context.Background()
+	front, back := NewLRU(10), NewLRU(10)
+	k := NewKey("m", RetrievalDocument, 1, "a")
+	back.Put(ctx, []Key{k}, [][]float32{{1}})
+
+	got, err := Tiered{front, back}.Get(ctx, []Key{k, NewKey("m", RetrievalDocument, 1, "b")})
+	if err != nil || got[0] == nil || got[1] != nil {
+		t.Fatalf("Get() = %v, %v", got, err)
+	}
+	if front.Len() != 1 {
+		t.Error("hit in back store was not copied to front store")
+	}
+}
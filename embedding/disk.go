@@ -0,0 +1,93 @@
+package embedding
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io/fs"
+	"math"
+	"os"
+	"path/filepath"
+)
+
+// DiskCache 는 벡터를 Dir 아래에 키마다 파일 하나로 저장하는 Store.
+// 파일은 float32 값들을 리틀 엔디언으로 이어 쓴 것이다.
+type DiskCache struct {
+	Dir string
+}
+
+// NewDiskCache 는 dir 을 만들고 그 안에 저장하는 DiskCache 를 돌려준다.
+func NewDiskCache(dir string) (*DiskCache, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("embedding cache: %w", err)
+	}
+	return &DiskCache{Dir: dir}, nil
+}
+
+// DefaultCacheDir 는 사용자 캐시 디렉터리 아래의 기본 위치.
+func DefaultCacheDir() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "vertex", "embeddings"), nil
+}
+
+func (c *DiskCache) path(k Key) string {
+	name := k.String()
+	// 한 디렉터리에 파일이 너무 많아지지 않도록 키 앞부분으로 나눈다
+	return filepath.Join(c.Dir, name[:2], name+".f32")
+}
+
+// Get 은 Store 를 구현한다. 깨진 파일은 없는 것으로 본다.
+func (c *DiskCache) Get(ctx context.Context, keys []Key) ([][]float32, error) {
+	out := make([][]float32, len(keys))
+	for i, k := range keys {
+		data, err := os.ReadFile(c.path(k))
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(data) == 0 || len(data)%4 != 0 || (k.Dimensionality > 0 && len(data) != 4*k.Dimensionality) {
+			continue
+		}
+		vec := make([]float32, len(data)/4)
+		for j := range vec {
+			vec[j] = math.Float32frombits(binary.LittleEndian.Uint32(data[4*j:]))
+		}
+		out[i] = vec
+	}
+	return out, nil
+}
+
+// Put 은 Store 를 구현한다. 임시 파일에 쓴 뒤 이름을 바꾸므로 동시에 써도 깨진 파일이 남지 않는다.
+func (c *DiskCache) Put(ctx context.Context, keys []Key, vecs [][]float32) error {
+	for i, k := range keys {
+		data := make([]byte, 4*len(vecs[i]))
+		for j, x := range vecs[i] {
+			binary.LittleEndian.PutUint32(data[4*j:], math.Float32bits(x))
+		}
+		path := c.path(k)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			return err
+		}
+		tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+		if err != nil {
+			return err
+		}
+		_, werr := tmp.Write(data)
+		cerr := tmp.Close()
+		if err := errors.Join(werr, cerr); err != nil {
+			os.Remove(tmp.Name())
+			return err
+		}
+		if err := os.Rename(tmp.Name(), path); err != nil {
+			os.Remove(tmp.Name())
+			return err
+		}
+	}
+	return nil
+}
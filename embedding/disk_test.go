@@ -0,0 +1,43 @@
+package embedding
+
+import (
+	"context"
+	"os"
+	"slices"
+	"testing"
+)
+
+func TestDiskCache(t *testing.T) {
+	ctx := context.Background()
+	c, err := NewDiskCache(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := NewKey("m", RetrievalDocument, 3, "a")
+	b := NewKey("m", RetrievalDocument, 3, "b")
+	if err := c.Put(ctx, []Key{a}, [][]float32{{0.25, -1, 3.5}}); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := c.Get(ctx, []Key{a, b})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(got[0], []float32{0.25, -1, 3.5}) || got[1] != nil {
+		t.Errorf("Get() = %v", got)
+	}
+
+	// 새 DiskCache 로 열어도 남아 있다
+	again := &DiskCache{Dir: c.Dir}
+	if got, _ := again.Get(ctx, []Key{a}); got[0] == nil {
+		t.Error("vector not persisted")
+	}
+
+	// 차원이 맞지 않는 파일은 없는 것으로 본다
+	if err := os.WriteFile(c.path(b), []byte{1, 2, 3, 4}, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := c.Get(ctx, []Key{b}); got[0] != nil {
+		t.Errorf("corrupt entry returned %v", got[0])
+	}
+}
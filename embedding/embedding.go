@@ -0,0 +1,64 @@
+// Package embedding 은 텍스트를 벡터로 바꾸는 Embedder 와 Vertex AI 구현.
+package embedding
+
+import (
+	"context"
+	"fmt"
+	"math"
+)
+
+// TaskType 은 임베딩을 어디에 쓸지 모델에 알려준다.
+type TaskType string
+
+const (
+	RetrievalDocument  TaskType = "RETRIEVAL_DOCUMENT"
+	RetrievalQuery     TaskType = "RETRIEVAL_QUERY"
+	SemanticSimilarity TaskType = "SEMANTIC_SIMILARITY"
+	Classification     TaskType = "CLASSIFICATION"
+	Clustering         TaskType = "CLUSTERING"
+)
+
+// 설정에서 Embedder 구현을 고를 때 쓰는 이름.
+const (
+	ProviderVertex = "vertex"
+	ProviderLocal  = "local"
+)
+
+// Embedder 는 texts 를 같은 순서의 벡터들로 바꾼다.
+type Embedder interface {
+	Embed(ctx context.Context, texts []string, taskType TaskType) ([][]float32, error)
+}
+
+// Embedding 은 벡터 하나와 모델이 알려준 통계.
+type Embedding struct {
+	Values []float32
+	// TokenCount 는 입력 텍스트의 토큰 수.
+	TokenCount int
+	// Truncated 는 입력이 모델의 토큰 한도를 넘어 잘린 채로 임베딩되었는지 여부.
+	Truncated bool
+}
+
+// TruncatedError 는 일부 입력이 잘린 채로 임베딩되었음을 알린다.
+// 벡터는 함께 반환되므로, 잘린 결과를 써도 되는 호출자는 errors.As 로 확인한 뒤 계속할 수 있다.
+type TruncatedError struct {
+	// Indexes 는 잘린 입력의 위치.
+	Indexes []int
+}
+
+func (e *TruncatedError) Error() string {
+	return fmt.Sprintf("embedding: %d input(s) truncated at the model token limit (indexes %v)", len(e.Indexes), e.Indexes)
+}
+
+// Cosine 은 두 벡터의 코사인 유사도. 어느 한쪽의 길이가 0 이면 0.
+func Cosine(a, b []float32) float32 {
+	var dot, normA, normB float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		normA += float64(a[i]) * float64(a[i])
+		normB += float64(b[i]) * float64(b[i])
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return float32(dot / (math.Sqrt(normA) * math.Sqrt(normB)))
+}
@@ -0,0 +1,23 @@
+package embedding
+
+import (
+	"math"
+	"testing"
+)
+
+func TestCosine(t *testing.T) {
+	tests := []struct {
+		a, b []float32
+		want float32
+	}{
+		{[]float32{1, 0}, []float32{1, 0}, 1},
+		{[]float32{1, 0}, []float32{0, 2}, 0},
+		{[]float32{1, 1}, []float32{-1, -1}, -1},
+		{[]float32{0, 0}, []float32{1, 1}, 0},
+	}
+	for _, tt := range tests {
+		if got := Cosine(tt.a, tt.b); math.Abs(float64(got-tt.want)) > 1e-6 {
+			t.Errorf("Cosine(%v, %v) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package embedding
+
+import (
+	"context"
+	"hash/fnv"
+	"math"
+	"strings"
+	"unicode"
+)
+
+// LocalModel 은 Local 임베딩을 캐시 키 등에서 가리키는 모델 이름.
+const LocalModel = "local-ngram"
+
+// Local 은 API 없이 동작하는 결정적 Embedder.
+//
+// 텍스트를 단어로 나눈 뒤 단어 경계를 표시한 글자 n-gram 들을 해시해 Dimensionality 차원에
+// 더하고(feature hashing) 길이 1 로 정규화한다. 한국어는 조사가 붙어도("AI는", "AI로")
+// 어간 쪽 n-gram 이 겹치므로 같은 말을 쓰는 문장끼리 가깝게 나온다.
+// 의미를 이해하지는 못하므로 검색 품질은 Vertex 보다 낮으며, 개발과 테스트용이다.
+type Local struct {
+	// Dimensionality 는 출력 차원. 0 이면 256.
+	Dimensionality int
+	// MinN, MaxN 은 글자 n-gram 의 길이 범위. 0 이면 각각 2, 3.
+	MinN, MaxN int
+}
+
+// NewLocal 은 dimensionality 차원의 Local 을 만든다.
+func NewLocal(dimensionality int) *Local {
+	return &Local{Dimensionality: dimensionality}
+}
+
+// Embed 는 Embedder 를 구현한다. taskType 은 결과에 영향을 주지 않는다.
+func (l *Local) Embed(ctx context.Context, texts []string, taskType TaskType) ([][]float32, error) {
+	out := make([][]float32, len(texts))
+	for i, text := range texts {
+		out[i] = l.vector(text)
+	}
+	return out, nil
+}
+
+func (l *Local) vector(text string) []float32 {
+	dim, minN, maxN := l.Dimensionality, l.MinN, l.MaxN
+	if dim <= 0 {
+		dim = 256
+	}
+	if minN <= 0 {
+		minN = 2
+	}
+	if maxN < minN {
+		maxN = max(3, minN)
+	}
+
+	acc := make([]float64, dim)
+	add := func(feature string, weight float64) {
+		h := fnv.New64a()
+		h.Write([]byte(feature))
+		sum := h.Sum64()
+		// 아래 비트로 위치, 위 비트로 부호를 정해 충돌이 한쪽으로 쌓이지 않게 한다
+		if sum>>63 == 1 {
+			weight = -weight
+		}
+		acc[sum%uint64(dim)] += weight
+	}
+	for _, word := range words(text) {
+		add("w:"+word, 1)
+		runes := []rune("<" + word + ">")
+		for n := minN; n <= maxN; n++ {
+			for i := 0; i+n <= len(runes); i++ {
+				add(string(runes[i:i+n]), 1/float64(n))
+			}
+		}
+	}
+
+	var norm float64
+	for _, x := range acc {
+		norm += x * x
+	}
+	vec := make([]float32, dim)
+	if norm == 0 {
+		return vec
+	}
+	norm = math.Sqrt(norm)
+	for i, x := range acc {
+		vec[i] = float32(x / norm)
+	}
+	return vec
+}
+
+// words 는 글자와 숫자로 된 단어들을 소문자로 돌려준다.
+func words(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
@@ -0,0 +1,66 @@
+package embedding
+
+import (
+	"context"
+	"math"
+	"slices"
+	"testing"
+)
+
+func TestLocal(t *testing.T) {
+	ctx := context.Background()
+	l := NewLocal(256)
+
+	docs := []string{
+		"Vertex AI는 Google Cloud의 ML 플랫폼입니다",
+		"RAG는 검색과 생성을 결합한 AI 접근법",
+		"오늘 서울의 날씨는 맑고 기온은 20도입니다",
+	}
+	vecs, err := l.Embed(ctx, docs, RetrievalDocument)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, v := range vecs {
+		var norm float64
+		for _, x := range v {
+			norm += float64(x) * float64(x)
+		}
+		if len(v) != 256 || math.Abs(norm-1) > 1e-5 {
+			t.Errorf("vector %d: len %d, norm %v", i, len(v), norm)
+		}
+	}
+
+	// 결정적이어야 한다
+	again, _ := l.Embed(ctx, docs[:1], RetrievalQuery)
+	if !slices.Equal(again[0], vecs[0]) {
+		t.Error("Local is not deterministic")
+	}
+
+	// 조사가 달라도 같은 낱말을 쓰는 문서가 가장 가깝다
+	tests := []struct {
+		query string
+		want  int
+	}{
+		{"Google Cloud 플랫폼으로 ML 하기", 0},
+		{"검색과 생성 결합", 1},
+		{"서울 날씨 어때?", 2},
+	}
+	for _, tt := range tests {
+		q, _ := l.Embed(ctx, []string{tt.query}, RetrievalQuery)
+		best, bestScore := -1, float32(-2)
+		for i, v := range vecs {
+			if s := Cosine(q[0], v); s > bestScore {
+				best, bestScore = i, s
+			}
+		}
+		if best != tt.want {
+			t.Errorf("query %q nearest doc = %d, want %d", tt.query, best, tt.want)
+		}
+	}
+
+	// 글자가 없는 텍스트는 영벡터
+	empty, _ := l.Embed(ctx, []string{"  ?! "}, RetrievalQuery)
+	if slices.ContainsFunc(empty[0], func(x float32) bool { return x != 0 }) {
+		t.Errorf("empty text vector = %v", empty[0])
+	}
+}
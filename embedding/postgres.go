@@ -0,0 +1,78 @@
+package embedding
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// DefaultPGCacheTable 은 PGCache 가 쓰는 기본 테이블 이름.
+const DefaultPGCacheTable = "embedding_cache"
+
+// PGCache 는 벡터를 PostgreSQL 테이블에 저장하는 Store.
+// 차원이 모델 설정에 따라 달라지므로 pgvector 타입 대신 real[] 로 저장한다.
+type PGCache struct {
+	DB    *pgxpool.Pool
+	Table string
+}
+
+// NewPGCache 는 테이블이 없으면 만들고 PGCache 를 돌려준다.
+func NewPGCache(ctx context.Context, db *pgxpool.Pool) (*PGCache, error) {
+	c := &PGCache{DB: db, Table: DefaultPGCacheTable}
+	_, err := db.Exec(ctx, fmt.Sprintf(`
+		CREATE TABLE IF NOT EXISTS %s (
+			key TEXT PRIMARY KEY,
+			embedding REAL[] NOT NULL,
+			created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+		)
+	`, pgx.Identifier{c.Table}.Sanitize()))
+	if err != nil {
+		return nil, fmt.Errorf("embedding cache: create table: %w", err)
+	}
+	return c, nil
+}
+
+// Get 은 Store 를 구현한다.
+func (c *PGCache) Get(ctx context.Context, keys []Key) ([][]float32, error) {
+	names := make([]string, len(keys))
+	at := make(map[string][]int, len(keys))
+	for i, k := range keys {
+		names[i] = k.String()
+		at[names[i]] = append(at[names[i]], i)
+	}
+	rows, err := c.DB.Query(ctx,
+		fmt.Sprintf("SELECT key, embedding FROM %s WHERE key = ANY($1)", pgx.Identifier{c.Table}.Sanitize()),
+		names)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make([][]float32, len(keys))
+	for rows.Next() {
+		var (
+			key string
+			vec []float32
+		)
+		if err := rows.Scan(&key, &vec); err != nil {
+			return nil, err
+		}
+		for _, i := range at[key] {
+			out[i] = vec
+		}
+	}
+	return out, rows.Err()
+}
+
+// Put 은 Store 를 구현한다.
+func (c *PGCache) Put(ctx context.Context, keys []Key, vecs [][]float32) error {
+	query := fmt.Sprintf("INSERT INTO %s (key, embedding) VALUES ($1, $2) ON CONFLICT (key) DO UPDATE SET embedding = EXCLUDED.embedding",
+		pgx.Identifier{c.Table}.Sanitize())
+	batch := &pgx.Batch{}
+	for i, k := range keys {
+		batch.Queue(query, k.String(), vecs[i])
+	}
+	return c.DB.SendBatch(ctx, batch).Close()
+}
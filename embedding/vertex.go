@@ -0,0 +1,268 @@
+package embedding
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"unicode/utf8"
+
+	"cloud.google.com/go/aiplatform/apiv1/aiplatformpb"
+	"github.com/googleapis/gax-go/v2"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// Predictor 는 Vertex 가 쓰는 예측 API. *aiplatform.PredictionClient 가 만족한다.
+type Predictor interface {
+	Predict(ctx context.Context, req *aiplatformpb.PredictRequest, opts ...gax.CallOption) (*aiplatformpb.PredictResponse, error)
+}
+
+// ErrMalformedPrediction 은 예측 결과가 텍스트 임베딩 모델의 응답 형식이 아닐 때의 오류.
+var ErrMalformedPrediction = errors.New("embedding: malformed prediction")
+
+// 텍스트 임베딩 모델의 요청당 한도. text-multilingual-embedding-002 기준.
+const (
+	// DefaultMaxBatchSize 는 요청 하나에 넣을 수 있는 최대 텍스트 수.
+	DefaultMaxBatchSize = 250
+	// DefaultMaxBatchTokens 는 요청 하나의 최대 입력 토큰 수.
+	DefaultMaxBatchTokens = 20000
+	// MaxInputTokens 는 텍스트 하나의 최대 토큰 수. 넘는 부분은 모델이 잘라낸다.
+	MaxInputTokens = 2048
+	// DefaultConcurrency 는 동시에 보내는 요청 수의 기본값.
+	DefaultConcurrency = 4
+)
+
+// Vertex 는 text-multilingual-embedding-002 같은 Vertex AI 텍스트 임베딩 모델을 쓰는 Embedder.
+//
+// 텍스트들은 MaxBatchSize 개, 추정 MaxBatchTokens 토큰 이하의 배치로 묶여 Concurrency 개씩
+// 동시에 요청된다. 추정이 빗나가 모델이 배치를 거부하면 배치를 반으로 나눠 다시 보낸다.
+type Vertex struct {
+	Client Predictor
+	// Endpoint 는 projects/{p}/locations/{l}/publishers/google/models/{model} 형식의 모델 이름.
+	Endpoint string
+	// Dimensionality 가 0 보다 크면 outputDimensionality 로 전달한다.
+	Dimensionality int
+
+	// MaxBatchSize 는 요청 하나에 넣을 텍스트 수 한도. 0 이면 DefaultMaxBatchSize.
+	MaxBatchSize int
+	// MaxBatchTokens 는 요청 하나의 추정 토큰 수 한도. 0 이면 DefaultMaxBatchTokens.
+	MaxBatchTokens int
+	// Concurrency 는 동시에 보낼 요청 수. 0 이면 DefaultConcurrency.
+	Concurrency int
+	// EstimateTokens 는 텍스트의 토큰 수를 추정한다. nil 이면 EstimateTokens 함수를 쓴다.
+	EstimateTokens func(text string) int
+}
+
+// NewVertex 는 project, location 의 model 을 쓰는 Vertex 를 만든다.
+func NewVertex(client Predictor, project, location, model string, dimensionality int) *Vertex {
+	return &Vertex{
+		Client:         client,
+		Endpoint:       ModelEndpoint(project, location, model),
+		Dimensionality: dimensionality,
+	}
+}
+
+// ModelEndpoint 는 Google 이 제공하는 모델의 리소스 이름.
+func ModelEndpoint(project, location, model string) string {
+	return "projects/" + project + "/locations/" + location + "/publishers/google/models/" + model
+}
+
+// EstimateTokens 는 토크나이저 없이 토큰 수를 넉넉하게 추정한다.
+// 한글은 대략 글자당 한 토큰, 영문은 네 글자당 한 토큰 정도이므로 그 사이를 잡는다.
+func EstimateTokens(text string) int {
+	var n int
+	for _, r := range text {
+		if r < utf8.RuneSelf {
+			n++
+		} else {
+			n += 4
+		}
+	}
+	return (n + 3) / 4
+}
+
+// Embed 는 Embedder 를 구현한다. 잘린 입력이 있으면 벡터와 함께 *TruncatedError 를 돌려준다.
+func (v *Vertex) Embed(ctx context.Context, texts []string, taskType TaskType) ([][]float32, error) {
+	embs, err := v.EmbedWithStats(ctx, texts, taskType)
+	if embs == nil {
+		return nil, err
+	}
+	out := make([][]float32, len(embs))
+	for i, e := range embs {
+		out[i] = e.Values
+	}
+	return out, err
+}
+
+// EmbedWithStats 는 texts 를 배치로 나눠 임베딩하고 토큰 통계도 함께 돌려준다.
+// 잘린 입력이 있으면 결과와 함께 *TruncatedError 를 돌려준다.
+func (v *Vertex) EmbedWithStats(ctx context.Context, texts []string, taskType TaskType) ([]Embedding, error) {
+	if len(texts) == 0 {
+		return nil, nil
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	embs := make([]Embedding, len(texts))
+	batches := v.batches(texts)
+	workers := v.Concurrency
+	if workers <= 0 {
+		workers = DefaultConcurrency
+	}
+	sem := make(chan struct{}, min(workers, len(batches)))
+
+	var (
+		wg       sync.WaitGroup
+		failOnce sync.Once
+		failErr  error
+	)
+	for _, b := range batches {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			select {
+			case sem <- struct{}{}:
+				defer func() { <-sem }()
+			case <-ctx.Done():
+				return
+			}
+			if err := v.embedBatch(ctx, texts[b.lo:b.hi], taskType, embs[b.lo:b.hi]); err != nil {
+				failOnce.Do(func() {
+					failErr = fmt.Errorf("texts %d-%d: %w", b.lo, b.hi-1, err)
+					cancel()
+				})
+			}
+		}()
+	}
+	wg.Wait()
+	if failErr != nil {
+		return nil, failErr
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	var truncated []int
+	for i, e := range embs {
+		if e.Truncated {
+			truncated = append(truncated, i)
+		}
+	}
+	if truncated != nil {
+		return embs, &TruncatedError{Indexes: truncated}
+	}
+	return embs, nil
+}
+
+// span 은 texts[lo:hi] 배치.
+type span struct{ lo, hi int }
+
+// batches 는 texts 를 순서대로 개수와 추정 토큰 한도 안의 배치로 나눈다.
+func (v *Vertex) batches(texts []string) []span {
+	maxSize, maxTokens := v.MaxBatchSize, v.MaxBatchTokens
+	if maxSize <= 0 {
+		maxSize = DefaultMaxBatchSize
+	}
+	if maxTokens <= 0 {
+		maxTokens = DefaultMaxBatchTokens
+	}
+	estimate := v.EstimateTokens
+	if estimate == nil {
+		estimate = EstimateTokens
+	}
+
+	var out []span
+	lo, tokens := 0, 0
+	for i, text := range texts {
+		// 한도를 넘는 텍스트는 모델이 잘라내므로 MaxInputTokens 만큼만 센다
+		n := min(estimate(text), MaxInputTokens)
+		if i > lo && (i-lo == maxSize || tokens+n > maxTokens) {
+			out = append(out, span{lo, i})
+			lo, tokens = i, 0
+		}
+		tokens += n
+	}
+	return append(out, span{lo, len(texts)})
+}
+
+// embedBatch 는 texts 를 한 번의 predict 요청으로 임베딩해 out 에 채운다.
+// 모델이 InvalidArgument 로 거부하면 먼저 첫 텍스트만 따로 보내 본다. 그것마저 거부되면
+// 배치 크기가 아니라 모델 이름이나 차원 같은 영구적인 문제이므로 바로 실패하고,
+// 성공하면 나머지를 반으로 나눠 다시 시도한다.
+func (v *Vertex) embedBatch(ctx context.Context, texts []string, taskType TaskType, out []Embedding) error {
+	if len(texts) == 0 {
+		return nil
+	}
+	resp, err := v.Client.Predict(ctx, v.request(texts, taskType))
+	if status.Code(err) == codes.InvalidArgument && len(texts) > 1 {
+		if err := v.embedBatch(ctx, texts[:1], taskType, out[:1]); err != nil {
+			return err
+		}
+		half := 1 + len(texts[1:])/2
+		if err := v.embedBatch(ctx, texts[1:half], taskType, out[1:half]); err != nil {
+			return err
+		}
+		return v.embedBatch(ctx, texts[half:], taskType, out[half:])
+	}
+	if err != nil {
+		return fmt.Errorf("embedding: predict: %w", err)
+	}
+	if len(resp.GetPredictions()) != len(texts) {
+		return fmt.Errorf("%w: got %d predictions for %d texts", ErrMalformedPrediction, len(resp.GetPredictions()), len(texts))
+	}
+	for i, p := range resp.GetPredictions() {
+		if out[i], err = ParsePrediction(p); err != nil {
+			return fmt.Errorf("prediction %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+func (v *Vertex) request(texts []string, taskType TaskType) *aiplatformpb.PredictRequest {
+	instances := make([]*structpb.Value, len(texts))
+	for i, text := range texts {
+		fields := map[string]*structpb.Value{"content": structpb.NewStringValue(text)}
+		if taskType != "" {
+			fields["task_type"] = structpb.NewStringValue(string(taskType))
+		}
+		instances[i] = structpb.NewStructValue(&structpb.Struct{Fields: fields})
+	}
+	req := &aiplatformpb.PredictRequest{Endpoint: v.Endpoint, Instances: instances}
+	if v.Dimensionality > 0 {
+		req.Parameters = structpb.NewStructValue(&structpb.Struct{Fields: map[string]*structpb.Value{
+			"outputDimensionality": structpb.NewNumberValue(float64(v.Dimensionality)),
+		}})
+	}
+	return req
+}
+
+// ParsePrediction 은 텍스트 임베딩 모델의 prediction 하나를 읽는다. 모델은
+//
+//	{"embeddings": {"values": [...], "statistics": {"token_count": n, "truncated": false}}}
+//
+// 형식으로 응답한다.
+func ParsePrediction(p *structpb.Value) (Embedding, error) {
+	embeddings := p.GetStructValue().GetFields()["embeddings"].GetStructValue()
+	if embeddings == nil {
+		return Embedding{}, fmt.Errorf("%w: no embeddings object", ErrMalformedPrediction)
+	}
+	values := embeddings.GetFields()["values"].GetListValue().GetValues()
+	if len(values) == 0 {
+		return Embedding{}, fmt.Errorf("%w: embeddings.values is empty or not a list", ErrMalformedPrediction)
+	}
+
+	e := Embedding{Values: make([]float32, len(values))}
+	for i, x := range values {
+		n, ok := x.GetKind().(*structpb.Value_NumberValue)
+		if !ok {
+			return Embedding{}, fmt.Errorf("%w: embeddings.values[%d] is not a number", ErrMalformedPrediction, i)
+		}
+		e.Values[i] = float32(n.NumberValue)
+	}
+	stats := embeddings.GetFields()["statistics"].GetStructValue().GetFields()
+	e.TokenCount = int(stats["token_count"].GetNumberValue())
+	e.Truncated = stats["truncated"].GetBoolValue()
+	return e, nil
+}
@@ -0,0 +1,226 @@
+package embedding
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	aiplatform "cloud.google.com/go/aiplatform/apiv1"
+	"cloud.google.com/go/aiplatform/apiv1/aiplatformpb"
+	"github.com/googleapis/gax-go/v2"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
+
+	"vertex/vertextest"
+)
+
+func TestVertexEmbed(t *testing.T) {
+	srv, err := vertextest.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Close()
+	ctx := context.Background()
+	client, err := aiplatform.NewPredictionClient(ctx, srv.ClientOptions()...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	v := NewVertex(client, "test-project", "us-central1", "text-multilingual-embedding-002", 16)
+	texts := []string{"Vertex AI 플랫폼", "검색과 생성을 결합"}
+	embs, err := v.EmbedWithStats(ctx, texts, RetrievalDocument)
+	if err != nil {
+		t.Fatalf("EmbedWithStats() error = %v", err)
+	}
+	for i, e := range embs {
+		want := vertextest.FakeEmbedding(texts[i], 16)
+		if len(e.Values) != 16 || e.Values[0] != want[0] || e.Values[15] != want[15] {
+			t.Errorf("embedding %d = %v, want %v", i, e.Values, want)
+		}
+		if e.TokenCount != 3 || e.Truncated {
+			t.Errorf("embedding %d stats = (%d, %v)", i, e.TokenCount, e.Truncated)
+		}
+	}
+
+	// 요청 형식: 한 번의 요청에 텍스트마다 인스턴스 하나
+	req := srv.PredictRequests()[0]
+	if req.GetEndpoint() != "projects/test-project/locations/us-central1/publishers/google/models/text-multilingual-embedding-002" {
+		t.Errorf("endpoint = %q", req.GetEndpoint())
+	}
+	inst := req.GetInstances()[1].GetStructValue().GetFields()
+	if len(req.GetInstances()) != 2 || inst["content"].GetStringValue() != texts[1] || inst["task_type"].GetStringValue() != "RETRIEVAL_DOCUMENT" {
+		t.Errorf("instances = %v", req.GetInstances())
+	}
+	if dim := req.GetParameters().GetStructValue().GetFields()["outputDimensionality"].GetNumberValue(); dim != 16 {
+		t.Errorf("outputDimensionality = %v", dim)
+	}
+}
+
+// stubPredictor 는 정해진 prediction 들을 돌려준다.
+type stubPredictor []*structpb.Value
+
+func (s stubPredictor) Predict(ctx context.Context, req *aiplatformpb.PredictRequest, opts ...gax.CallOption) (*aiplatformpb.PredictResponse, error) {
+	return &aiplatformpb.PredictResponse{Predictions: s}, nil
+}
+
+func TestVertexEmbedErrors(t *testing.T) {
+	bareList := structpb.NewListValue(&structpb.ListValue{Values: []*structpb.Value{structpb.NewNumberValue(0.5)}})
+	tests := []struct {
+		name          string
+		predictions   stubPredictor
+		texts         []string
+		wantMalformed bool
+		wantTruncated []int
+	}{
+		{
+			name:          "bare list",
+			predictions:   stubPredictor{bareList},
+			texts:         []string{"a"},
+			wantMalformed: true,
+		},
+		{
+			name:          "count mismatch",
+			predictions:   stubPredictor{vertextest.EmbeddingPrediction([]float32{1}, 1, false)},
+			texts:         []string{"a", "b"},
+			wantMalformed: true,
+		},
+		{
+			name:          "empty values",
+			predictions:   stubPredictor{vertextest.EmbeddingPrediction(nil, 1, false)},
+			texts:         []string{"a"},
+			wantMalformed: true,
+		},
+		{
+			name: "truncated",
+			predictions: stubPredictor{
+				vertextest.EmbeddingPrediction([]float32{1, 0}, 1, false),
+				vertextest.EmbeddingPrediction([]float32{0, 1}, 2048, true),
+			},
+			texts:         []string{"short", "long"},
+			wantTruncated: []int{1},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := &Vertex{Client: tt.predictions, Endpoint: "projects/p/locations/l/publishers/google/models/m"}
+			vecs, err := v.Embed(context.Background(), tt.texts, RetrievalQuery)
+			if got := errors.Is(err, ErrMalformedPrediction); got != tt.wantMalformed {
+				t.Errorf("Embed() error = %v, want malformed %v", err, tt.wantMalformed)
+			}
+			var terr *TruncatedError
+			if tt.wantTruncated != nil {
+				if !errors.As(err, &terr) || len(terr.Indexes) != 1 || terr.Indexes[0] != tt.wantTruncated[0] {
+					t.Errorf("Embed() error = %v, want truncated %v", err, tt.wantTruncated)
+				}
+				if len(vecs) != len(tt.texts) {
+					t.Errorf("Embed() returned %d vectors with TruncatedError, want %d", len(vecs), len(tt.texts))
+				}
+			}
+		})
+	}
+}
+
+// limitPredictor 는 maxInstances 개를 넘는 요청을 거부하고 동시 요청 수를 기록한다.
+type limitPredictor struct {
+	maxInstances int
+
+	mu          sync.Mutex
+	inFlight    int
+	maxInFlight int
+	requests    int
+	rejected    int
+}
+
+func (p *limitPredictor) Predict(ctx context.Context, req *aiplatformpb.PredictRequest, opts ...gax.CallOption) (*aiplatformpb.PredictResponse, error) {
+	p.mu.Lock()
+	p.requests++
+	p.inFlight++
+	p.maxInFlight = max(p.maxInFlight, p.inFlight)
+	p.mu.Unlock()
+	defer func() {
+		p.mu.Lock()
+		p.inFlight--
+		p.mu.Unlock()
+	}()
+	time.Sleep(10 * time.Millisecond)
+
+	if len(req.GetInstances()) > p.maxInstances {
+		p.mu.Lock()
+		p.rejected++
+		p.mu.Unlock()
+		return nil, status.Error(codes.InvalidArgument, "too many tokens in request")
+	}
+	return vertextest.DefaultPredict(req)
+}
+
+func TestVertexBatching(t *testing.T) {
+	texts := make([]string, 23)
+	for i := range texts {
+		texts[i] = fmt.Sprintf("문서 %d", i)
+	}
+	p := &limitPredictor{maxInstances: 3}
+	v := &Vertex{
+		Client:         p,
+		Endpoint:       "projects/p/locations/l/publishers/google/models/m",
+		Dimensionality: 8,
+		MaxBatchSize:   5,
+		Concurrency:    2,
+	}
+	vecs, err := v.Embed(context.Background(), texts, RetrievalDocument)
+	if err != nil {
+		t.Fatalf("Embed() error = %v", err)
+	}
+	for i, vec := range vecs {
+		if want := vertextest.FakeEmbedding(texts[i], 8); vec[0] != want[0] || vec[7] != want[7] {
+			t.Errorf("vector %d does not belong to %q", i, texts[i])
+		}
+	}
+	// 5개짜리 배치 4개는 거부되어 첫 텍스트 하나와 2+2 로 나뉘고, 마지막 3개짜리 배치는 그대로 성공
+	if p.rejected != 4 || p.requests != 4*4+1 {
+		t.Errorf("requests = %d (rejected %d), want 17 (rejected 4)", p.requests, p.rejected)
+	}
+	if p.maxInFlight > 2 {
+		t.Errorf("max in-flight requests = %d, want <= 2", p.maxInFlight)
+	}
+}
+
+// TestVertexPermanentInvalidArgument 는 텍스트 하나도 거부되면 배치를 더 나누지 않고 실패하는지 확인한다.
+func TestVertexPermanentInvalidArgument(t *testing.T) {
+	p := &limitPredictor{maxInstances: 0}
+	v := &Vertex{Client: p, Endpoint: "projects/p/locations/l/publishers/google/models/m", MaxBatchSize: 8}
+	texts := []string{"a", "b", "c", "d", "e", "f", "g", "h"}
+	if _, err := v.Embed(context.Background(), texts, RetrievalDocument); status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("Embed() error = %v, want InvalidArgument", err)
+	}
+	if p.requests != 2 {
+		t.Errorf("requests = %d, want 2 (batch and one single-text probe)", p.requests)
+	}
+}
+
+func TestBatches(t *testing.T) {
+	tokens := func(s string) int { return len(s) }
+	tests := []struct {
+		name  string
+		v     Vertex
+		texts []string
+		want  []span
+	}{
+		{"size limit", Vertex{MaxBatchSize: 2, EstimateTokens: tokens}, []string{"a", "b", "c", "d", "e"}, []span{{0, 2}, {2, 4}, {4, 5}}},
+		{"token limit", Vertex{MaxBatchTokens: 5, EstimateTokens: tokens}, []string{"aa", "bbb", "c", "dddd", "e"}, []span{{0, 2}, {2, 4}, {4, 5}}},
+		{"oversize text alone", Vertex{MaxBatchTokens: 3, EstimateTokens: tokens}, []string{"a", "bbbbbb", "c"}, []span{{0, 1}, {1, 2}, {2, 3}}},
+		{"single batch", Vertex{}, []string{"a", "b"}, []span{{0, 2}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.v.batches(tt.texts)
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("batches() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -6,8 +6,13 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"os"
 
 	"cloud.google.com/go/vertexai/genai"
+	"google.golang.org/api/option"
+
+	"vertex/prompt"
+	"vertex/tools"
 )
 
 // 실제 함수 1: 제품 SKU 조회
@@ -25,9 +30,33 @@ func getStoreLocation(location string) map[string]any {
 	}
 }
 
-func functionCallsChat(w io.Writer, projectID, location, modelName string) error {
+// 도구 인자 구조체: 스키마와 디코딩 모두 태그에서 만들어진다
+type productSkuArgs struct {
+	ProductName string `json:"productName" description:"Product name" schema:"required"`
+}
+
+type storeLocationArgs struct {
+	Location string `json:"location" description:"Location" schema:"required"`
+}
+
+// newRegistry: 이 샘플에서 모델에 노출하는 도구 등록
+func newRegistry() *tools.Registry {
+	registry := tools.NewRegistry()
+	registry.MustRegister(tools.MustNewTool("getProductSku", "Get the SKU for a product",
+		func(ctx context.Context, args productSkuArgs) (map[string]any, error) {
+			return getProductSku(args.ProductName), nil
+		}))
+	registry.MustRegister(tools.MustNewTool("getStoreLocation", "Get the location of the closest store",
+		func(ctx context.Context, args storeLocationArgs) (map[string]any, error) {
+			return getStoreLocation(args.Location), nil
+		}))
+	return registry
+}
+
+// opts 는 genai.NewClient 에 그대로 전달된다 (테스트에서 가짜 서버 지정 등).
+func functionCallsChat(w io.Writer, projectID, location, modelName string, opts ...option.ClientOption) error {
 	ctx := context.Background()
-	client, err := genai.NewClient(ctx, projectID, location)
+	client, err := genai.NewClient(ctx, projectID, location, opts...)
 	if err != nil {
 		return fmt.Errorf("unable to create client: %w", err)
 	}
@@ -35,137 +64,99 @@ func functionCallsChat(w io.Writer, projectID, location, modelName string) error
 
 	model := client.GenerativeModel(modelName)
 
-	// 함수 선언
-	paramsProduct := &genai.Schema{
-		Type: genai.TypeObject,
-		Properties: map[string]*genai.Schema{
-			"productName": {
-				Type:        genai.TypeString,
-				Description: "Product name",
-			},
-		},
-	}
-	fundeclProductInfo := &genai.FunctionDeclaration{
-		Name:        "getProductSku",
-		Description: "Get the SKU for a product",
-		Parameters:  paramsProduct,
-	}
+	// 도구 등록: 선언과 핸들러를 레지스트리 한 곳에서 관리
+	registry := newRegistry()
+	model.Tools = registry.Tools()
+	model.SetTemperature(0.0)
 
-	paramsStore := &genai.Schema{
-		Type: genai.TypeObject,
-		Properties: map[string]*genai.Schema{
-			"location": {
-				Type:        genai.TypeString,
-				Description: "Location",
-			},
-		},
+	// 질문 문구, 시스템 지시, 예시는 "function_call" 템플릿에서 가져온다. 버전과 언어는 prompt.EnvVersion, prompt.EnvLanguage 로 고른다
+	prompts, err := prompt.Open(os.Getenv(prompt.EnvDir))
+	if err != nil {
+		return err
 	}
-	fundeclStoreLocation := &genai.FunctionDeclaration{
-		Name:        "getStoreLocation",
-		Description: "Get the location of the closest store",
-		Parameters:  paramsStore,
+	tmpl, err := prompts.GetEnv("function_call")
+	if err != nil {
+		return err
 	}
-
-	model.Tools = []*genai.Tool{
-		{FunctionDeclarations: []*genai.FunctionDeclaration{
-			fundeclProductInfo,
-			fundeclStoreLocation,
-		}},
+	// 시스템 지시와 예시는 질문과 상관없으므로 대화를 시작할 때 한 번만 넣는다
+	intro, err := tmpl.Render(questionData{})
+	if err != nil {
+		return err
 	}
-	model.SetTemperature(0.0)
-
+	intro.Apply(model)
 	chat := model.StartChat()
+	chat.History = intro.History()
 
 	// 1. 첫 번째 질문: 제품 재고 확인
-	prompt := "Do you have the Pixel 8 Pro in stock?"
-	fmt.Fprintf(w, "Question: %s\n", prompt)
-	if err := processChatMessage(w, chat, ctx, prompt); err != nil {
+	question := "Do you have the Pixel 8 Pro in stock?"
+	fmt.Fprintf(w, "Question: %s\n", question)
+	if err := processChatMessage(w, chat, registry, ctx, tmpl, question); err != nil {
 		return err
 	}
 
 	// 2. 두 번째 질문: 매장 위치 확인
-	prompt2 := "Is there a store in Mountain View, CA that I can visit to try it out?"
-	fmt.Fprintf(w, "Question: %s\n", prompt2)
-	if err := processChatMessage(w, chat, ctx, prompt2); err != nil {
+	question2 := "Is there a store in Mountain View, CA that I can visit to try it out?"
+	fmt.Fprintf(w, "Question: %s\n", question2)
+	if err := processChatMessage(w, chat, registry, ctx, tmpl, question2); err != nil {
+		return err
+	}
+
+	// 3. 연쇄 호출: SKU 조회 후 매장 위치 조회
+	question3 := "Find the SKU of the Pixel 8 Pro and then the closest store in Mountain View, CA where I can buy it."
+	fmt.Fprintf(w, "Question: %s\n", question3)
+	if err := processChatMessage(w, chat, registry, ctx, tmpl, question3); err != nil {
 		return err
 	}
 
-	// 3.
-	prompt3 := "Explain History of Tokyo?"
-	fmt.Fprintf(w, "Question: %s\n", prompt3)
-	if err := processChatMessage(w, chat, ctx, prompt3); err != nil {
+	// 4.
+	question4 := "Explain History of Tokyo?"
+	fmt.Fprintf(w, "Question: %s\n", question4)
+	if err := processChatMessage(w, chat, registry, ctx, tmpl, question4); err != nil {
 		return err
 	}
 
 	return nil
 }
 
-// processChatMessage: 응답 타입에 따라 함수 호출 또는 일반 답변 처리
-func processChatMessage(w io.Writer, chat *genai.ChatSession, ctx context.Context, prompt string) error {
-	resp, err := chat.SendMessage(ctx, genai.Text(prompt))
+// questionData 는 "function_call" 템플릿에 넘기는 값.
+type questionData struct {
+	Question string
+}
+
+// processChatMessage: 질문을 템플릿으로 채워 보내고, 모델이 텍스트로 답할 때까지 함수 호출을 반복 처리
+func processChatMessage(w io.Writer, chat *genai.ChatSession, registry *tools.Registry, ctx context.Context, tmpl *prompt.Template, question string) error {
+	rendered, err := tmpl.Render(questionData{Question: question})
 	if err != nil {
 		return err
 	}
-	if len(resp.Candidates) == 0 || len(resp.Candidates[0].Content.Parts) == 0 {
-		return errors.New("empty response from model")
+	calls := 0
+	loop := &tools.Loop{
+		Registry: registry, // MaxSteps 0: tools.DefaultMaxSteps
+		OnCall: func(call genai.FunctionCall, funresp *genai.FunctionResponse) {
+			calls++
+			jsondata, _ := json.MarshalIndent(funresp.Response, "", "  ")
+			fmt.Fprintf(w, "function call response sent to the model:\n\t%s\n\n", string(jsondata))
+		},
+	}
+
+	resp, err := loop.Run(ctx, chat, genai.Text(rendered.User))
+	var callErr *tools.CallError
+	if errors.As(err, &callErr) {
+		// 중단 정책(AbortOnError)인 도구가 실패한 경우
+		// LLM의 원본 응답(함수 호출 객체)을 출력
+		jsondata, _ := json.MarshalIndent(callErr.Call, "", "  ")
+		fmt.Fprintf(w, "Answer generated by the model:\n\t%s\n\n", string(jsondata))
+		return nil
+	}
+	if err != nil {
+		return err
 	}
 
-	part := resp.Candidates[0].Content.Parts[0]
-	switch v := part.(type) {
-	case genai.FunctionCall:
-		// 함수 호출을 처리할 수 있는 핸들러가 있는지 확인
-		var result map[string]any
-		var err error
-		switch v.Name {
-		case "getProductSku":
-			productName, ok := v.Args["productName"].(string)
-			if !ok {
-				err = fmt.Errorf("invalid productName")
-			} else {
-				// 실제 핸들러가 있다면 처리
-				result = getProductSku(productName)
-			}
-		case "getStoreLocation":
-			location, ok := v.Args["location"].(string)
-			if !ok {
-				err = fmt.Errorf("invalid location")
-			} else {
-				// 실제 핸들러가 있다면 처리
-				result = getStoreLocation(location)
-			}
-		default:
-			// 지원하지 않는 함수일 경우
-			err = fmt.Errorf("unsupported function: %s", v.Name)
-		}
-
-		if err != nil {
-			// 함수 호출을 처리할 수 없거나 지원하지 않는 경우
-			// LLM의 원본 응답(함수 호출 객체)을 출력
-			jsondata, _ := json.MarshalIndent(v, "", "  ")
-			fmt.Fprintf(w, "Answer generated by the model:\n\t%s\n\n", string(jsondata))
-			return nil
-		}
-
-		// 함수 호출을 처리할 수 있으면 기존 로직대로 진행
-		jsondata, _ := json.MarshalIndent(result, "", "  ")
-		fmt.Fprintf(w, "function call response sent to the model:\n\t%s\n\n", string(jsondata))
-
-		funresp := &genai.FunctionResponse{
-			Name:     v.Name,
-			Response: result,
-		}
-		resp, err = chat.SendMessage(ctx, funresp)
-		if err != nil {
-			return err
-		}
-		if len(resp.Candidates) == 0 || len(resp.Candidates[0].Content.Parts) == 0 {
-			return errors.New("empty response from model")
-		}
-		jsondata, _ = json.MarshalIndent(resp.Candidates[0].Content.Parts[0], "", "  ")
+	jsondata, _ := json.MarshalIndent(resp.Text, "", "  ")
+	if calls > 0 {
 		fmt.Fprintf(w, "Answer generated by the model(Function):\n\t%s\n\n", string(jsondata))
-	default:
-		// 함수 호출이 아닌 경우 일반 답변 출력
-		jsondata, _ := json.MarshalIndent(part, "", "  ")
+	} else {
+		// 함수 호출이 없었던 경우 일반 답변 출력
 		fmt.Fprintf(w, "Answer generated by the model(LLM):\n\t%s\n\n", string(jsondata))
 	}
 	return nil
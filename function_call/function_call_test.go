@@ -2,25 +2,117 @@ package function_call
 
 import (
 	"bytes"
-	"fmt"
 	"io"
+	"strings"
 	"testing"
+
+	"vertex/cassette"
+	"vertex/vertextest"
 )
 
+// scriptChat 은 functionCallsChat 의 네 질문에 대한 모델 응답을 srv 에 넣는다.
+func scriptChat(srv *vertextest.Server) {
+	sku := vertextest.Call{Name: "getProductSku", Args: map[string]any{"productName": "Pixel 8 Pro"}}
+	store := vertextest.Call{Name: "getStoreLocation", Args: map[string]any{"location": "Mountain View, CA"}}
+	// 1. 재고 확인
+	srv.AddFunctionCalls(sku)
+	srv.AddText("Yes, the Pixel 8 Pro (GA04834-US) is in stock.")
+	// 2. 매장 위치
+	srv.AddFunctionCalls(store)
+	srv.AddText("You can visit 2000 N Shoreline Blvd, Mountain View.")
+	// 3. 연쇄 호출
+	srv.AddFunctionCalls(sku)
+	srv.AddFunctionCalls(store)
+	srv.AddText("GA04834-US is available at 2000 N Shoreline Blvd.")
+	// 4. 함수 호출 없는 일반 답변
+	srv.AddText("Tokyo began as a fishing village named Edo.")
+}
+
 func TestFunctioncall(t *testing.T) {
+	// 가짜 Vertex AI 서버와 질문별 모델 응답 스크립트
+	srv, err := vertextest.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Close()
+
+	scriptChat(srv)
+
 	// 출력 캡처용 버퍼
 	var buf bytes.Buffer
 	w := io.Writer(&buf)
 
 	// 테스트 대상 함수 실행
-	err := functionCallsChat(w, "metanonia-53f36", "us-central1", "gemini-2.0-flash")
+	err = functionCallsChat(w, "metanonia-53f36", "us-central1", "gemini-2.0-flash", srv.ClientOptions()...)
 
 	// 오류 검증
 	if err != nil {
-		t.Errorf("functionCallsChat() error = %v", err)
-		return
+		t.Fatalf("functionCallsChat() error = %v", err)
+	}
+	if srv.Pending() != 0 {
+		t.Errorf("%d scripted replies left unused", srv.Pending())
+	}
+
+	// 출력 검증
+	out := buf.String()
+	for sub, want := range map[string]int{
+		"Question: ": 4,
+		"function call response sent to the model:": 4,
+		`"sku": "GA04834-US"`:                       2,
+		"Answer generated by the model(Function):":  3,
+		"Answer generated by the model(LLM):":       1,
+		"fishing village":                           1,
+	} {
+		if got := strings.Count(out, sub); got != want {
+			t.Errorf("output contains %q %d times, want %d", sub, got, want)
+		}
+	}
+
+	// 요청 검증: 도구 선언과 모델에 돌려보낸 함수 응답
+	reqs := srv.GenerateRequests()
+	if len(reqs) != 8 {
+		t.Fatalf("got %d generateContent requests, want 8", len(reqs))
+	}
+	decls := reqs[0].GetTools()[0].GetFunctionDeclarations()
+	if len(decls) != 2 || decls[0].GetName() != "getProductSku" || decls[1].GetName() != "getStoreLocation" {
+		t.Errorf("declared tools = %v", decls)
+	}
+	last := reqs[1].GetContents()[len(reqs[1].GetContents())-1]
+	funresp := last.GetParts()[0].GetFunctionResponse()
+	if funresp.GetName() != "getProductSku" || funresp.GetResponse().GetFields()["sku"].GetStringValue() != "GA04834-US" {
+		t.Errorf("function response sent = %v", funresp)
+	}
+
+	t.Logf("Captured output:\n%s", out)
+}
+
+// TestFunctioncallReplay 는 카세트에 기록된 모델 응답으로 대화 전체를 재생한다.
+// 프롬프트나 도구 선언이 바뀌면 카세트와 요청이 달라져 실패한다.
+// 저장소의 카세트는 scriptChat 응답을 VERTEX_CASSETTE=fake 로 기록한 것이다.
+func TestFunctioncallReplay(t *testing.T) {
+	rec := cassette.Start(t, "function_call")
+	opts := rec.ClientOptions()
+	if rec.Mode() == cassette.Fake {
+		srv, err := vertextest.NewServer()
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer srv.Close()
+		scriptChat(srv)
+		opts = append(opts, srv.ClientOptions()...)
+	}
+
+	var buf bytes.Buffer
+	err := functionCallsChat(&buf, "metanonia-53f36", "us-central1", "gemini-2.0-flash", opts...)
+	if err != nil {
+		t.Fatalf("functionCallsChat() error = %v", err)
 	}
 
-	// 버퍼 내용 출력
-	fmt.Printf("Captured output:\n%s\n", buf.String())
+	out := buf.String()
+	if got := strings.Count(out, "Question: "); got != 4 {
+		t.Errorf("output contains %d questions, want 4", got)
+	}
+	if !strings.Contains(out, `"sku": "GA04834-US"`) || !strings.Contains(out, "Answer generated by the model(LLM):") {
+		t.Errorf("unexpected output:\n%s", out)
+	}
 }
@@ -0,0 +1,175 @@
+package hybrid
+
+import (
+	"cmp"
+	"context"
+	"math"
+	"slices"
+	"sync"
+
+	"vertex/vectorstore"
+)
+
+// BM25 기본 값. K1 은 같은 토큰이 여러 번 나올 때 점수가 늘어나는 정도, B 는 문서 길이 보정의 세기.
+const (
+	DefaultK1 = 1.2
+	DefaultB  = 0.75
+)
+
+// BM25 는 메모리에 두는 BM25 낱말 색인. 여러 고루틴에서 함께 써도 된다.
+type BM25 struct {
+	k1, b float64
+
+	mu       sync.RWMutex
+	docs     map[string]*bm25Doc
+	postings map[string]map[string]int // 토큰 → 문서 ID → 나온 횟수
+	totalLen int
+}
+
+type bm25Doc struct {
+	doc vectorstore.Document // Vector 는 비워 둔다
+	tf  map[string]int
+	len int
+}
+
+// NewBM25 는 기본 값의 빈 색인을 만든다.
+func NewBM25() *BM25 {
+	return NewBM25With(DefaultK1, DefaultB)
+}
+
+// NewBM25With 는 k1, b 값의 빈 색인을 만든다.
+func NewBM25With(k1, b float64) *BM25 {
+	return &BM25{k1: k1, b: b, docs: make(map[string]*bm25Doc), postings: make(map[string]map[string]int)}
+}
+
+// Upsert 는 TextIndex 를 구현한다. 같은 ID 가 있으면 바꾼다.
+func (x *BM25) Upsert(ctx context.Context, docs ...vectorstore.Document) error {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	for _, d := range docs {
+		x.remove(d.ID)
+		tokens := Tokenize(d.Content)
+		bd := &bm25Doc{tf: make(map[string]int), len: len(tokens)}
+		bd.doc = d
+		bd.doc.Vector = nil
+		for _, t := range tokens {
+			bd.tf[t]++
+		}
+		for t, n := range bd.tf {
+			p := x.postings[t]
+			if p == nil {
+				p = make(map[string]int)
+				x.postings[t] = p
+			}
+			p[d.ID] = n
+		}
+		x.docs[d.ID] = bd
+		x.totalLen += bd.len
+	}
+	return nil
+}
+
+// Delete 는 TextIndex 를 구현한다.
+func (x *BM25) Delete(ctx context.Context, ids ...string) error {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	for _, id := range ids {
+		x.remove(id)
+	}
+	return nil
+}
+
+// Prune 은 keep 의 문서(DocID)마다 조각 ID 가 keep[DocID] 에 없는 조각을 지운다.
+func (x *BM25) Prune(ctx context.Context, keep map[string][]string) error {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	for id, bd := range x.docs {
+		if ids, ok := keep[bd.doc.DocID]; ok && !slices.Contains(ids, id) {
+			x.remove(id)
+		}
+	}
+	return nil
+}
+
+// remove 는 id 문서를 뺀다. x.mu 를 잡은 채로 부른다.
+func (x *BM25) remove(id string) {
+	bd, ok := x.docs[id]
+	if !ok {
+		return
+	}
+	for t := range bd.tf {
+		delete(x.postings[t], id)
+		if len(x.postings[t]) == 0 {
+			delete(x.postings, t)
+		}
+	}
+	delete(x.docs, id)
+	x.totalLen -= bd.len
+}
+
+// Len 은 색인된 문서 수.
+func (x *BM25) Len() int {
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+	return len(x.docs)
+}
+
+// SearchText 는 TextIndex 를 구현한다. 점수가 같으면 ID 순서를 따른다.
+func (x *BM25) SearchText(ctx context.Context, query string, opts vectorstore.SearchOptions) ([]vectorstore.Result, error) {
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+	if len(x.docs) == 0 {
+		return nil, nil
+	}
+	n := float64(len(x.docs))
+	avgLen := float64(x.totalLen) / n
+	scores := make(map[string]float64)
+	seen := make(map[string]bool)
+	for _, t := range Tokenize(query) {
+		if seen[t] {
+			continue
+		}
+		seen[t] = true
+		p := x.postings[t]
+		if len(p) == 0 {
+			continue
+		}
+		df := float64(len(p))
+		idf := math.Log(1 + (n-df+0.5)/(df+0.5))
+		for id, tf := range p {
+			norm := x.k1 * (1 - x.b + x.b*float64(x.docs[id].len)/avgLen)
+			scores[id] += idf * float64(tf) * (x.k1 + 1) / (float64(tf) + norm)
+		}
+	}
+
+	var results []vectorstore.Result
+	for id, s := range scores {
+		d := x.docs[id].doc
+		if len(opts.DocIDs) > 0 && !slices.Contains(opts.DocIDs, d.DocID) {
+			continue
+		}
+		results = append(results, vectorstore.Result{
+			ID: d.ID, Content: d.Content, Score: float32(s), DocID: d.DocID, Start: d.Start, End: d.End,
+		})
+	}
+	sortResults(results)
+	return results[:min(limit(opts), len(results))], nil
+}
+
+// sortResults 는 점수 내림차순, 같으면 ID 오름차순으로 정렬한다.
+func sortResults(results []vectorstore.Result) {
+	slices.SortFunc(results, func(a, b vectorstore.Result) int {
+		if c := cmp.Compare(b.Score, a.Score); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.ID, b.ID)
+	})
+}
+
+// limit 은 opts.K, 0 이면 vectorstore.DefaultK.
+func limit(opts vectorstore.SearchOptions) int {
+	if opts.K <= 0 {
+		return vectorstore.DefaultK
+	}
+	return opts.K
+}
@@ -0,0 +1,68 @@
+package hybrid
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"vertex/vectorstore"
+)
+
+func TestBM25(t *testing.T) {
+	ctx := context.Background()
+	x := NewBM25()
+	err := x.Upsert(ctx,
+		vectorstore.Document{ID: "a#0", DocID: "a", Content: "GA04834-US 무선 이어폰 재고 안내"},
+		vectorstore.Document{ID: "b#0", DocID: "b", Content: "무선 이어폰 사용 설명서. 이어폰을 충전하세요."},
+		vectorstore.Document{ID: "c#0", DocID: "c", Content: "환불 정책과 배송 안내"},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		query string
+		opts  vectorstore.SearchOptions
+		want  []string
+	}{
+		{"product code", "GA04834-US 있나요", vectorstore.SearchOptions{K: 3}, []string{"a#0"}},
+		{"code part", "ga04834", vectorstore.SearchOptions{}, []string{"a#0"}},
+		// 이어폰이 두 번 나오는 b 가 먼저
+		{"term frequency", "이어폰", vectorstore.SearchOptions{K: 3}, []string{"b#0", "a#0"}},
+		{"korean bigram", "배송은 언제", vectorstore.SearchOptions{}, []string{"c#0"}},
+		{"doc filter", "이어폰", vectorstore.SearchOptions{DocIDs: []string{"a"}}, []string{"a#0"}},
+		{"k", "안내", vectorstore.SearchOptions{K: 1}, []string{"c#0"}},
+		{"no match", "주문 취소", vectorstore.SearchOptions{}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := x.SearchText(ctx, tt.query, tt.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ids := resultIDs(got); !slices.Equal(ids, tt.want) {
+				t.Errorf("SearchText(%q) = %v, want %v", tt.query, ids, tt.want)
+			}
+		})
+	}
+
+	// 바꾸거나 지운 문서의 예전 토큰으로는 찾히지 않는다
+	if err := x.Upsert(ctx, vectorstore.Document{ID: "a#0", DocID: "a", Content: "단종된 모델"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := x.Delete(ctx, "c#0", "missing"); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := x.SearchText(ctx, "GA04834-US 안내", vectorstore.SearchOptions{}); len(got) != 0 || x.Len() != 2 {
+		t.Errorf("SearchText() after update = %v, Len() = %d", resultIDs(got), x.Len())
+	}
+}
+
+func resultIDs(results []vectorstore.Result) []string {
+	var ids []string
+	for _, r := range results {
+		ids = append(ids, r.ID)
+	}
+	return ids
+}
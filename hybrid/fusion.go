@@ -0,0 +1,78 @@
+package hybrid
+
+import (
+	"vertex/vectorstore"
+)
+
+// DefaultRRFK 는 RRF 의 순위 보정 상수. 원 논문(Cormack et al., 2009)의 값을 쓴다.
+const DefaultRRFK = 60
+
+// Fusion 은 여러 검색 결과 목록을 하나로 합친다. 결과는 합친 점수 내림차순이며,
+// Score 는 합친 점수이므로 코사인 유사도와 비교할 수 없다.
+type Fusion func(lists ...[]vectorstore.Result) []vectorstore.Result
+
+// RRF 는 reciprocal rank fusion. 문서마다 목록별 1/(k + 순위) 를 더한다 (순위는 1부터).
+// 점수의 크기를 보지 않고 순위만 쓰므로 BM25 와 코사인처럼 단위가 다른 점수도 합칠 수 있다.
+func RRF(k int) Fusion {
+	if k <= 0 {
+		k = DefaultRRFK
+	}
+	return func(lists ...[]vectorstore.Result) []vectorstore.Result {
+		return merge(lists, func(_ int, list []vectorstore.Result) []float64 {
+			scores := make([]float64, len(list))
+			for rank := range list {
+				scores[rank] = 1 / float64(k+rank+1)
+			}
+			return scores
+		})
+	}
+}
+
+// Weighted 는 목록마다 점수를 0~1 로 맞춘 뒤(최솟값 0, 최댓값 1) weights 를 곱해 더한다.
+// weights 는 목록 순서를 따르며, 모자라면 1 로 본다. 목록의 점수가 모두 같으면 모두 1 로 본다.
+func Weighted(weights ...float64) Fusion {
+	return func(lists ...[]vectorstore.Result) []vectorstore.Result {
+		return merge(lists, func(i int, list []vectorstore.Result) []float64 {
+			w := 1.0
+			if i < len(weights) {
+				w = weights[i]
+			}
+			lo, hi := list[0].Score, list[0].Score
+			for _, r := range list {
+				lo, hi = min(lo, r.Score), max(hi, r.Score)
+			}
+			scores := make([]float64, len(list))
+			for rank, r := range list {
+				scores[rank] = w
+				if hi > lo {
+					scores[rank] = w * float64(r.Score-lo) / float64(hi-lo)
+				}
+			}
+			return scores
+		})
+	}
+}
+
+// merge 는 목록들의 결과를 ID 로 모아 score 가 매긴 점수를 더한다. score 는 i 번째 목록의
+// 결과마다의 점수를 돌려준다. 내용은 처음 나온 결과의 것을 쓴다.
+func merge(lists [][]vectorstore.Result, score func(i int, list []vectorstore.Result) []float64) []vectorstore.Result {
+	total := make(map[string]float64)
+	var out []vectorstore.Result
+	for i, list := range lists {
+		if len(list) == 0 {
+			continue
+		}
+		for rank, s := range score(i, list) {
+			r := list[rank]
+			if _, ok := total[r.ID]; !ok {
+				out = append(out, r)
+			}
+			total[r.ID] += s
+		}
+	}
+	for j := range out {
+		out[j].Score = float32(total[out[j].ID])
+	}
+	sortResults(out)
+	return out
+}
@@ -0,0 +1,55 @@
+package hybrid
+
+import (
+	"math"
+	"slices"
+	"testing"
+
+	"vertex/vectorstore"
+)
+
+func results(ids ...string) []vectorstore.Result {
+	out := make([]vectorstore.Result, len(ids))
+	for i, id := range ids {
+		out[i] = vectorstore.Result{ID: id, Score: float32(len(ids) - i)}
+	}
+	return out
+}
+
+func TestRRF(t *testing.T) {
+	vec := results("a", "b", "c")
+	text := results("c", "d")
+	got := RRF(60)(vec, text)
+	// c 는 두 목록에 모두 있어 1/63 + 1/61 로 가장 높다
+	if ids := resultIDs(got); !slices.Equal(ids, []string{"c", "a", "b", "d"}) {
+		t.Errorf("RRF() = %v", ids)
+	}
+	if want := 1.0/63 + 1.0/61; math.Abs(float64(got[0].Score)-want) > 1e-6 {
+		t.Errorf("RRF() score of c = %v, want %v", got[0].Score, want)
+	}
+	if got := RRF(0)(nil, nil); got != nil {
+		t.Errorf("RRF() of empty lists = %v", got)
+	}
+}
+
+func TestWeighted(t *testing.T) {
+	vec := []vectorstore.Result{{ID: "a", Score: 0.9}, {ID: "b", Score: 0.8}, {ID: "c", Score: 0.5}}
+	text := []vectorstore.Result{{ID: "c", Score: 12}, {ID: "d", Score: 2}}
+	tests := []struct {
+		name    string
+		weights []float64
+		want    []string
+	}{
+		// c: 0 + 1, a: 1, b: 0.75, d: 0 → 같은 점수는 ID 순서
+		{"equal", nil, []string{"a", "c", "b", "d"}},
+		{"favor text", []float64{0.3, 0.7}, []string{"c", "a", "b", "d"}},
+		{"vector only", []float64{1, 0}, []string{"a", "b", "c", "d"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if ids := resultIDs(Weighted(tt.weights...)(vec, text)); !slices.Equal(ids, tt.want) {
+				t.Errorf("Weighted(%v) = %v, want %v", tt.weights, ids, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,166 @@
+// Package hybrid 는 낱말 검색(BM25, PostgreSQL 전문 검색)과 벡터 검색을 함께 써서 결과를 합친다.
+//
+// 임베딩 검색은 뜻이 비슷한 문서는 잘 찾지만 "GA04834-US" 같은 제품 코드나 이름처럼 글자가
+// 그대로 맞아야 하는 질문은 놓치기 쉽다. Store 는 문서를 벡터 저장소와 낱말 색인에 함께 넣고,
+// 두 검색 결과를 순위 융합(RRF)이나 가중 점수로 합친다.
+package hybrid
+
+import (
+	"context"
+	"errors"
+	"slices"
+
+	"vertex/vectorstore"
+)
+
+// DefaultCandidates 는 합치기 전에 각 검색에서 가져올 후보 수가 K 의 몇 배인지.
+const DefaultCandidates = 4
+
+// DefaultMinMatch 는 Store.MinMatch 가 0 일 때 쓰는 값.
+const DefaultMinMatch = 0.5
+
+// TextIndex 는 낱말로 찾는 색인. BM25 와 PGText 가 구현한다.
+type TextIndex interface {
+	Upsert(ctx context.Context, docs ...vectorstore.Document) error
+	Delete(ctx context.Context, ids ...string) error
+	// SearchText 는 query 의 토큰과 맞는 문서를 점수 내림차순으로 최대 K 개 돌려준다.
+	// 점수는 색인마다 단위가 달라 MinScore 는 쓰지 않고 K 와 DocIDs 만 따른다.
+	SearchText(ctx context.Context, query string, opts vectorstore.SearchOptions) ([]vectorstore.Result, error)
+}
+
+var (
+	_ TextIndex               = (*BM25)(nil)
+	_ TextIndex               = (*PGText)(nil)
+	_ vectorstore.VectorStore = (*Store)(nil)
+)
+
+// Store 는 문서를 Vector 와 Text 에 함께 넣고, SearchHybrid 로 두 검색을 합쳐 찾는다.
+// vectorstore.VectorStore 도 구현하며, 그 Search 는 벡터 검색만 한다.
+type Store struct {
+	Vector vectorstore.VectorStore
+	Text   TextIndex
+	// Fusion 이 nil 이면 RRF(DefaultRRFK). 목록은 벡터, 낱말 순서로 넘긴다.
+	Fusion Fusion
+	// Candidates 는 각 검색에서 가져올 후보 수를 K 의 몇 배로 할지. 0 이면 DefaultCandidates.
+	Candidates int
+	// MinMatch 는 벡터 검색에 없는 낱말 검색 결과가 담아야 하는 질문 토큰의 비율.
+	// 0 이면 DefaultMinMatch, 음수면 거르지 않는다.
+	MinMatch float64
+}
+
+// Upsert 는 문서들을 두 색인에 모두 넣는다.
+func (s *Store) Upsert(ctx context.Context, docs ...vectorstore.Document) error {
+	if err := s.Vector.Upsert(ctx, docs...); err != nil {
+		return err
+	}
+	return s.Text.Upsert(ctx, docs...)
+}
+
+// Delete 는 문서들을 두 색인에서 모두 지운다.
+func (s *Store) Delete(ctx context.Context, ids ...string) error {
+	return errors.Join(s.Vector.Delete(ctx, ids...), s.Text.Delete(ctx, ids...))
+}
+
+// Prune 은 두 색인에서 keep 의 문서마다 keep 에 없는 조각을 지운다. Prune 이 없는 색인은 건너뛴다.
+func (s *Store) Prune(ctx context.Context, keep map[string][]string) error {
+	var errs []error
+	for _, x := range []any{s.Vector, s.Text} {
+		if p, ok := x.(interface {
+			Prune(ctx context.Context, keep map[string][]string) error
+		}); ok {
+			errs = append(errs, p.Prune(ctx, keep))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Count 는 벡터 저장소의 문서 수.
+func (s *Store) Count(ctx context.Context) (int, error) {
+	return s.Vector.Count(ctx)
+}
+
+// Search 는 벡터 검색만 한다.
+func (s *Store) Search(ctx context.Context, query []float32, opts vectorstore.SearchOptions) ([]vectorstore.Result, error) {
+	return s.Vector.Search(ctx, query, opts)
+}
+
+// Get 은 벡터 저장소가 문서를 꺼낼 수 있으면 id 문서를 돌려준다.
+func (s *Store) Get(id string) (vectorstore.Document, bool) {
+	if g, ok := s.Vector.(interface {
+		Get(id string) (vectorstore.Document, bool)
+	}); ok {
+		return g.Get(id)
+	}
+	return vectorstore.Document{}, false
+}
+
+// SearchHybrid 는 질문 text 와 그 임베딩 vec 로 두 검색을 하고 합친 결과를 최대 K 개 돌려준다.
+// MinScore 는 벡터 검색에만 쓴다. 낱말이 그대로 맞은 문서는 유사도가 낮아도 근거가 되기 때문이다.
+// 대신 벡터 검색에 없는 낱말 검색 결과는 질문 토큰(Tokenize)의 MinMatch 비율 이상을 본문에
+// 담아야 남는다. "AI" 같은 낱말 하나만 우연히 맞은 문서는 빠지므로, 벡터 검색이 모두 거른
+// 질문에는 빈 결과를 돌려줄 수 있다. 결과의 Score 는 Fusion 이 합친 점수다.
+func (s *Store) SearchHybrid(ctx context.Context, text string, vec []float32, opts vectorstore.SearchOptions) ([]vectorstore.Result, error) {
+	k := limit(opts)
+	cand := opts
+	cand.K = k * s.candidates()
+	vres, err := s.Vector.Search(ctx, vec, cand)
+	if err != nil {
+		return nil, err
+	}
+	tres, err := s.Text.SearchText(ctx, text, cand)
+	if err != nil {
+		return nil, err
+	}
+	tres = s.filterText(text, vres, tres)
+	fusion := s.Fusion
+	if fusion == nil {
+		fusion = RRF(DefaultRRFK)
+	}
+	results := fusion(vres, tres)
+	return results[:min(k, len(results))], nil
+}
+
+// filterText 는 tres 에서 vres 에 없고 질문 토큰을 MinMatch 비율만큼 담지 않은 결과를 뺀다.
+func (s *Store) filterText(text string, vres, tres []vectorstore.Result) []vectorstore.Result {
+	minMatch := s.MinMatch
+	if minMatch == 0 {
+		minMatch = DefaultMinMatch
+	}
+	if minMatch < 0 {
+		return tres
+	}
+	query := tokenSet(text)
+	inVector := make(map[string]bool, len(vres))
+	for _, r := range vres {
+		inVector[r.ID] = true
+	}
+	return slices.DeleteFunc(tres, func(r vectorstore.Result) bool {
+		if inVector[r.ID] {
+			return false
+		}
+		content := tokenSet(r.Content)
+		n := 0
+		for t := range query {
+			if content[t] {
+				n++
+			}
+		}
+		return len(query) == 0 || float64(n)/float64(len(query)) < minMatch
+	})
+}
+
+// tokenSet 은 text 의 토큰 집합.
+func tokenSet(text string) map[string]bool {
+	set := make(map[string]bool)
+	for _, t := range Tokenize(text) {
+		set[t] = true
+	}
+	return set
+}
+
+func (s *Store) candidates() int {
+	if s.Candidates <= 0 {
+		return DefaultCandidates
+	}
+	return s.Candidates
+}
@@ -0,0 +1,90 @@
+package hybrid
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"vertex/vectorstore"
+)
+
+// TestStoreProductCode 는 임베딩으로는 멀지만 제품 코드가 그대로 맞는 문서를 하이브리드 검색이 찾는지 확인한다.
+func TestStoreProductCode(t *testing.T) {
+	ctx := context.Background()
+	s := &Store{Vector: vectorstore.NewMemory(), Text: NewBM25()}
+	err := s.Upsert(ctx,
+		vectorstore.Document{ID: "manual#0", DocID: "manual", Content: "무선 이어폰 사용 설명서", Vector: []float32{1, 0, 0}},
+		vectorstore.Document{ID: "faq#0", DocID: "faq", Content: "이어폰 자주 묻는 질문", Vector: []float32{0.9, 0.1, 0}},
+		vectorstore.Document{ID: "stock#0", DocID: "stock", Content: "GA04834-US 재고 3개", Vector: []float32{0, 0, 1}},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// "GA04834-US 이어폰 재고" 의 임베딩이 설명서 쪽에 가까워 벡터 검색은 재고 문서를 MinScore 에서 거른다
+	query, vec := "GA04834-US 이어폰 재고", []float32{1, 0.05, 0.1}
+	opts := vectorstore.SearchOptions{K: 3, MinScore: vectorstore.Threshold(0.5)}
+
+	vres, err := s.Search(ctx, vec, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if slices.Contains(resultIDs(vres), "stock#0") {
+		t.Fatalf("vector search already finds stock#0: %v", resultIDs(vres))
+	}
+	for _, fusion := range []struct {
+		name string
+		f    Fusion
+	}{{"rrf", nil}, {"weighted", Weighted(0.5, 0.5)}} {
+		t.Run(fusion.name, func(t *testing.T) {
+			s.Fusion = fusion.f
+			got, err := s.SearchHybrid(ctx, query, vec, opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ids := resultIDs(got); len(ids) != 3 || !slices.Contains(ids, "stock#0") {
+				t.Errorf("SearchHybrid() = %v, want 3 results with stock#0", ids)
+			}
+		})
+	}
+
+	// 지우면 두 색인에서 모두 빠진다
+	if err := s.Delete(ctx, "stock#0"); err != nil {
+		t.Fatal(err)
+	}
+	got, _ := s.SearchHybrid(ctx, "GA04834-US", []float32{0, 0, 1}, vectorstore.SearchOptions{MinScore: vectorstore.Threshold(0.5)})
+	if n, _ := s.Count(ctx); len(got) != 0 || n != 2 {
+		t.Errorf("after Delete: SearchHybrid() = %v, Count() = %d", resultIDs(got), n)
+	}
+	if d, ok := s.Get("manual#0"); !ok || d.DocID != "manual" {
+		t.Errorf("Get(manual#0) = %+v, %v", d, ok)
+	}
+}
+
+// TestStoreMinMatch 는 낱말 하나만 우연히 맞은 문서가 근거로 남지 않는지 확인한다.
+func TestStoreMinMatch(t *testing.T) {
+	ctx := context.Background()
+	s := &Store{Vector: vectorstore.NewMemory(), Text: NewBM25()}
+	err := s.Upsert(ctx,
+		vectorstore.Document{ID: "cloud#0", Content: "Vertex AI는 Google Cloud의 ML 플랫폼입니다", Vector: []float32{1, 0}},
+		vectorstore.Document{ID: "rag#0", Content: "RAG는 검색과 생성을 결합한 AI 접근법", Vector: []float32{0, 1}},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 벡터 검색은 아무것도 돌려주지 않고, 두 문서 모두 "AI" 만 맞는다
+	query, vec := "오늘 저녁 AI 추천 메뉴", []float32{-1, -1}
+	opts := vectorstore.SearchOptions{K: 3, MinScore: vectorstore.Threshold(0.5)}
+
+	if got, err := s.SearchHybrid(ctx, query, vec, opts); err != nil || len(got) != 0 {
+		t.Errorf("SearchHybrid() = %v, %v, want no context", resultIDs(got), err)
+	}
+	s.MinMatch = -1
+	if got, _ := s.SearchHybrid(ctx, query, vec, opts); len(got) != 2 {
+		t.Errorf("SearchHybrid() with MinMatch -1 = %v, want both documents", resultIDs(got))
+	}
+	// 벡터 검색에 든 문서는 낱말이 적게 맞아도 그대로 남는다
+	s.MinMatch = 0
+	if got, _ := s.SearchHybrid(ctx, query, []float32{1, 0}, opts); !slices.Equal(resultIDs(got), []string{"cloud#0"}) {
+		t.Errorf("SearchHybrid() with vector hit = %v, want [cloud#0]", resultIDs(got))
+	}
+}
@@ -0,0 +1,184 @@
+package hybrid
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"vertex/vectorstore"
+)
+
+// DefaultPGTextTable 은 PGText 가 쓰는 기본 테이블 이름.
+const DefaultPGTextTable = "documents_text"
+
+// maxPosition 은 tsvector 가 기록할 수 있는 가장 큰 위치.
+const maxPosition = 16383
+
+// PGText 는 PostgreSQL 전문 검색(tsvector)으로 찾는 낱말 색인.
+//
+// PostgreSQL 의 기본 파서는 한글을 띄어쓰기 단위로만 나누므로, 토큰은 Tokenize 로 만들어
+// tsvector 로 그대로 넣고 질문도 같은 토큰의 tsquery 로 찾는다. 순위는 ts_rank 를 쓴다.
+type PGText struct {
+	DB    *pgxpool.Pool
+	Table string
+}
+
+// NewPGText 는 table 이 없으면 만들고 PGText 를 돌려준다. table 이 비어 있으면 DefaultPGTextTable.
+func NewPGText(ctx context.Context, db *pgxpool.Pool, table string) (*PGText, error) {
+	if table == "" {
+		table = DefaultPGTextTable
+	}
+	x := &PGText{DB: db, Table: table}
+	for _, q := range []string{
+		fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+			id TEXT PRIMARY KEY,
+			content TEXT,
+			doc_id TEXT,
+			tokens TSVECTOR NOT NULL
+		)`, x.table()),
+		// 출처 표시용 오프셋은 나중에 더했으므로 예전 테이블에도 추가한다
+		fmt.Sprintf(`ALTER TABLE %s
+			ADD COLUMN IF NOT EXISTS start_offset INTEGER,
+			ADD COLUMN IF NOT EXISTS end_offset INTEGER`, x.table()),
+		fmt.Sprintf("CREATE INDEX IF NOT EXISTS %s ON %s USING GIN (tokens)",
+			pgx.Identifier{table + "_tokens"}.Sanitize(), x.table()),
+	} {
+		if _, err := db.Exec(ctx, q); err != nil {
+			return nil, fmt.Errorf("hybrid: create table: %w", err)
+		}
+	}
+	return x, nil
+}
+
+func (x *PGText) table() string {
+	return pgx.Identifier{x.Table}.Sanitize()
+}
+
+// Upsert 는 TextIndex 를 구현한다.
+func (x *PGText) Upsert(ctx context.Context, docs ...vectorstore.Document) error {
+	query := fmt.Sprintf(`
+		INSERT INTO %s (id, content, doc_id, tokens, start_offset, end_offset) VALUES ($1, $2, $3, $4::tsvector, $5, $6)
+		ON CONFLICT (id) DO UPDATE SET content = $2, doc_id = $3, tokens = $4::tsvector, start_offset = $5, end_offset = $6`,
+		x.table())
+	var batch pgx.Batch
+	for _, d := range docs {
+		batch.Queue(query, d.ID, d.Content, d.DocID, tsvector(Tokenize(d.Content)), d.Start, d.End)
+	}
+	return x.DB.SendBatch(ctx, &batch).Close()
+}
+
+// Delete 는 TextIndex 를 구현한다.
+func (x *PGText) Delete(ctx context.Context, ids ...string) error {
+	_, err := x.DB.Exec(ctx, fmt.Sprintf("DELETE FROM %s WHERE id = ANY($1)", x.table()), ids)
+	return err
+}
+
+// Prune 은 keep 의 문서(doc_id)마다 조각 ID 가 keep[doc_id] 에 없는 행을 지운다.
+func (x *PGText) Prune(ctx context.Context, keep map[string][]string) error {
+	query := fmt.Sprintf("DELETE FROM %s WHERE doc_id = $1 AND NOT (id = ANY($2))", x.table())
+	var batch pgx.Batch
+	for docID, ids := range keep {
+		batch.Queue(query, docID, ids)
+	}
+	return x.DB.SendBatch(ctx, &batch).Close()
+}
+
+// SearchText 는 TextIndex 를 구현한다. 질문의 토큰 중 하나라도 맞는 문서를 찾는다.
+func (x *PGText) SearchText(ctx context.Context, query string, opts vectorstore.SearchOptions) ([]vectorstore.Result, error) {
+	tokens := Tokenize(query)
+	if len(tokens) == 0 {
+		return nil, nil
+	}
+	sql, args := textQuery(x.table(), tsquery(tokens), opts)
+	rows, err := x.DB.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var results []vectorstore.Result
+	for rows.Next() {
+		var (
+			r          vectorstore.Result
+			docID      *string
+			start, end *int
+		)
+		if err := rows.Scan(&r.ID, &r.Content, &docID, &start, &end, &r.Score); err != nil {
+			return nil, err
+		}
+		if docID != nil {
+			r.DocID = *docID
+		}
+		if start != nil && end != nil {
+			r.Start, r.End = *start, *end
+		}
+		results = append(results, r)
+	}
+	return results, rows.Err()
+}
+
+// textQuery 는 SearchText 의 SQL 과 인자를 만든다.
+func textQuery(table, query string, opts vectorstore.SearchOptions) (string, []any) {
+	args := []any{query}
+	where := []string{"tokens @@ $1::tsquery"}
+	if len(opts.DocIDs) > 0 {
+		args = append(args, opts.DocIDs)
+		where = append(where, fmt.Sprintf("doc_id = ANY($%d)", len(args)))
+	}
+	args = append(args, limit(opts))
+	return fmt.Sprintf(`
+		SELECT id, content, doc_id, start_offset, end_offset, ts_rank(tokens, $1::tsquery) AS score
+		FROM %s
+		WHERE %s
+		ORDER BY score DESC, id
+		LIMIT $%d`, table, strings.Join(where, " AND "), len(args)), args
+}
+
+// tsvector 는 토큰들을 위치와 함께 tsvector 문자열로 쓴다 ('검색':1,4 'rag':2).
+// 위치를 넣어야 ts_rank 가 나온 횟수를 센다.
+func tsvector(tokens []string) string {
+	positions := make(map[string][]int)
+	var order []string
+	for i, t := range tokens {
+		if _, ok := positions[t]; !ok {
+			order = append(order, t)
+		}
+		positions[t] = append(positions[t], min(i+1, maxPosition))
+	}
+	var sb strings.Builder
+	for i, t := range order {
+		if i > 0 {
+			sb.WriteByte(' ')
+		}
+		sb.WriteString(quoteLexeme(t))
+		sb.WriteByte(':')
+		for j, p := range slices.Compact(positions[t]) {
+			if j > 0 {
+				sb.WriteByte(',')
+			}
+			fmt.Fprint(&sb, p)
+		}
+	}
+	return sb.String()
+}
+
+// tsquery 는 토큰 중 하나라도 맞으면 되는 tsquery 문자열 ('검색' | 'rag').
+func tsquery(tokens []string) string {
+	quoted := make([]string, 0, len(tokens))
+	for _, t := range tokens {
+		if q := quoteLexeme(t); !slices.Contains(quoted, q) {
+			quoted = append(quoted, q)
+		}
+	}
+	return strings.Join(quoted, " | ")
+}
+
+// quoteLexeme 은 t 를 작은따옴표로 감싼다. 안의 작은따옴표와 역슬래시는 이스케이프한다.
+func quoteLexeme(t string) string {
+	t = strings.ReplaceAll(t, `\`, `\\`)
+	return "'" + strings.ReplaceAll(t, "'", "''") + "'"
+}
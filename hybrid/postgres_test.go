@@ -0,0 +1,32 @@
+package hybrid
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"vertex/vectorstore"
+)
+
+// 데이터베이스 없이 tsvector, tsquery 문자열과 SQL 조립만 확인한다.
+func TestTSVector(t *testing.T) {
+	if got, want := tsvector([]string{"검색", "rag", "검색", "o'k"}), `'검색':1,3 'rag':2 'o''k':4`; got != want {
+		t.Errorf("tsvector() = %s, want %s", got, want)
+	}
+	if got, want := tsquery([]string{"ga04834-us", "ga04834", "us", "us"}), `'ga04834-us' | 'ga04834' | 'us'`; got != want {
+		t.Errorf("tsquery() = %s, want %s", got, want)
+	}
+	if got, want := quoteLexeme(`a\b`), `'a\\b'`; got != want {
+		t.Errorf("quoteLexeme() = %s, want %s", got, want)
+	}
+}
+
+func TestTextQuery(t *testing.T) {
+	sql, args := textQuery(`"documents_text"`, "'q'", vectorstore.SearchOptions{K: 5, DocIDs: []string{"a.md"}})
+	if !strings.Contains(sql, "WHERE tokens @@ $1::tsquery AND doc_id = ANY($2)\n") || !strings.HasSuffix(sql, "LIMIT $3") {
+		t.Errorf("SQL = %s", sql)
+	}
+	if want := []any{"'q'", []string{"a.md"}, 5}; !reflect.DeepEqual(args, want) {
+		t.Errorf("args = %v, want %v", args, want)
+	}
+}
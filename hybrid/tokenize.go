@@ -0,0 +1,84 @@
+package hybrid
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Tokenize 는 text 를 검색용 토큰으로 나눈다.
+//
+// 한글, 한자, 가나는 띄어쓰기와 조사가 일정하지 않아 낱말 단위로는 잘 맞지 않으므로 두 글자씩
+// 겹쳐 자른다 ("검색과" → "검색", "색과"). 그 밖의 글자와 숫자는 낱말 하나가 토큰이 된다.
+// "GA04834-US" 처럼 -, _, ., / 로 이어진 낱말은 조각들과 함께 통째로도 넣어 제품 코드가
+// 그대로 맞게 한다. 모두 소문자로 바꾼다.
+func Tokenize(text string) []string {
+	var tokens []string
+	for _, word := range words(strings.ToLower(text)) {
+		parts := strings.FieldsFunc(word, isConnector)
+		if len(parts) > 1 {
+			tokens = append(tokens, word)
+		}
+		for _, p := range parts {
+			tokens = appendSegments(tokens, []rune(p))
+		}
+	}
+	return tokens
+}
+
+// words 는 글자와 숫자가 이어진 낱말들을 돌려준다. 연결 문자는 양쪽이 모두 글자나 숫자일 때만 낱말에 넣는다.
+func words(text string) []string {
+	var out []string
+	rs := []rune(text)
+	start := -1
+	for i, r := range rs {
+		inner := isConnector(r) && start >= 0 && i+1 < len(rs) && isWordRune(rs[i+1])
+		switch {
+		case isWordRune(r) || inner:
+			if start < 0 {
+				start = i
+			}
+		case start >= 0:
+			out = append(out, string(rs[start:i]))
+			start = -1
+		}
+	}
+	if start >= 0 {
+		out = append(out, string(rs[start:]))
+	}
+	return out
+}
+
+// appendSegments 는 낱말 조각 p 를 문자 종류별로 나눠 토큰을 더한다.
+// 한중일 글자 구간은 두 글자씩, 나머지 구간은 통째로 넣는다.
+func appendSegments(tokens []string, p []rune) []string {
+	for len(p) > 0 {
+		cjk := isCJK(p[0])
+		n := 1
+		for n < len(p) && isCJK(p[n]) == cjk {
+			n++
+		}
+		seg := p[:n]
+		switch {
+		case !cjk || len(seg) == 1:
+			tokens = append(tokens, string(seg))
+		default:
+			for i := 0; i+1 < len(seg); i++ {
+				tokens = append(tokens, string(seg[i:i+2]))
+			}
+		}
+		p = p[n:]
+	}
+	return tokens
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+func isConnector(r rune) bool {
+	return r == '-' || r == '_' || r == '.' || r == '/'
+}
+
+func isCJK(r rune) bool {
+	return unicode.In(r, unicode.Hangul, unicode.Han, unicode.Hiragana, unicode.Katakana)
+}
@@ -0,0 +1,30 @@
+package hybrid
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestTokenize(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want []string
+	}{
+		{"english", "Vertex AI, Google Cloud!", []string{"vertex", "ai", "google", "cloud"}},
+		{"hangul bigrams", "검색과 생성", []string{"검색", "색과", "생성"}},
+		{"single hangul", "나 는", []string{"나", "는"}},
+		{"mixed script", "RAG는 접근법", []string{"rag", "는", "접근", "근법"}},
+		{"product code", "모델 GA04834-US 재고", []string{"모델", "ga04834-us", "ga04834", "us", "재고"}},
+		{"trailing connector", "v1.2. 끝-", []string{"v1.2", "v1", "2", "끝"}},
+		{"numbers", "2024년 3월", []string{"2024", "년", "3", "월"}},
+		{"empty", " .,- ", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Tokenize(tt.text); !slices.Equal(got, tt.want) {
+				t.Errorf("Tokenize(%q) = %q, want %q", tt.text, got, tt.want)
+			}
+		})
+	}
+}
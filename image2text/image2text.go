@@ -5,18 +5,24 @@ import (
 	"fmt"
 	"io"
 	"mime"
+	"os"
 	"path/filepath"
 
 	"cloud.google.com/go/vertexai/genai"
+	"google.golang.org/api/option"
+
+	"vertex/prompt"
+	"vertex/response"
 )
 
 // generateMultimodalContent generates a response into w, based upon the  provided image.
-func generateMultimodalContent(w io.Writer, projectID, location, modelName string) error {
+// opts are passed on to genai.NewClient, e.g. to point it at a fake backend in tests.
+func generateMultimodalContent(w io.Writer, projectID, location, modelName string, opts ...option.ClientOption) error {
 	// location := "us-central1"
 	// model := "gemini-1.5-flash-001"
 	ctx := context.Background()
 
-	client, err := genai.NewClient(ctx, projectID, location)
+	client, err := genai.NewClient(ctx, projectID, location, opts...)
 	if err != nil {
 		return fmt.Errorf("unable to create client: %w", err)
 	}
@@ -44,11 +50,25 @@ func generateMultimodalContent(w io.Writer, projectID, location, modelName strin
 		FileURI:  "https://metanonia.com/images/background.jpeg",
 	}
 
-	res, err := model.GenerateContent(ctx, img, genai.Text("describe this image using Korean."))
+	// 요청 문구는 "image2text" 템플릿에서 가져온다. 버전과 언어는 prompt.EnvVersion, prompt.EnvLanguage 로 고른다
+	prompts, err := prompt.Open(os.Getenv(prompt.EnvDir))
+	if err != nil {
+		return err
+	}
+	tmpl, err := prompts.GetEnv("image2text")
+	if err != nil {
+		return err
+	}
+	rendered, err := tmpl.Render(nil)
+	if err != nil {
+		return err
+	}
+
+	res, err := response.Read(rendered.Generate(ctx, model, img))
 	if err != nil {
 		return fmt.Errorf("unable to generate contents: %w", err)
 	}
 
-	fmt.Fprintf(w, "generated response: %s\n", res.Candidates[0].Content.Parts[0])
+	fmt.Fprintf(w, "generated response: %s\n", res.Text)
 	return nil
 }
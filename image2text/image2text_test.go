@@ -3,7 +3,13 @@ package image2text
 import (
 	"bytes"
 	"io"
+	"regexp"
+	"strings"
 	"testing"
+
+	"vertex/cassette"
+	"vertex/prompt"
+	"vertex/vertextest"
 )
 
 func TestGenerateMultimodalContent(t *testing.T) {
@@ -21,7 +27,7 @@ func TestGenerateMultimodalContent(t *testing.T) {
 			location:    "us-central1",
 			modelName:   "gemini-2.0-flash",
 			wantErr:     false,
-			outputRegex: "",
+			outputRegex: `^generated response: 눈 위에 앉아 있는 고양이 사진입니다\.\n$`,
 		},
 		{
 			name:        "Invalid Project ID",
@@ -35,23 +41,83 @@ func TestGenerateMultimodalContent(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
+			// 가짜 Vertex AI 서버
+			srv, err := vertextest.NewServer()
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer srv.Close()
+			srv.AddText("눈 위에 앉아 있는 고양이 사진입니다.")
+
 			// 출력 캡처용 버퍼
 			var buf bytes.Buffer
 			w := io.Writer(&buf)
 
 			// 테스트 대상 함수 실행
-			err := generateMultimodalContent(w, tt.projectID, tt.location, tt.modelName)
+			err = generateMultimodalContent(w, tt.projectID, tt.location, tt.modelName, srv.ClientOptions()...)
 
 			// 오류 검증
 			if (err != nil) != tt.wantErr {
-				t.Errorf("parallelFunctionCalling() error = %v, wantErr %v", err, tt.wantErr)
+				t.Errorf("generateMultimodalContent() error = %v, wantErr %v", err, tt.wantErr)
 				return
 			}
 
 			// 출력 검증 (오류가 없는 경우만)
 			if !tt.wantErr {
-				t.Logf("Output Got: %s", buf.String())
+				if !regexp.MustCompile(tt.outputRegex).MatchString(buf.String()) {
+					t.Errorf("output = %q, want match for %q", buf.String(), tt.outputRegex)
+				}
+				// 이미지 파트와 안전 설정이 요청에 담겨야 한다
+				req := srv.GenerateRequests()[0]
+				parts := req.GetContents()[0].GetParts()
+				if !strings.HasSuffix(parts[0].GetFileData().GetFileUri(), "background.jpeg") || len(req.GetSafetySettings()) != 2 {
+					t.Errorf("request = %v", req)
+				}
 			}
 		})
 	}
 }
+
+// TestGenerateMultimodalContentReplay 는 카세트에 기록된 모델 응답으로 이미지 설명 요청을 재생한다.
+// 저장소의 카세트는 아래 스크립트 응답을 VERTEX_CASSETTE=fake 로 기록한 것이다.
+func TestGenerateMultimodalContentReplay(t *testing.T) {
+	rec := cassette.Start(t, "image2text")
+	opts := rec.ClientOptions()
+	if rec.Mode() == cassette.Fake {
+		srv, err := vertextest.NewServer()
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer srv.Close()
+		srv.AddText("눈 위에 앉아 있는 고양이 사진입니다.")
+		opts = append(opts, srv.ClientOptions()...)
+	}
+
+	var buf bytes.Buffer
+	if err := generateMultimodalContent(&buf, "metanonia-53f36", "us-central1", "gemini-2.0-flash", opts...); err != nil {
+		t.Fatalf("generateMultimodalContent() error = %v", err)
+	}
+	if !strings.HasPrefix(buf.String(), "generated response: ") || len(buf.String()) < len("generated response: \n")+1 {
+		t.Errorf("unexpected output: %q", buf.String())
+	}
+}
+
+// TestGenerateMultimodalContentLanguage 는 VERTEX_PROMPT_LANG 로 요청 문구의 언어를 고르는지 확인한다.
+func TestGenerateMultimodalContentLanguage(t *testing.T) {
+	t.Setenv(prompt.EnvLanguage, "en")
+	srv, err := vertextest.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Close()
+	srv.AddText("A cat sitting in the snow.")
+
+	var buf bytes.Buffer
+	if err := generateMultimodalContent(&buf, "metanonia-53f36", "us-central1", "gemini-2.0-flash", srv.ClientOptions()...); err != nil {
+		t.Fatalf("generateMultimodalContent() error = %v", err)
+	}
+	parts := srv.GenerateRequests()[0].GetContents()[0].GetParts()
+	if got := parts[len(parts)-1].GetText(); got != "Describe this image in English." {
+		t.Errorf("request text = %q", got)
+	}
+}
@@ -0,0 +1,141 @@
+package ingest
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+)
+
+// ErrCheckpointConfig 는 체크포인트가 다른 설정(임베딩 모델, 분할 방식 등)으로 만들어졌을 때의 오류.
+// 이어서 넣으면 서로 다른 방식의 조각이 섞이므로, 체크포인트 파일을 지우고 처음부터 넣어야 한다.
+var ErrCheckpointConfig = errors.New("ingest: checkpoint was written with different settings")
+
+// FileState 는 다 넣은 파일 하나의 기록. 크기나 수정 시각이 바뀌면 다시 넣는다.
+type FileState struct {
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mod_time"`
+	Chunks  int       `json:"chunks"`
+}
+
+// Checkpoint 는 어떤 파일까지 저장소에 넣었는지 기록한다. 파일마다 넣은 뒤 바로 저장하므로
+// 중간에 멈춰도 다음 실행은 남은 파일부터 이어 간다.
+type Checkpoint struct {
+	mu   sync.Mutex
+	path string
+
+	// Config 는 체크포인트를 만든 설정을 나타내는 문자열.
+	Config string `json:"config"`
+	// Files 는 루트 디렉터리 기준 상대 경로 ("/" 구분) 별 기록.
+	Files map[string]FileState `json:"files"`
+}
+
+// LoadCheckpoint 는 path 의 체크포인트를 읽는다. 파일이 없으면 빈 체크포인트를 만든다.
+// 읽은 체크포인트의 설정이 config 와 다르면 ErrCheckpointConfig 를 돌려준다.
+// path 가 비어 있으면 파일에 저장하지 않는 체크포인트가 된다.
+func LoadCheckpoint(path, config string) (*Checkpoint, error) {
+	c := &Checkpoint{path: path, Config: config, Files: make(map[string]FileState)}
+	if path == "" {
+		return c, nil
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return c, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ingest: read checkpoint: %w", err)
+	}
+	if err := json.Unmarshal(data, c); err != nil {
+		return nil, fmt.Errorf("ingest: read checkpoint %s: %w", path, err)
+	}
+	if c.Config != config {
+		return nil, fmt.Errorf("%w: %s has %q, want %q", ErrCheckpointConfig, path, c.Config, config)
+	}
+	if c.Files == nil {
+		c.Files = make(map[string]FileState)
+	}
+	return c, nil
+}
+
+// Done 은 rel 파일이 지금 상태(info) 그대로 이미 들어갔는지 알려 준다.
+func (c *Checkpoint) Done(rel string, info fs.FileInfo) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	s, ok := c.Files[rel]
+	return ok && s.Size == info.Size() && s.ModTime.Equal(info.ModTime())
+}
+
+// Previous 는 rel 파일의 이전 기록. 파일이 바뀐 경우 예전 조각을 지울지 정하는 데 쓴다.
+func (c *Checkpoint) Previous(rel string) (FileState, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	s, ok := c.Files[rel]
+	return s, ok
+}
+
+// Mark 는 rel 파일을 다 넣었다고 기록하고 체크포인트를 저장한다.
+func (c *Checkpoint) Mark(rel string, info fs.FileInfo, chunks int) error {
+	c.Set(rel, info, chunks)
+	return c.Save()
+}
+
+// Set 은 rel 파일을 다 넣었다고 기록만 한다. Save 를 불러야 파일에 남는다.
+func (c *Checkpoint) Set(rel string, info fs.FileInfo, chunks int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.Files[rel] = FileState{Size: info.Size(), ModTime: info.ModTime(), Chunks: chunks}
+}
+
+// Forget 은 rel 파일의 기록을 지운다. Save 를 불러야 파일에 남는다.
+func (c *Checkpoint) Forget(rel string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.Files, rel)
+}
+
+// Paths 는 기록된 파일들의 경로를 정렬해 돌려준다.
+func (c *Checkpoint) Paths() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return slices.Sorted(maps.Keys(c.Files))
+}
+
+// Save 는 지금까지의 기록을 저장한다.
+func (c *Checkpoint) Save() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.save()
+}
+
+// save 는 임시 파일에 쓴 뒤 이름을 바꿔, 저장 중에 멈춰도 이전 체크포인트가 남게 한다.
+func (c *Checkpoint) save() error {
+	if c.path == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(c.path), ".checkpoint-*")
+	if err != nil {
+		return fmt.Errorf("ingest: write checkpoint: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("ingest: write checkpoint: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("ingest: write checkpoint: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), c.path); err != nil {
+		return fmt.Errorf("ingest: write checkpoint: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,226 @@
+package ingest
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// ErrUnsupported 는 글자를 뽑을 수 없는 파일 형식의 오류.
+var ErrUnsupported = errors.New("ingest: unsupported file type")
+
+// Supported 는 path 의 확장자로 글자를 뽑을 수 있는지 알려 준다.
+func Supported(path string) bool {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".txt", ".md", ".markdown", ".html", ".htm", ".pdf":
+		return true
+	}
+	return false
+}
+
+// ExtractFile 은 파일을 읽어 확장자에 맞게 글자를 뽑는다.
+func ExtractFile(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return Extract(path, data)
+}
+
+// Extract 는 path 의 확장자로 형식을 정해 data 에서 글자를 뽑는다.
+// .txt 와 .md 는 그대로, .html 은 태그를 빼고 블록마다 문단을 나누며, .pdf 는 ExtractPDF 를 쓴다.
+func Extract(path string, data []byte) (string, error) {
+	var (
+		text string
+		err  error
+	)
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".txt", ".md", ".markdown":
+		text = string(bytes.TrimPrefix(data, []byte("\xef\xbb\xbf")))
+	case ".html", ".htm":
+		text, err = ExtractHTML(data)
+	case ".pdf":
+		text, err = ExtractPDF(data)
+	default:
+		return "", fmt.Errorf("%w: %q", ErrUnsupported, ext)
+	}
+	if err != nil {
+		return "", err
+	}
+	return strings.ToValidUTF8(text, "�"), nil
+}
+
+// ExtractHTML 은 HTML 에서 보이는 글자만 뽑는다. script, style 등은 빼고,
+// 문단·제목·목록 같은 블록 사이는 빈 줄로 나눠 Paragraphs 나 Markdown 으로 나누기 좋게 한다.
+// 제목은 "# " 형식으로 바꾸고, <title> 은 맨 앞의 "# " 제목이 된다 (같은 글자의 h1 은 한 번만 쓴다).
+// pre 는 들여쓰기와 줄바꿈을 그대로 둔 채 ``` 코드 블록으로 감싸, Markdown 이 그 안의 # 을
+// 제목으로 보지 않게 한다.
+func ExtractHTML(data []byte) (string, error) {
+	doc, err := html.Parse(bytes.NewReader(data))
+	if err != nil {
+		return "", fmt.Errorf("ingest: parse html: %w", err)
+	}
+	var (
+		sb    strings.Builder
+		segs  []htmlSegment
+		title string
+	)
+	if t := find(doc, atom.Title); t != nil {
+		title = strings.TrimSpace(spaceRE.ReplaceAllString(textContent(t), " "))
+	}
+	if title != "" {
+		sb.WriteString("# " + title + "\n\n")
+	}
+	var walk func(n *html.Node, pre bool)
+	walk = func(n *html.Node, pre bool) {
+		switch n.Type {
+		case html.TextNode:
+			if pre {
+				sb.WriteString(n.Data)
+			} else {
+				sb.WriteString(spaceRE.ReplaceAllString(n.Data, " "))
+			}
+			return
+		case html.ElementNode:
+			switch n.DataAtom {
+			case atom.Script, atom.Style, atom.Noscript, atom.Template, atom.Head, atom.Svg:
+				return
+			case atom.Br:
+				sb.WriteString("\n")
+				return
+			case atom.H1:
+				if title != "" && strings.TrimSpace(spaceRE.ReplaceAllString(textContent(n), " ")) == title {
+					return
+				}
+			case atom.Pre:
+				if pre {
+					break
+				}
+				// pre 의 글자는 따로 모아 줄 정리에서 뺀다
+				sb.WriteString("\n\n")
+				segs = append(segs, htmlSegment{text: sb.String()})
+				sb.Reset()
+				for c := n.FirstChild; c != nil; c = c.NextSibling {
+					walk(c, true)
+				}
+				segs = append(segs, htmlSegment{text: sb.String(), pre: true})
+				sb.Reset()
+				sb.WriteString("\n\n")
+				return
+			}
+		}
+		block := n.Type == html.ElementNode && blockElements[n.DataAtom]
+		if block {
+			sb.WriteString("\n\n")
+			if level := headingLevel(n.DataAtom); level > 0 {
+				sb.WriteString(strings.Repeat("#", level) + " ")
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c, pre)
+		}
+		if block {
+			sb.WriteString("\n\n")
+		}
+	}
+	walk(doc, false)
+	segs = append(segs, htmlSegment{text: sb.String()})
+
+	// pre 밖은 줄마다 앞뒤 공백을 정리하고 빈 줄은 하나로 줄인다
+	var lines []string
+	blank := true
+	for _, seg := range segs {
+		if seg.pre {
+			text := strings.Trim(seg.text, "\n")
+			if strings.TrimSpace(text) == "" {
+				continue
+			}
+			lines = append(lines, "```")
+			for _, line := range strings.Split(text, "\n") {
+				lines = append(lines, strings.TrimRight(line, " \t"))
+			}
+			lines = append(lines, "```")
+			blank = false
+			continue
+		}
+		for _, line := range strings.Split(seg.text, "\n") {
+			line = strings.TrimSpace(line)
+			if line == "" {
+				if !blank {
+					lines = append(lines, "")
+				}
+				blank = true
+				continue
+			}
+			lines = append(lines, line)
+			blank = false
+		}
+	}
+	return strings.TrimRight(strings.Join(lines, "\n"), "\n"), nil
+}
+
+// htmlSegment 는 ExtractHTML 이 뽑은 글자 조각. pre 면 공백을 그대로 둔다.
+type htmlSegment struct {
+	text string
+	pre  bool
+}
+
+// find 는 n 아래에서 처음 나오는 a 요소를 찾는다.
+func find(n *html.Node, a atom.Atom) *html.Node {
+	if n.Type == html.ElementNode && n.DataAtom == a {
+		return n
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if f := find(c, a); f != nil {
+			return f
+		}
+	}
+	return nil
+}
+
+// textContent 는 n 아래 글자 노드를 모두 이어 붙인다.
+func textContent(n *html.Node) string {
+	if n.Type == html.TextNode {
+		return n.Data
+	}
+	var sb strings.Builder
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		sb.WriteString(textContent(c))
+	}
+	return sb.String()
+}
+
+var spaceRE = regexp.MustCompile(`\s+`)
+
+var blockElements = map[atom.Atom]bool{
+	atom.P: true, atom.Div: true, atom.Section: true, atom.Article: true, atom.Header: true,
+	atom.Footer: true, atom.Nav: true, atom.Aside: true, atom.Main: true, atom.Blockquote: true,
+	atom.Pre: true, atom.Ul: true, atom.Ol: true, atom.Li: true, atom.Dl: true, atom.Dt: true,
+	atom.Dd: true, atom.Table: true, atom.Tr: true, atom.Figure: true, atom.Figcaption: true,
+	atom.H1: true, atom.H2: true, atom.H3: true, atom.H4: true, atom.H5: true, atom.H6: true,
+}
+
+func headingLevel(a atom.Atom) int {
+	switch a {
+	case atom.H1:
+		return 1
+	case atom.H2:
+		return 2
+	case atom.H3:
+		return 3
+	case atom.H4:
+		return 4
+	case atom.H5:
+		return 5
+	case atom.H6:
+		return 6
+	}
+	return 0
+}
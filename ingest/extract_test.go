@@ -0,0 +1,56 @@
+package ingest
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestExtract(t *testing.T) {
+	tests := []struct {
+		path string
+		data string
+		want string
+	}{
+		{"a.txt", "\xef\xbb\xbf한글 문서", "한글 문서"},
+		{"b.md", "# 제목\n\n본문", "# 제목\n\n본문"},
+		{
+			"c.html",
+			`<html><head><title>설치   안내</title><style>p{}</style></head><body>
+			<h1>설치</h1><p>첫   문단
+			입니다.<br>줄바꿈</p><script>alert(1)</script>
+			<ul><li>하나</li><li>둘</li></ul><pre>  code
+  block</pre></body></html>`,
+			"# 설치 안내\n\n# 설치\n\n첫 문단 입니다.\n줄바꿈\n\n하나\n\n둘\n\n```\n  code\n  block\n```",
+		},
+		{
+			// 제목과 같은 h1 은 한 번만, pre 안의 들여쓰기와 빈 줄, # 은 코드 블록에 그대로
+			"d.html",
+			`<title>Go 예제</title><h1>Go  예제</h1><p>코드:</p><pre><code># 실행
+func main() {
+	if ok {
+		run()
+	}
+
+	done()
+}
+</code></pre><p>끝</p>`,
+			"# Go 예제\n\n코드:\n\n```\n# 실행\nfunc main() {\n\tif ok {\n\t\trun()\n\t}\n\n\tdone()\n}\n```\n\n끝",
+		},
+		{"e.html", `<pre>   앞 공백</pre>`, "```\n   앞 공백\n```"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			got, err := Extract(tt.path, []byte(tt.data))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("Extract() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := Extract("d.docx", nil); !errors.Is(err, ErrUnsupported) {
+		t.Errorf("Extract(docx) error = %v, want ErrUnsupported", err)
+	}
+}
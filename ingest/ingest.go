@@ -0,0 +1,269 @@
+// Package ingest 는 디렉터리의 문서 파일들을 읽어 조각으로 나누고, 임베딩해 저장소에 넣는다.
+//
+// .txt, .md, .html, .pdf 파일에서 글자를 뽑으며, 넣은 파일을 Checkpoint 에 기록하므로
+// 큰 말뭉치를 넣다가 멈춰도 다음 실행은 남은 파일부터 이어 간다.
+package ingest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"vertex/chunker"
+	"vertex/embedding"
+	"vertex/vectorstore"
+)
+
+// Store 는 조각을 넣을 저장소. vectorstore.VectorStore 구현들이 이를 만족한다.
+// 바뀐 파일을 다시 넣을 때는 예전 조각이 남지 않도록 먼저 지운다.
+type Store interface {
+	Upsert(ctx context.Context, docs ...vectorstore.Document) error
+	Delete(ctx context.Context, ids ...string) error
+}
+
+// Flusher 는 바뀐 조각을 모아 두었다가 Flush 에서 한꺼번에 저장하는 Store (인덱스 파일 등).
+// Store 가 Flusher 면 Ingester 는 FlushEvery 파일마다, 그리고 Run 이 끝날 때 Flush 한 뒤에야
+// 그 파일들을 체크포인트에 저장하므로, 중간에 멈춰도 체크포인트가 저장소보다 앞서지 않는다.
+type Flusher interface {
+	Flush(ctx context.Context) error
+}
+
+// DefaultFlushEvery 는 Ingester.FlushEvery 가 0 일 때 몇 파일마다 Flush 할지.
+const DefaultFlushEvery = 100
+
+// Progress 는 파일 하나를 처리한 결과.
+type Progress struct {
+	// Path 는 루트 기준 상대 경로이자 조각의 DocID.
+	Path string
+	// Done 번째 파일 (1부터), 전체 Total 개.
+	Done, Total int
+	// Chunks 는 넣은 조각 수.
+	Chunks int
+	// Skipped 는 체크포인트에 이미 있어 건너뛰었는지.
+	Skipped bool
+	// Removed 는 디렉터리에서 사라진 파일이라 조각을 지웠는지. 이때 Done, Total 은 0 이다.
+	Removed bool
+	// Err 는 글자를 뽑지 못한 오류. 이 파일은 건너뛰고 다음 실행에서 다시 시도한다.
+	// *embedding.TruncatedError 이면 조각은 넣었지만 일부가 잘려 임베딩된 것이다.
+	Err error
+}
+
+// Stats 는 Run 한 번의 결과 요약.
+type Stats struct {
+	Files, Skipped, Failed, Chunks int
+	// Removed 는 디렉터리에서 사라져 조각을 지운 파일 수.
+	Removed int
+}
+
+// Ingester 는 파일들을 저장소에 넣는다.
+type Ingester struct {
+	Chunker  chunker.Chunker
+	Embedder embedding.Embedder
+	Store    Store
+	// Checkpoint 가 nil 이면 모든 파일을 매번 넣는다.
+	Checkpoint *Checkpoint
+	// FlushEvery 는 Store 가 Flusher 일 때 몇 파일을 넣을 때마다 Flush 할지. 0 이면 DefaultFlushEvery.
+	// Flusher 가 아니면 파일마다 체크포인트를 저장한다.
+	FlushEvery int
+	// Progress 가 nil 이 아니면 파일마다 부른다.
+	Progress func(Progress)
+}
+
+// Files 는 root 아래에서 글자를 뽑을 수 있는 파일들을 경로 순서대로 돌려준다.
+// 이름이 "." 으로 시작하는 파일과 디렉터리(.git 등)는 건너뛴다.
+func Files(root string) ([]string, error) {
+	var files []string
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if path != root && strings.HasPrefix(d.Name(), ".") {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.Type().IsRegular() && Supported(path) {
+			files = append(files, path)
+		}
+		return nil
+	})
+	return files, err
+}
+
+// Run 은 root 아래의 파일들을 넣는다. 글자를 뽑지 못한 파일은 Progress 로 알리고 건너뛰지만,
+// 임베딩이나 저장에 실패하면 그 자리에서 멈춘다. 그때까지 넣은 파일은 체크포인트에 남는다.
+// 모든 파일을 넣은 뒤에는 체크포인트에는 있지만 root 에서 사라진 파일의 조각을 지운다.
+func (in *Ingester) Run(ctx context.Context, root string) (stats Stats, err error) {
+	files, err := Files(root)
+	if err != nil {
+		return stats, fmt.Errorf("ingest: %w", err)
+	}
+	f := flusher{in: in}
+	// 멈추거나 실패해도 그때까지 넣은 파일은 저장한다
+	defer func() { err = errors.Join(err, f.flush(context.WithoutCancel(ctx))) }()
+	for i, path := range files {
+		if err := ctx.Err(); err != nil {
+			return stats, err
+		}
+		p, err := in.file(ctx, root, path)
+		p.Done, p.Total = i+1, len(files)
+		if err != nil {
+			return stats, fmt.Errorf("ingest: %s: %w", p.Path, err)
+		}
+		if !p.Skipped && (p.Err == nil || p.Chunks > 0) {
+			if err := f.added(ctx); err != nil {
+				return stats, err
+			}
+		}
+		stats.Files++
+		switch {
+		case p.Skipped:
+			stats.Skipped++
+		case p.Err != nil && p.Chunks == 0:
+			stats.Failed++
+		}
+		stats.Chunks += p.Chunks
+		if in.Progress != nil {
+			in.Progress(p)
+		}
+	}
+	if in.Checkpoint == nil {
+		return stats, nil
+	}
+
+	seen := make(map[string]bool, len(files))
+	for _, path := range files {
+		if rel, err := filepath.Rel(root, path); err == nil {
+			seen[filepath.ToSlash(rel)] = true
+		}
+	}
+	for _, rel := range in.Checkpoint.Paths() {
+		if seen[rel] {
+			continue
+		}
+		prev, _ := in.Checkpoint.Previous(rel)
+		if err := in.Store.Delete(ctx, chunkIDs(rel, prev.Chunks)...); err != nil {
+			return stats, fmt.Errorf("ingest: %s: %w", rel, err)
+		}
+		in.Checkpoint.Forget(rel)
+		if err := f.added(ctx); err != nil {
+			return stats, err
+		}
+		stats.Removed++
+		if in.Progress != nil {
+			in.Progress(Progress{Path: rel, Removed: true})
+		}
+	}
+	return stats, nil
+}
+
+// chunkIDs 는 docID 문서의 조각 n 개의 ID.
+func chunkIDs(docID string, n int) []string {
+	ids := make([]string, n)
+	for i := range ids {
+		ids[i] = chunker.Chunk{DocID: docID, Index: i}.ID()
+	}
+	return ids
+}
+
+// flusher 는 저장하지 않은 파일 수를 세어 Store 와 체크포인트를 함께 저장한다.
+type flusher struct {
+	in      *Ingester
+	pending int
+}
+
+// added 는 파일 하나를 넣거나 지웠다고 세고, 모인 수가 FlushEvery 가 되면 저장한다.
+func (f *flusher) added(ctx context.Context) error {
+	f.pending++
+	every := f.in.FlushEvery
+	if every <= 0 {
+		every = DefaultFlushEvery
+	}
+	if _, ok := f.in.Store.(Flusher); ok && f.pending < every {
+		return nil
+	}
+	return f.flush(ctx)
+}
+
+// flush 는 Store 를 먼저 저장하고, 성공해야 체크포인트를 저장한다.
+func (f *flusher) flush(ctx context.Context) error {
+	if f.pending == 0 {
+		return nil
+	}
+	if s, ok := f.in.Store.(Flusher); ok {
+		if err := s.Flush(ctx); err != nil {
+			return fmt.Errorf("ingest: flush store: %w", err)
+		}
+	}
+	f.pending = 0
+	if f.in.Checkpoint == nil {
+		return nil
+	}
+	return f.in.Checkpoint.Save()
+}
+
+// file 은 파일 하나를 넣는다. 돌려주는 오류는 Run 을 멈춰야 하는 오류뿐이다.
+func (in *Ingester) file(ctx context.Context, root, path string) (Progress, error) {
+	rel, err := filepath.Rel(root, path)
+	if err != nil {
+		return Progress{Path: path}, err
+	}
+	p := Progress{Path: filepath.ToSlash(rel)}
+	info, err := os.Stat(path)
+	if err != nil {
+		return p, err
+	}
+	if in.Checkpoint != nil && in.Checkpoint.Done(p.Path, info) {
+		p.Skipped = true
+		return p, nil
+	}
+
+	text, err := ExtractFile(path)
+	if err != nil {
+		p.Err = err
+		return p, nil
+	}
+	chunks := in.Chunker.Split(p.Path, text)
+	texts := make([]string, len(chunks))
+	for i, c := range chunks {
+		texts[i] = c.Text
+	}
+	var vecs [][]float32
+	if len(texts) > 0 {
+		vecs, err = in.Embedder.Embed(ctx, texts, embedding.RetrievalDocument)
+		var truncErr *embedding.TruncatedError
+		if errors.As(err, &truncErr) {
+			p.Err = err
+		} else if err != nil {
+			return p, err
+		}
+	}
+
+	// 바뀐 파일이면 예전 조각을 먼저 지운다 (조각 수가 줄었을 수 있다)
+	if in.Checkpoint != nil {
+		if prev, ok := in.Checkpoint.Previous(p.Path); ok && prev.Chunks > 0 {
+			if err := in.Store.Delete(ctx, chunkIDs(p.Path, prev.Chunks)...); err != nil {
+				return p, err
+			}
+		}
+	}
+	docs := make([]vectorstore.Document, len(chunks))
+	for i, c := range chunks {
+		docs[i] = vectorstore.Document{
+			ID: c.ID(), Content: c.Text, Vector: vecs[i], DocID: c.DocID, Start: c.Start, End: c.End,
+		}
+	}
+	if err := in.Store.Upsert(ctx, docs...); err != nil {
+		return p, err
+	}
+	p.Chunks = len(chunks)
+	if in.Checkpoint != nil {
+		in.Checkpoint.Set(p.Path, info, len(chunks))
+	}
+	return p, nil
+}
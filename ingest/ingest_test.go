@@ -0,0 +1,249 @@
+package ingest
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"vertex/chunker"
+	"vertex/embedding"
+	"vertex/vectorstore"
+)
+
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// countingEmbedder 는 임베딩한 글의 수를 센다. failAfter 번째 호출부터는 실패한다 (0 이면 실패하지 않음).
+type countingEmbedder struct {
+	embedding.Embedder
+	calls, texts, failAfter int
+}
+
+func (e *countingEmbedder) Embed(ctx context.Context, texts []string, taskType embedding.TaskType) ([][]float32, error) {
+	e.calls++
+	if e.failAfter > 0 && e.calls >= e.failAfter {
+		return nil, errors.New("quota exceeded")
+	}
+	e.texts += len(texts)
+	return e.Embedder.Embed(ctx, texts, taskType)
+}
+
+func TestRun(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"a.txt":          "첫 번째 문서입니다. 두 문장이에요.",
+		"docs/b.md":      "# 제목\n\n마크다운 문서.",
+		"docs/c.html":    "<p>HTML 문서</p>",
+		"docs/bad.pdf":   "깨진 PDF",
+		"docs/skip.docx": "지원하지 않는 형식",
+		".git/d.txt":     "숨은 디렉터리",
+	})
+	cpPath := filepath.Join(t.TempDir(), "checkpoint.json")
+	cp, err := LoadCheckpoint(cpPath, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	emb := &countingEmbedder{Embedder: embedding.NewLocal(32)}
+	store := vectorstore.NewMemory()
+	var progress []Progress
+	in := &Ingester{
+		Chunker:    &chunker.Sentences{Size: 12},
+		Embedder:   emb,
+		Store:      store,
+		Checkpoint: cp,
+		Progress:   func(p Progress) { progress = append(progress, p) },
+	}
+	stats, err := in.Run(context.Background(), dir)
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if want := (Stats{Files: 4, Failed: 1, Chunks: 5}); stats != want {
+		t.Errorf("Run() = %+v, want %+v", stats, want)
+	}
+	var paths []string
+	for i, p := range progress {
+		paths = append(paths, p.Path)
+		if p.Done != i+1 || p.Total != 4 {
+			t.Errorf("progress %d = %d/%d", i, p.Done, p.Total)
+		}
+	}
+	if want := []string{"a.txt", "docs/b.md", "docs/bad.pdf", "docs/c.html"}; !slices.Equal(paths, want) {
+		t.Errorf("progress paths = %v, want %v", paths, want)
+	}
+	if progress[2].Err == nil {
+		t.Error("bad.pdf reported no error")
+	}
+	if store.Len() != 5 {
+		t.Errorf("store has %d chunks, want 5", store.Len())
+	}
+	res, err := store.Search(context.Background(), make([]float32, 32), vectorstore.SearchOptions{K: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.ContainsFunc(res, func(r vectorstore.Result) bool { return r.ID == "a.txt#1" }) {
+		t.Errorf("results %v have no a.txt#1", res)
+	}
+
+	// 체크포인트를 다시 읽어 이어 하면 아무것도 임베딩하지 않는다. 깨진 파일만 다시 시도한다.
+	cp, err = LoadCheckpoint(cpPath, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	in.Checkpoint = cp
+	emb.texts = 0
+	stats, err = in.Run(context.Background(), dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (Stats{Files: 4, Skipped: 3, Failed: 1}); stats != want || emb.texts != 0 {
+		t.Errorf("resumed Run() = %+v with %d texts embedded, want %+v and none", stats, emb.texts, want)
+	}
+
+	// 바뀐 파일만 예전 조각을 지우고 다시 넣는다
+	later := time.Now().Add(time.Hour)
+	writeFiles(t, dir, map[string]string{"a.txt": "바뀐 문서."})
+	if err := os.Chtimes(filepath.Join(dir, "a.txt"), later, later); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := in.Run(context.Background(), dir); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := store.Get("a.txt#1"); emb.texts != 1 || ok || store.Len() != 4 {
+		t.Errorf("after change: %d texts embedded, %d chunks, a.txt#1 kept = %v", emb.texts, store.Len(), ok)
+	}
+
+	// 지운 파일은 조각과 체크포인트 기록이 모두 빠진다
+	if err := os.Remove(filepath.Join(dir, "docs", "b.md")); err != nil {
+		t.Fatal(err)
+	}
+	progress = nil
+	stats, err = in.Run(context.Background(), dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Removed != 1 || len(progress) == 0 || !progress[len(progress)-1].Removed {
+		t.Errorf("Run() after remove = %+v, progress %+v", stats, progress)
+	}
+	for _, d := range store.Documents() {
+		if d.DocID == "docs/b.md" {
+			t.Errorf("chunk %s of removed file kept", d.ID)
+		}
+	}
+	if cp, err := LoadCheckpoint(cpPath, "test"); err != nil || len(cp.Files) != 2 {
+		t.Errorf("checkpoint after remove = %v, %v, want a.txt and docs/c.html", cp.Files, err)
+	}
+}
+
+func TestRunResumesAfterFailure(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"1.txt": "하나.", "2.txt": "둘.", "3.txt": "셋."})
+	cpPath := filepath.Join(t.TempDir(), "checkpoint.json")
+	cp, err := LoadCheckpoint(cpPath, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 두 번째 파일에서 임베딩이 실패하면 멈추고, 첫 파일만 체크포인트에 남는다
+	emb := &countingEmbedder{Embedder: embedding.NewLocal(8), failAfter: 2}
+	in := &Ingester{Chunker: &chunker.Sentences{}, Embedder: emb, Store: vectorstore.NewMemory(), Checkpoint: cp}
+	if _, err := in.Run(context.Background(), dir); err == nil {
+		t.Fatal("Run() error = nil")
+	}
+
+	cp, err = LoadCheckpoint(cpPath, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cp.Files) != 1 {
+		t.Fatalf("checkpoint has %v, want only 1.txt", cp.Files)
+	}
+	emb.failAfter, emb.texts = 0, 0
+	in.Checkpoint = cp
+	stats, err := in.Run(context.Background(), dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Skipped != 1 || emb.texts != 2 {
+		t.Errorf("resumed Run() = %+v with %d texts embedded, want 1 skipped and 2 embedded", stats, emb.texts)
+	}
+}
+
+// flushStore 는 Flush 할 때 넣은 조각 수를 saved 에 기록하는 Flusher.
+type flushStore struct {
+	*vectorstore.Memory
+	saved []int
+}
+
+func (s *flushStore) Flush(ctx context.Context) error {
+	s.saved = append(s.saved, s.Len())
+	return nil
+}
+
+// TestRunFlush 는 Flusher 저장소를 FlushEvery 파일마다 저장하고, 저장한 파일까지만 체크포인트에 남기는지 확인한다.
+func TestRunFlush(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"1.txt": "하나.", "2.txt": "둘.", "3.txt": "셋.", "4.txt": "넷.", "5.txt": "다섯."})
+	cpPath := filepath.Join(t.TempDir(), "checkpoint.json")
+	cp, err := LoadCheckpoint(cpPath, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 네 번째 파일에서 멈추면 두 파일째에 한 번, 멈출 때 한 번 저장한다
+	emb := &countingEmbedder{Embedder: embedding.NewLocal(8), failAfter: 4}
+	store := &flushStore{Memory: vectorstore.NewMemory()}
+	in := &Ingester{Chunker: &chunker.Sentences{}, Embedder: emb, Store: store, Checkpoint: cp, FlushEvery: 2}
+	if _, err := in.Run(context.Background(), dir); err == nil {
+		t.Fatal("Run() error = nil")
+	}
+	if !slices.Equal(store.saved, []int{2, 3}) {
+		t.Errorf("flushed with %v chunks, want [2 3]", store.saved)
+	}
+	if cp, err = LoadCheckpoint(cpPath, "test"); err != nil || len(cp.Files) != 3 {
+		t.Fatalf("checkpoint has %v, %v, want 3 files", cp.Files, err)
+	}
+
+	// 이어 하면 남은 두 파일을 넣고 끝날 때 한 번 저장한다
+	emb.failAfter = 0
+	store.saved = nil
+	in.Checkpoint = cp
+	if _, err := in.Run(context.Background(), dir); err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(store.saved, []int{5}) {
+		t.Errorf("resumed run flushed with %v chunks, want [5]", store.saved)
+	}
+}
+
+func TestLoadCheckpointConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "checkpoint.json")
+	cp, err := LoadCheckpoint(path, "model-a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(filepath.Dir(path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cp.Mark("x.txt", info, 1); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadCheckpoint(path, "model-b"); !errors.Is(err, ErrCheckpointConfig) {
+		t.Errorf("LoadCheckpoint(other config) error = %v, want ErrCheckpointConfig", err)
+	}
+}
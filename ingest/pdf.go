@@ -0,0 +1,185 @@
+package ingest
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"github.com/ledongthuc/pdf"
+)
+
+// ErrNoPDFText 는 PDF 에서 글자를 하나도 찾지 못했을 때의 오류 (스캔한 이미지 PDF 등).
+var ErrNoPDFText = errors.New("ingest: no text found in pdf")
+
+// ErrEncryptedPDF 는 암호를 알아야 열 수 있는 PDF 의 오류.
+var ErrEncryptedPDF = errors.New("ingest: pdf is password protected")
+
+// maxFormDepth 는 폼 XObject 안의 폼을 따라 들어가는 최대 깊이. 서로를 부르는 폼에서 멈추게 한다.
+const maxFormDepth = 8
+
+// ExtractPDF 는 PDF 의 페이지마다 글자를 뽑는다. 페이지 사이는 빈 줄로 나눈다.
+//
+// 파일 구조는 github.com/ledongthuc/pdf 가 읽는다: 상호 참조 표와 스트림, 압축된 객체 스트림,
+// FlateDecode 스트림, 그리고 빈 사용자 암호로 잠근 128비트 RC4·AES 암호화. 여기서는 페이지와 폼
+// XObject 의 내용 스트림에서 글자 연산자(Tj, TJ, ', ")만 따라가며, 글자 코드는 Tf 로 고른
+// 글꼴의 인코딩이나 ToUnicode CMap 으로 바꾼다. 줄 이동 연산자와 텍스트 블록의 끝에서 줄을
+// 바꿀 뿐 글자 위치로 단이나 표를 되살리지는 않는다. 읽지 못하는 내용 스트림(지원하지 않는
+// 필터 등)은 건너뛴다. 상호 참조 표가 망가진 파일은 복구하지 않고 오류를 돌려준다.
+func ExtractPDF(data []byte) (text string, err error) {
+	// pdf 패키지는 망가진 파일에서 panic 한다
+	defer func() {
+		if r := recover(); r != nil {
+			text, err = "", fmt.Errorf("ingest: malformed pdf: %v", r)
+		}
+	}()
+	r, err := pdf.NewReader(bytes.NewReader(data), int64(len(data)))
+	switch {
+	case errors.Is(err, pdf.ErrInvalidPassword):
+		return "", ErrEncryptedPDF
+	case err != nil:
+		return "", fmt.Errorf("ingest: read pdf: %w", err)
+	}
+
+	var pages []string
+	for i := 1; i <= r.NumPage(); i++ {
+		p := r.Page(i)
+		if p.V.IsNull() {
+			continue
+		}
+		var w pdfText
+		w.content(p.V.Key("Contents"), p.Resources(), 0)
+		if s := strings.TrimSpace(w.sb.String()); s != "" {
+			pages = append(pages, s)
+		}
+	}
+	if len(pages) == 0 {
+		return "", ErrNoPDFText
+	}
+	return strings.Join(pages, "\n\n"), nil
+}
+
+// pdfText 는 내용 스트림의 글자 연산자를 따라 글자를 모은다.
+type pdfText struct {
+	sb strings.Builder
+}
+
+// newline 은 줄 끝이 아니면 줄을 바꾼다.
+func (t *pdfText) newline() {
+	if s := t.sb.String(); s != "" && !strings.HasSuffix(s, "\n") {
+		t.sb.WriteByte('\n')
+	}
+}
+
+// content 는 내용 스트림 strm(또는 그 배열)의 글자를 쓴다. 글꼴과 폼은 res 에서 찾는다.
+func (t *pdfText) content(strm, res pdf.Value, depth int) {
+	if strm.Kind() == pdf.Array {
+		for i := 0; i < strm.Len(); i++ {
+			t.content(strm.Index(i), res, depth)
+		}
+		return
+	}
+	if strm.Kind() != pdf.Stream {
+		return
+	}
+	// 읽지 못한 스트림은 그때까지 쓴 글자만 남기고 건너뛴다
+	defer func() {
+		if recover() != nil {
+			t.newline()
+		}
+	}()
+
+	encoders := make(map[string]pdf.TextEncoding)
+	var enc pdf.TextEncoding
+	show := func(v pdf.Value) {
+		if v.Kind() != pdf.String {
+			return
+		}
+		if enc != nil {
+			t.sb.WriteString(enc.Decode(v.RawString()))
+		} else {
+			t.sb.WriteString(pdfString(v.RawString()))
+		}
+	}
+	pdf.Interpret(strm, func(stk *pdf.Stack, op string) {
+		args := make([]pdf.Value, stk.Len())
+		for i := len(args) - 1; i >= 0; i-- {
+			args[i] = stk.Pop()
+		}
+		last := pdf.Value{}
+		if len(args) > 0 {
+			last = args[len(args)-1]
+		}
+		switch op {
+		case "Tf":
+			if len(args) != 2 {
+				return
+			}
+			name := args[0].Name()
+			e, ok := encoders[name]
+			if !ok {
+				// 글꼴을 찾지 못하면 nil 로 두어 pdfString 으로 읽는다
+				if f := res.Key("Font").Key(name); f.Kind() == pdf.Dict {
+					e = pdf.Font{V: f}.Encoder()
+				}
+				encoders[name] = e
+			}
+			enc = e
+		case "Tj":
+			show(last)
+		case "'", `"`:
+			t.newline()
+			show(last)
+		case "TJ":
+			for i := 0; i < last.Len(); i++ {
+				v := last.Index(i)
+				// 글자 폭의 1/5 이상 벌어지면 낱말 사이로 본다 (단위는 1/1000 em)
+				if v.Kind() != pdf.String && v.Float64() <= -200 && i > 0 {
+					t.sb.WriteByte(' ')
+				}
+				show(v)
+			}
+		case "T*", "ET":
+			t.newline()
+		case "Td", "TD":
+			// 세로로 움직이면 새 줄
+			if len(args) == 2 && args[1].Float64() != 0 {
+				t.newline()
+			}
+		case "Do":
+			x := res.Key("XObject").Key(last.Name())
+			if x.Key("Subtype").Name() != "Form" || depth >= maxFormDepth {
+				return
+			}
+			formRes := x.Key("Resources")
+			if formRes.IsNull() {
+				formRes = res
+			}
+			t.newline()
+			t.content(x, formRes, depth+1)
+		}
+	})
+	t.newline()
+}
+
+// pdfString 은 글꼴 없이 쓴 문자열을 해석한다.
+// BOM 이 있으면 UTF-16BE, 올바른 UTF-8 이면 그대로, 아니면 Latin-1 로 본다.
+func pdfString(s string) string {
+	if strings.HasPrefix(s, "\xfe\xff") {
+		u := make([]uint16, 0, len(s)/2)
+		for i := 2; i+1 < len(s); i += 2 {
+			u = append(u, uint16(s[i])<<8|uint16(s[i+1]))
+		}
+		return string(utf16.Decode(u))
+	}
+	if utf8.ValidString(s) {
+		return s
+	}
+	r := make([]rune, len(s))
+	for i := 0; i < len(s); i++ {
+		r[i] = rune(s[i])
+	}
+	return string(r)
+}
@@ -0,0 +1,332 @@
+package ingest
+
+import (
+	"bytes"
+	"compress/zlib"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/md5"
+	"crypto/rc4"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// testPDF 는 PDF 도구가 쓰는 구조 그대로 테스트용 PDF 를 만든다: 상호 참조 표나 스트림,
+// 객체 스트림, FlateDecode, 표준 보안 처리기의 RC4·AES 암호화.
+type testPDF struct {
+	// XRefStream 이면 상호 참조 표 대신 상호 참조 스트림(PDF 1.5)을 쓴다.
+	XRefStream bool
+	// ObjStm 이면 스트림이 아닌 객체를 객체 스트림에 넣는다. XRefStream 이 필요하다.
+	ObjStm bool
+	// Compress 면 스트림을 FlateDecode 로 압축한다.
+	Compress bool
+	// Encrypt 는 "", "rc4-128", "aes-128". UserPassword 는 여는 암호.
+	Encrypt      string
+	UserPassword string
+}
+
+// pdfObject 는 객체 하나. stream 이 nil 이 아니면 dict 는 /Length 와 /Filter 를 뺀 스트림 사전의 안쪽이다.
+type pdfObject struct {
+	dict   string
+	stream []byte
+}
+
+// passwordPad 는 표준 보안 처리기가 암호를 32바이트로 채우는 값.
+var passwordPad = []byte("\x28\xbf\x4e\x5e\x4e\x75\x8a\x41\x64\x00\x4e\x56\xff\xfa\x01\x08" +
+	"\x2e\x2e\x00\xb6\xd0\x68\x3e\x80\x2f\x0c\xa9\xfe\x64\x53\x69\x7a")
+
+var pdfID = []byte("0123456789abcdef")
+
+func padPassword(pw string) []byte {
+	return append([]byte(pw), passwordPad[:32-len(pw)]...)
+}
+
+func rc4XOR(key, data []byte) []byte {
+	c, _ := rc4.NewCipher(key)
+	out := make([]byte, len(data))
+	c.XORKeyStream(out, data)
+	return out
+}
+
+// encryption 은 PDF 32000-1 7.6.3 의 알고리즘 2, 3, 5 로 키와 /Encrypt 사전을 만든다 (R3, R4).
+func (o testPDF) encryption() (key []byte, dict string) {
+	const p = -4
+	owner := md5.Sum(padPassword("owner"))
+	ownerKey := owner[:]
+	for range 50 {
+		s := md5.Sum(ownerKey)
+		ownerKey = s[:]
+	}
+	O := rc4XOR(ownerKey, padPassword(o.UserPassword))
+	for i := 1; i <= 19; i++ {
+		k := bytes.Clone(ownerKey)
+		for j := range k {
+			k[j] ^= byte(i)
+		}
+		O = rc4XOR(k, O)
+	}
+
+	h := md5.New()
+	h.Write(padPassword(o.UserPassword))
+	h.Write(O)
+	binary.Write(h, binary.LittleEndian, int32(p))
+	h.Write(pdfID)
+	key = h.Sum(nil)
+	for range 50 {
+		s := md5.Sum(key)
+		key = s[:]
+	}
+
+	u := md5.Sum(append(bytes.Clone(passwordPad), pdfID...))
+	U := rc4XOR(key, u[:])
+	for i := 1; i <= 19; i++ {
+		k := bytes.Clone(key)
+		for j := range k {
+			k[j] ^= byte(i)
+		}
+		U = rc4XOR(k, U)
+	}
+	U = append(U, passwordPad[:16]...)
+
+	if o.Encrypt == "aes-128" {
+		dict = fmt.Sprintf("<< /Filter /Standard /V 4 /R 4 /Length 128 /P %d /O <%x> /U <%x>"+
+			" /CF << /StdCF << /CFM /AESV2 /AuthEvent /DocOpen /Length 16 >> >> /StmF /StdCF /StrF /StdCF >>", p, O, U)
+	} else {
+		dict = fmt.Sprintf("<< /Filter /Standard /V 2 /R 3 /Length 128 /P %d /O <%x> /U <%x> >>", p, O, U)
+	}
+	return key, dict
+}
+
+// encryptStream 은 num 객체의 스트림 data 를 암호화한다 (알고리즘 1).
+func (o testPDF) encryptStream(key []byte, num int, data []byte) []byte {
+	h := md5.New()
+	h.Write(key)
+	h.Write([]byte{byte(num), byte(num >> 8), byte(num >> 16), 0, 0})
+	if o.Encrypt != "aes-128" {
+		return rc4XOR(h.Sum(nil), data)
+	}
+	h.Write([]byte("sAlT"))
+	block, _ := aes.NewCipher(h.Sum(nil))
+	pad := aes.BlockSize - len(data)%aes.BlockSize
+	data = append(bytes.Clone(data), bytes.Repeat([]byte{byte(pad)}, pad)...)
+	iv := []byte("fedcba9876543210")
+	out := append(bytes.Clone(iv), make([]byte, len(data))...)
+	cipher.NewCBCEncrypter(block, iv).CryptBlocks(out[aes.BlockSize:], data)
+	return out
+}
+
+// build 는 objects 를 1번부터 번호를 붙여 PDF 로 쓴다. root 는 카탈로그의 번호.
+func (o testPDF) build(objects []pdfObject, root int) []byte {
+	var (
+		b       bytes.Buffer
+		key     []byte
+		encDict string
+	)
+	if o.Encrypt != "" {
+		key, encDict = o.encryption()
+	}
+	// xref[i] 는 i 번 객체의 {종류, 위치 또는 객체 스트림 번호, 객체 스트림 안의 순서}
+	type entry struct{ kind, field2, field3 int }
+	xref := make([]entry, 1, len(objects)+4)
+	xref[0] = entry{0, 0, 65535}
+
+	stream := func(num int, dict string, data []byte) {
+		if o.Compress {
+			var z bytes.Buffer
+			w := zlib.NewWriter(&z)
+			w.Write(data)
+			w.Close()
+			data = z.Bytes()
+			dict += " /Filter /FlateDecode"
+		}
+		if key != nil {
+			data = o.encryptStream(key, num, data)
+		}
+		fmt.Fprintf(&b, "%d 0 obj\n<< %s /Length %d >>\nstream\n%s\nendstream\nendobj\n", num, dict, len(data), data)
+	}
+
+	b.WriteString("%PDF-1.5\n%\xe2\xe3\xcf\xd3\n")
+	var packed []int
+	for i, obj := range objects {
+		num := i + 1
+		xref = append(xref, entry{1, b.Len(), 0})
+		switch {
+		case obj.stream != nil:
+			stream(num, obj.dict, obj.stream)
+		case o.ObjStm:
+			packed = append(packed, num)
+		default:
+			fmt.Fprintf(&b, "%d 0 obj\n%s\nendobj\n", num, obj.dict)
+		}
+	}
+	trailer := fmt.Sprintf("/Root %d 0 R /ID [<%x> <%x>]", root, pdfID, pdfID)
+	if key != nil {
+		num := len(xref)
+		xref = append(xref, entry{1, b.Len(), 0})
+		fmt.Fprintf(&b, "%d 0 obj\n%s\nendobj\n", num, encDict)
+		trailer += fmt.Sprintf(" /Encrypt %d 0 R", num)
+	}
+	if len(packed) > 0 {
+		num := len(xref)
+		var header, body bytes.Buffer
+		for i, n := range packed {
+			fmt.Fprintf(&header, "%d %d ", n, body.Len())
+			body.WriteString(objects[n-1].dict + "\n")
+			xref[n] = entry{2, num, i}
+		}
+		xref = append(xref, entry{1, b.Len(), 0})
+		stream(num, fmt.Sprintf("/Type /ObjStm /N %d /First %d", len(packed), header.Len()), append(header.Bytes(), body.Bytes()...))
+	}
+
+	start := b.Len()
+	if o.XRefStream {
+		// 상호 참조 스트림 자신도 항목이 있어야 하며, 암호화하지 않는다
+		num := len(xref)
+		xref = append(xref, entry{1, start, 0})
+		var data bytes.Buffer
+		for _, e := range xref {
+			data.WriteByte(byte(e.kind))
+			binary.Write(&data, binary.BigEndian, uint32(e.field2))
+			binary.Write(&data, binary.BigEndian, uint16(e.field3))
+		}
+		fmt.Fprintf(&b, "%d 0 obj\n<< /Type /XRef /Size %d /W [1 4 2] %s /Length %d >>\nstream\n%s\nendstream\nendobj\n",
+			num, len(xref), trailer, data.Len(), data.Bytes())
+	} else {
+		fmt.Fprintf(&b, "xref\n0 %d\n", len(xref))
+		for i, e := range xref {
+			if i == 0 {
+				b.WriteString("0000000000 65535 f \n")
+				continue
+			}
+			fmt.Fprintf(&b, "%010d 00000 n \n", e.field2)
+		}
+		fmt.Fprintf(&b, "trailer\n<< /Size %d %s >>\n", len(xref), trailer)
+	}
+	fmt.Fprintf(&b, "startxref\n%d\n%%%%EOF\n", start)
+	return b.Bytes()
+}
+
+// toUnicodeCMap 은 <0001> <0002> 를 "안녕" 으로, <0010>~<0012> 를 "가각갂" 으로 바꾼다.
+const toUnicodeCMap = `/CIDInit /ProcSet findresource begin
+12 dict begin
+begincmap
+/CMapName /Adobe-Identity-UCS def
+/CMapType 2 def
+1 begincodespacerange
+<0000> <FFFF>
+endcodespacerange
+2 beginbfchar
+<0001> <C548>
+<0002> <B155>
+endbfchar
+1 beginbfrange
+<0010> <0012> <AC00>
+endbfrange
+endcmap
+CMapName currentdict /CMap defineresource pop
+end
+end`
+
+// samplePDF 는 두 쪽짜리 문서의 객체들. 첫 쪽은 Helvetica 로 쓴 영어, 둘째 쪽은 ToUnicode CMap 이
+// 있는 한글 글꼴과 폼 XObject 를 쓰며, 내용 스트림이 둘로 나뉘어 있다. 카탈로그는 1번.
+func samplePDF(page1 string) []pdfObject {
+	return []pdfObject{
+		{dict: "<< /Type /Catalog /Pages 2 0 R >>"},
+		// 첫 쪽은 /Pages 의 /Resources 를 물려받는다
+		{dict: "<< /Type /Pages /Kids [3 0 R 4 0 R] /Count 2 /Resources << /Font << /F1 5 0 R /F2 6 0 R >> >> >>"},
+		{dict: "<< /Type /Page /Parent 2 0 R /MediaBox [0 0 612 792] /Contents 7 0 R >>"},
+		{dict: "<< /Type /Page /Parent 2 0 R /MediaBox [0 0 612 792] /Contents [8 0 R 9 0 R]" +
+			" /Resources << /Font << /F1 5 0 R >> /XObject << /Fm1 10 0 R >> >> >>"},
+		{dict: "<< /Type /Font /Subtype /Type0 /BaseFont /NanumGothic /Encoding /Identity-H" +
+			" /DescendantFonts [12 0 R] /ToUnicode 11 0 R >>"},
+		{dict: "<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>"},
+		{dict: "", stream: []byte(page1)},
+		{dict: "", stream: []byte("BT /F1 12 Tf 72 720 Td <00010002> Tj 0 -14 Td <001000110012> Tj ET")},
+		{dict: "", stream: []byte("q 1 0 0 1 0 0 cm /Fm1 Do Q")},
+		// 폼은 자기 /Resources 의 글꼴 이름을 쓴다
+		{dict: "/Type /XObject /Subtype /Form /BBox [0 0 612 792] /Resources << /Font << /F9 6 0 R >> >>",
+			stream: []byte("BT /F9 10 Tf 72 100 Td (caf\\351) Tj ET")},
+		{dict: "", stream: []byte(toUnicodeCMap)},
+		{dict: "<< /Type /Font /Subtype /CIDFontType2 /BaseFont /NanumGothic" +
+			" /CIDSystemInfo << /Registry (Adobe) /Ordering (Identity) /Supplement 0 >> >>"},
+	}
+}
+
+const samplePage1 = `BT /F2 12 Tf 14 TL 72 720 Td (Hello \(PDF\)) Tj 0 -14 Td [(Wor) 20 (ld) -300 (again)] TJ T* (next line) Tj ET`
+
+const sampleText = "Hello (PDF)\nWorld again\nnext line\n\n안녕\n가각갂\ncafé"
+
+func TestExtractPDF(t *testing.T) {
+	tests := []struct {
+		name string
+		pdf  testPDF
+	}{
+		{"xref table", testPDF{}},
+		{"compressed", testPDF{Compress: true}},
+		{"xref stream", testPDF{XRefStream: true, Compress: true}},
+		{"object stream", testPDF{XRefStream: true, ObjStm: true, Compress: true}},
+		{"rc4", testPDF{Encrypt: "rc4-128"}},
+		{"aes", testPDF{Encrypt: "aes-128", Compress: true}},
+		{"aes object stream", testPDF{Encrypt: "aes-128", XRefStream: true, ObjStm: true, Compress: true}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := tt.pdf.build(samplePDF(samplePage1), 1)
+			if tt.pdf.Encrypt != "" && bytes.Contains(data, []byte("Hello")) {
+				t.Fatal("encrypted content stream in plain text")
+			}
+			got, err := ExtractPDF(data)
+			if err != nil || got != sampleText {
+				t.Errorf("ExtractPDF() = %q, %v, want %q", got, err, sampleText)
+			}
+		})
+	}
+
+	t.Run("utf-16 string without font", func(t *testing.T) {
+		data := testPDF{}.build(samplePDF("BT <FEFFD55CAE00> Tj ET"), 1)
+		if got, err := ExtractPDF(data); err != nil || !strings.HasPrefix(got, "한글\n\n") {
+			t.Errorf("ExtractPDF() = %q, %v, want 한글 first", got, err)
+		}
+	})
+
+	t.Run("unreadable stream is skipped", func(t *testing.T) {
+		objects := samplePDF(samplePage1)
+		objects[6].dict = "/Filter /LZWDecode"
+		if got, err := ExtractPDF(testPDF{}.build(objects, 1)); err != nil || got != "안녕\n가각갂\ncafé" {
+			t.Errorf("ExtractPDF() = %q, %v", got, err)
+		}
+	})
+}
+
+func TestExtractPDFErrors(t *testing.T) {
+	if _, err := ExtractPDF([]byte("hello")); err == nil {
+		t.Error("not a pdf: error = nil")
+	}
+
+	objects := samplePDF("0 0 1 rg 0 0 100 100 re f")
+	objects[7].stream = []byte("0 g 0 0 10 10 re f")
+	objects[9].stream = []byte("0 G 0 0 m 10 10 l S")
+	if _, err := ExtractPDF(testPDF{}.build(objects, 1)); !errors.Is(err, ErrNoPDFText) {
+		t.Errorf("no text: error = %v, want ErrNoPDFText", err)
+	}
+
+	locked := testPDF{Encrypt: "aes-128", UserPassword: "secret"}.build(samplePDF(samplePage1), 1)
+	if _, err := ExtractPDF(locked); !errors.Is(err, ErrEncryptedPDF) {
+		t.Errorf("password protected: error = %v, want ErrEncryptedPDF", err)
+	}
+
+	// 망가진 파일은 panic 하지 않고 오류가 된다
+	good := testPDF{XRefStream: true, ObjStm: true, Compress: true}.build(samplePDF(samplePage1), 1)
+	for _, data := range [][]byte{
+		good[:len(good)/2],
+		bytes.Replace(good, []byte("/Type /ObjStm"), []byte("/Type /ObjSt "), 1),
+		bytes.Replace(good, []byte("startxref\n"), []byte("startxref\n9"), 1),
+	} {
+		if _, err := ExtractPDF(data); err == nil {
+			t.Errorf("corrupt pdf: error = nil")
+		}
+	}
+}
@@ -2,21 +2,83 @@ package parallel
 
 import (
 	"context"
-	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
+	"sort"
+	"strings"
+	"time"
 
 	"cloud.google.com/go/vertexai/genai"
+	"google.golang.org/api/option"
+
+	"vertex/response"
+	"vertex/tools"
 )
 
+// weatherArgs is the argument struct of getCurrentWeather; its tags generate the function schema.
+type weatherArgs struct {
+	Location string `json:"location" schema:"required" description:"The location for which to get the weather. It can be a city name, a city name and state, or a zip code. Examples: 'San Francisco', 'San Francisco, CA', '95616', etc."`
+}
+
+// execOptions bounds how many weather lookups run at once and how long each may take.
+// A flaky backend is retried, and whatever still fails is reported to the model as an error
+// next to the successful results instead of aborting the whole answer.
+var execOptions = tools.ExecOptions{
+	Workers:      4,
+	Timeout:      10 * time.Second,
+	Policy:       tools.RetryFailed,
+	Retries:      2,
+	RetryBackoff: 200 * time.Millisecond,
+}
+
+// newRegistry registers getCurrentWeather, backed by provider, so the model's calls can be dispatched by name.
+func newRegistry(provider WeatherProvider) *tools.Registry {
+	registry := tools.NewRegistry()
+	registry.MustRegister(tools.MustNewTool("getCurrentWeather", "Get the current weather in a given location",
+		func(ctx context.Context, args weatherArgs) (map[string]any, error) {
+			weather, err := provider.CurrentWeather(ctx, args.Location)
+			if err != nil {
+				return nil, err
+			}
+			return weather.toMap(), nil
+		}))
+	return registry
+}
+
+// weatherPrompt asks for the weather in every location of the map, ordered by key.
+func weatherPrompt(location map[string]string) (genai.Text, error) {
+	keys := make([]string, 0, len(location))
+	for k := range location {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var places []string
+	for _, k := range keys {
+		if v := strings.TrimSpace(location[k]); v != "" {
+			places = append(places, v)
+		}
+	}
+	if len(places) == 0 {
+		return "", errors.New("no locations to query")
+	}
+	return genai.Text(fmt.Sprintf("Get weather details in %s?", strings.Join(places, " and "))), nil
+}
+
 // parallelFunctionCalling shows how to execute multiple function calls in parallel
 // and return their results to the model for generating a complete response.
-func parallelFunctionCalling(w io.Writer, projectID string, location map[string]string, modelName string) error {
+// The values of location are the places asked about; provider serves their weather.
+// opts are passed on to genai.NewClient, e.g. to point it at a fake backend in tests.
+func parallelFunctionCalling(w io.Writer, projectID string, location map[string]string, modelName string, provider WeatherProvider, opts ...option.ClientOption) error {
 	// location = "us-central1"
 	// modelName = "gemini-1.5-flash-002"
+	prompt, err := weatherPrompt(location)
+	if err != nil {
+		return err
+	}
+
 	ctx := context.Background()
-	client, err := genai.NewClient(ctx, projectID, "us-central1")
+	client, err := genai.NewClient(ctx, projectID, "us-central1", opts...)
 	if err != nil {
 		return fmt.Errorf("failed to create GenAI client: %w", err)
 	}
@@ -26,99 +88,46 @@ func parallelFunctionCalling(w io.Writer, projectID string, location map[string]
 	// Set temperature to 0.0 for maximum determinism in function calling.
 	model.SetTemperature(0.0)
 
-	funcName := "getCurrentWeather"
-	funcDecl := &genai.FunctionDeclaration{
-		Name:        funcName,
-		Description: "Get the current weather in a given location",
-		Parameters: &genai.Schema{
-			Type: genai.TypeObject,
-			Properties: map[string]*genai.Schema{
-				"location": {
-					Type: genai.TypeString,
-					Description: "The location for which to get the weather. " +
-						"It can be a city name, a city name and state, or a zip code. " +
-						"Examples: 'San Francisco', 'San Francisco, CA', '95616', etc.",
-				},
-			},
-			Required: []string{"location"},
-		},
-	}
 	// Add the weather function to our model toolbox.
-	model.Tools = []*genai.Tool{
-		{
-			FunctionDeclarations: []*genai.FunctionDeclaration{funcDecl},
-		},
-	}
-
-	prompt := genai.Text("Get weather details in New Delhi and San Francisco?")
-	resp, err := model.GenerateContent(ctx, prompt)
+	registry := newRegistry(provider)
+	model.Tools = registry.Tools()
+	chat := model.StartChat()
 
+	res, err := response.Read(chat.SendMessage(ctx, prompt))
 	if err != nil {
 		return fmt.Errorf("failed to generate content: %w", err)
 	}
-	if len(resp.Candidates) == 0 {
-		return errors.New("got empty response from model")
-	} else if len(resp.Candidates[0].FunctionCalls()) == 0 {
+	if len(res.FunctionCalls) == 0 {
 		return errors.New("got no function call suggestions from model")
 	}
 
-	// In a production environment, consider adding validations for function names and arguments.
-	for _, fnCall := range resp.Candidates[0].FunctionCalls() {
+	for _, fnCall := range res.FunctionCalls {
 		fmt.Fprintf(w, "The model suggests to call the function %q with args: %v\n", fnCall.Name, fnCall.Args)
 		// Example response:
 		// The model suggests to call the function "getCurrentWeather" with args: map[location:New Delhi]
 		// The model suggests to call the function "getCurrentWeather" with args: map[location:San Francisco]
 	}
 
-	// Use synthetic data to simulate responses from the external API.
-	// In a real application, this would come from an actual weather API.
-	mockAPIResp1, err := json.Marshal(map[string]string{
-		"location":         "New Delhi",
-		"temperature":      "42",
-		"temperature_unit": "C",
-		"description":      "Hot and humid",
-		"humidity":         "65",
-	})
+	// The function calls don't have to be chained, so they run concurrently against the registered
+	// handlers and their results go back to Gemini at once, in the order the model asked for them.
+	summary, err := registry.ExecuteBatch(ctx, res.FunctionCalls, execOptions)
 	if err != nil {
-		return fmt.Errorf("failed to marshal function response to JSON: %w", err)
+		return fmt.Errorf("failed to execute function calls: %w", err)
 	}
-
-	mockAPIResp2, err := json.Marshal(map[string]string{
-		"location":         "San Francisco",
-		"temperature":      "36",
-		"temperature_unit": "F",
-		"description":      "Cold and cloudy",
-		"humidity":         "N/A",
-	})
-	if err != nil {
-		return fmt.Errorf("failed to marshal function response to JSON: %w", err)
-	}
-
-	// Note, that the function calls don't have to be chained. We can obtain both responses in parallel
-	// and return them to Gemini at once.
-	funcResp1 := &genai.FunctionResponse{
-		Name: funcName,
-		Response: map[string]any{
-			"content": mockAPIResp1,
-		},
-	}
-	funcResp2 := &genai.FunctionResponse{
-		Name: funcName,
-		Response: map[string]any{
-			"content": mockAPIResp2,
-		},
+	funcResps := summary.Responses()
+	replies := make([]genai.Part, len(funcResps))
+	for i, funcResp := range funcResps {
+		replies[i] = funcResp
 	}
+	fmt.Fprintf(w, "%d function calls processed: %s\n", len(funcResps), summary)
 
-	// Return both API responses to the model allowing it to complete its response.
-	resp, err = model.GenerateContent(ctx, prompt, funcResp1, funcResp2)
+	// Return all API responses to the model allowing it to complete its response.
+	res, err = response.Read(chat.SendMessage(ctx, replies...))
 	if err != nil {
 		return fmt.Errorf("failed to generate content: %w", err)
 	}
-	if len(resp.Candidates) == 0 || len(resp.Candidates[0].Content.Parts) == 0 {
-		return errors.New("got empty response from model")
-	}
 
-	fmt.Fprintln(w, resp.Candidates[0].Content.Parts[0])
+	fmt.Fprintln(w, res.Text)
 	// Example response:
 	// The weather in New Delhi is hot and humid with a humidity of 65 and a temperature of 42°C. The weather in San Francisco ...
 
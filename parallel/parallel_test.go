@@ -2,10 +2,36 @@ package parallel
 
 import (
 	"bytes"
+	"context"
+	"errors"
 	"io"
+	"net/http"
+	"regexp"
+	"sort"
 	"testing"
+	"time"
+
+	"vertex/cassette"
+	"vertex/vertextest"
 )
 
+// scriptWeather 는 location 의 지역마다 날씨 함수 호출을 제안한 뒤 답하는 모델 응답을 srv 에 넣고,
+// 제안한 호출을 돌려준다.
+func scriptWeather(srv *vertextest.Server, location map[string]string) []vertextest.Call {
+	keys := make([]string, 0, len(location))
+	for k := range location {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var calls []vertextest.Call
+	for _, k := range keys {
+		calls = append(calls, vertextest.Call{Name: "getCurrentWeather", Args: map[string]any{"location": location[k]}})
+	}
+	srv.AddFunctionCalls(calls...)
+	srv.AddText("sunny stand-in answer")
+	return calls
+}
+
 func TestParallelFunctionCalling(t *testing.T) {
 	tests := []struct {
 		name        string
@@ -18,10 +44,19 @@ func TestParallelFunctionCalling(t *testing.T) {
 		{
 			name:        "Valid Parameters",
 			projectID:   "metanonia-53f36",
-			location:    map[string]string{"location": "San Francisco"},
+			location:    map[string]string{"first": "New Delhi", "second": "San Francisco"},
+			modelName:   "gemini-2.0-flash",
+			wantErr:     false,
+			outputRegex: `(?s)2 function calls processed: 2/2 calls succeeded.*sunny stand-in answer`,
+		},
+		// 알 수 없는 지역(404)은 다시 해도 같으므로 한 번만 묻는다
+		{
+			name:        "Partial Failure",
+			projectID:   "metanonia-53f36",
+			location:    map[string]string{"first": "Seoul", "second": "Atlantis"},
 			modelName:   "gemini-2.0-flash",
 			wantErr:     false,
-			outputRegex: `.*function calls.*processed.*`,
+			outputRegex: `1/2 calls succeeded; failed: getCurrentWeather#1 \(.*404.*, 1 attempts\)`,
 		},
 		{
 			name:        "Invalid Project ID",
@@ -33,14 +68,31 @@ func TestParallelFunctionCalling(t *testing.T) {
 		},
 	}
 
+	// 로컬 가짜 날씨 API
+	fixtures, err := loadWeatherFixtures("testdata/weather.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := newFakeWeatherServer(fixtures, fakeWeatherOptions{Latency: 50 * time.Millisecond})
+	defer server.Close()
+	provider := &HTTPWeatherProvider{BaseURL: server.URL, Client: server.Client()}
+
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
+			// 가짜 Vertex AI: 지역마다 함수 호출을 제안한 뒤 답변
+			srv, err := vertextest.NewServer()
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer srv.Close()
+			calls := scriptWeather(srv, tt.location)
+
 			// 출력 캡처용 버퍼
 			var buf bytes.Buffer
 			w := io.Writer(&buf)
 
 			// 테스트 대상 함수 실행
-			err := parallelFunctionCalling(w, tt.projectID, tt.location, tt.modelName)
+			err = parallelFunctionCalling(w, tt.projectID, tt.location, tt.modelName, provider, srv.ClientOptions()...)
 
 			// 오류 검증
 			if (err != nil) != tt.wantErr {
@@ -50,8 +102,102 @@ func TestParallelFunctionCalling(t *testing.T) {
 
 			// 출력 검증 (오류가 없는 경우만)
 			if !tt.wantErr {
-				t.Logf("Output Got: %s", buf.String())
+				if !regexp.MustCompile(tt.outputRegex).MatchString(buf.String()) {
+					t.Errorf("output does not match %q:\n%s", tt.outputRegex, buf.String())
+				}
+				// 함수 응답은 모델이 요청한 순서대로 돌아가야 한다
+				reqs := srv.GenerateRequests()
+				contents := reqs[len(reqs)-1].GetContents()
+				parts := contents[len(contents)-1].GetParts()
+				for i, part := range parts {
+					if got := part.GetFunctionResponse().GetName(); got != "getCurrentWeather" || i >= len(calls) {
+						t.Errorf("part %d = %v", i, part)
+					}
+				}
+				if loc := parts[0].GetFunctionResponse().GetResponse().GetFields()["location"].GetStringValue(); loc != calls[0].Args["location"] {
+					t.Errorf("first function response location = %q, want %q", loc, calls[0].Args["location"])
+				}
 			}
 		})
 	}
 }
+
+func TestHTTPWeatherProvider(t *testing.T) {
+	fixtures, err := loadWeatherFixtures("testdata/weather.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := newFakeWeatherServer(fixtures, fakeWeatherOptions{
+		Latency: 20 * time.Millisecond,
+		Errors:  map[string]int{"Tokyo": http.StatusServiceUnavailable},
+	})
+	defer server.Close()
+	provider := &HTTPWeatherProvider{BaseURL: server.URL, Client: server.Client()}
+	ctx := context.Background()
+
+	got, err := provider.CurrentWeather(ctx, "New Delhi")
+	if err != nil {
+		t.Fatalf("CurrentWeather() error = %v", err)
+	}
+	if got.Temperature != 42 || got.TemperatureUnit != "C" {
+		t.Errorf("CurrentWeather() = %+v", got)
+	}
+
+	// 서버 오류와 알 수 없는 지역은 상태 코드와 함께 보고된다
+	for location, status := range map[string]int{"Tokyo": http.StatusServiceUnavailable, "Atlantis": http.StatusNotFound} {
+		var apiErr *WeatherAPIError
+		if _, err := provider.CurrentWeather(ctx, location); !errors.As(err, &apiErr) || apiErr.StatusCode != status {
+			t.Errorf("CurrentWeather(%s) error = %v, want status %d", location, err, status)
+		}
+	}
+
+	// 지연보다 짧은 타임아웃이면 취소된다
+	short, cancel := context.WithTimeout(ctx, 5*time.Millisecond)
+	defer cancel()
+	if _, err := provider.CurrentWeather(short, "Seoul"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("CurrentWeather() with short timeout error = %v", err)
+	}
+}
+
+func TestWeatherPrompt(t *testing.T) {
+	prompt, err := weatherPrompt(map[string]string{"b": "San Francisco", "a": "New Delhi"})
+	if err != nil || prompt != "Get weather details in New Delhi and San Francisco?" {
+		t.Errorf("weatherPrompt() = %q, %v", prompt, err)
+	}
+	if _, err := weatherPrompt(nil); err == nil {
+		t.Error("weatherPrompt(nil) error = nil")
+	}
+}
+
+// TestParallelFunctionCallingReplay 는 카세트에 기록된 모델 응답과 로컬 날씨 API 로 병렬 호출을 재생한다.
+// 저장소의 카세트는 scriptWeather 응답을 VERTEX_CASSETTE=fake 로 기록한 것이다.
+func TestParallelFunctionCallingReplay(t *testing.T) {
+	rec := cassette.Start(t, "parallel")
+	location := map[string]string{"first": "New Delhi", "second": "San Francisco"}
+	opts := rec.ClientOptions()
+	if rec.Mode() == cassette.Fake {
+		srv, err := vertextest.NewServer()
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer srv.Close()
+		scriptWeather(srv, location)
+		opts = append(opts, srv.ClientOptions()...)
+	}
+
+	fixtures, err := loadWeatherFixtures("testdata/weather.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := newFakeWeatherServer(fixtures, fakeWeatherOptions{})
+	defer server.Close()
+	provider := &HTTPWeatherProvider{BaseURL: server.URL, Client: server.Client()}
+
+	var buf bytes.Buffer
+	if err := parallelFunctionCalling(&buf, "metanonia-53f36", location, "gemini-2.0-flash", provider, opts...); err != nil {
+		t.Fatalf("parallelFunctionCalling() error = %v", err)
+	}
+	if re := regexp.MustCompile(`(?s)New Delhi.*San Francisco.*2 function calls processed: 2/2 calls succeeded\n\S`); !re.MatchString(buf.String()) {
+		t.Errorf("output does not match %q:\n%s", re, buf.String())
+	}
+}
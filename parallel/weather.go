@@ -0,0 +1,101 @@
+package parallel
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Weather is the current weather reported for a location.
+type Weather struct {
+	Location        string  `json:"location"`
+	Temperature     float64 `json:"temperature"`
+	TemperatureUnit string  `json:"temperature_unit"`
+	Description     string  `json:"description"`
+	Humidity        string  `json:"humidity"`
+}
+
+// toMap converts w into the JSON object sent back to the model.
+func (w *Weather) toMap() map[string]any {
+	return map[string]any{
+		"location":         w.Location,
+		"temperature":      w.Temperature,
+		"temperature_unit": w.TemperatureUnit,
+		"description":      w.Description,
+		"humidity":         w.Humidity,
+	}
+}
+
+// WeatherProvider looks up the current weather; it backs the getCurrentWeather tool.
+type WeatherProvider interface {
+	CurrentWeather(ctx context.Context, location string) (*Weather, error)
+}
+
+// WeatherAPIError is returned when the weather API answers with a non-200 status.
+type WeatherAPIError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *WeatherAPIError) Error() string {
+	return fmt.Sprintf("weather API: %d %s", e.StatusCode, e.Message)
+}
+
+// Retryable reports whether the request may succeed when repeated: server errors and rate limiting.
+// tools.RetryFailed retries only these, so an unknown location (404) is looked up once.
+func (e *WeatherAPIError) Retryable() bool {
+	return e.StatusCode >= 500 || e.StatusCode == http.StatusTooManyRequests
+}
+
+// HTTPWeatherProvider queries a weather API over HTTP:
+//
+//	GET {BaseURL}/v1/current?location=San+Francisco
+//
+// which answers with a Weather JSON object, or {"error": "..."} and a non-200 status.
+type HTTPWeatherProvider struct {
+	BaseURL string
+	// Client is used for requests; http.DefaultClient when nil.
+	Client *http.Client
+}
+
+// CurrentWeather implements WeatherProvider.
+func (p *HTTPWeatherProvider) CurrentWeather(ctx context.Context, location string) (*Weather, error) {
+	u := strings.TrimRight(p.BaseURL, "/") + "/v1/current?" + url.Values{"location": {location}}.Encode()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build weather request: %w", err)
+	}
+	client := p.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("weather request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read weather response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		var apiErr struct {
+			Error string `json:"error"`
+		}
+		if json.Unmarshal(body, &apiErr) != nil || apiErr.Error == "" {
+			apiErr.Error = http.StatusText(resp.StatusCode)
+		}
+		return nil, &WeatherAPIError{StatusCode: resp.StatusCode, Message: apiErr.Error}
+	}
+
+	var weather Weather
+	if err := json.Unmarshal(body, &weather); err != nil {
+		return nil, fmt.Errorf("failed to decode weather response: %w", err)
+	}
+	return &weather, nil
+}
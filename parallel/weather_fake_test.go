@@ -0,0 +1,73 @@
+package parallel
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"time"
+)
+
+// fakeWeatherOptions controls how the fake weather API misbehaves.
+type fakeWeatherOptions struct {
+	// Latency delays every response, like a slow upstream. The request context still cancels it.
+	Latency time.Duration
+	// Errors maps a location to the HTTP status returned for it instead of its fixture.
+	Errors map[string]int
+}
+
+// loadWeatherFixtures reads a JSON array of Weather objects from path.
+func loadWeatherFixtures(path string) (map[string]Weather, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read weather fixtures: %w", err)
+	}
+	var list []Weather
+	if err := json.Unmarshal(data, &list); err != nil {
+		return nil, fmt.Errorf("failed to parse weather fixtures: %w", err)
+	}
+	fixtures := make(map[string]Weather, len(list))
+	for _, w := range list {
+		fixtures[w.Location] = w
+	}
+	return fixtures, nil
+}
+
+// newFakeWeatherServer starts a local stand-in for the weather API served by HTTPWeatherProvider.
+// Unknown locations get 404; the caller must Close the returned server.
+func newFakeWeatherServer(fixtures map[string]Weather, opts fakeWeatherOptions) *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /v1/current", func(w http.ResponseWriter, r *http.Request) {
+		if opts.Latency > 0 {
+			select {
+			case <-time.After(opts.Latency):
+			case <-r.Context().Done():
+				return
+			}
+		}
+
+		location := r.URL.Query().Get("location")
+		if location == "" {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "location is required"})
+			return
+		}
+		if status, ok := opts.Errors[location]; ok {
+			writeJSON(w, status, map[string]string{"error": http.StatusText(status)})
+			return
+		}
+		weather, ok := fixtures[location]
+		if !ok {
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": "unknown location " + location})
+			return
+		}
+		writeJSON(w, http.StatusOK, weather)
+	})
+	return httptest.NewServer(mux)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
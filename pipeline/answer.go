@@ -0,0 +1,77 @@
+package pipeline
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"vertex/vectorstore"
+)
+
+// Answer 는 모델의 답과 그 답이 근거로 인용한 조각들.
+type Answer struct {
+	Text string
+	// Citations 는 답에 처음 나온 순서대로의 인용. 같은 조각은 한 번만 들어 있다.
+	Citations []Citation
+	// Sources 는 프롬프트에 넣은 조각들. 프롬프트의 [n] 은 Sources[n-1] 이다.
+	Sources []vectorstore.Result
+}
+
+// Citation 은 답에 붙은 [N] 이 가리키는 조각과 그 원문 위치.
+type Citation struct {
+	N     int
+	ID    string
+	DocID string
+	// URI 는 원래 문서의 위치. Pipeline.SourceURI 가 없으면 DocID 와 같다.
+	URI        string
+	Start, End int
+}
+
+// CitationError 는 답이 프롬프트에 없는 번호를 인용했을 때의 오류.
+// 모델이 지어낸 근거이므로 Answer 는 올바른 인용만 담아 함께 돌려준다.
+type CitationError struct {
+	Numbers []int
+}
+
+func (e *CitationError) Error() string {
+	return fmt.Sprintf("pipeline: answer cites unknown passages %v", e.Numbers)
+}
+
+// citationRE 는 [1] 이나 [1, 3] 같은 인용 표시.
+var citationRE = regexp.MustCompile(`\[(\d+(?:\s*,\s*\d+)*)\]`)
+
+// citations 는 text 의 인용 표시를 sources 의 조각으로 바꾼다.
+// sources 에 없는 번호가 있으면 나머지 인용과 함께 *CitationError 를 돌려준다.
+func citations(text string, sources []vectorstore.Result, uri func(docID string) string) ([]Citation, error) {
+	var (
+		cites   []Citation
+		unknown []int
+		seen    = make(map[int]bool)
+	)
+	for _, m := range citationRE.FindAllStringSubmatch(text, -1) {
+		for _, s := range strings.Split(m[1], ",") {
+			n, err := strconv.Atoi(strings.TrimSpace(s))
+			if err != nil || seen[n] {
+				continue
+			}
+			seen[n] = true
+			if n < 1 || n > len(sources) {
+				unknown = append(unknown, n)
+				continue
+			}
+			r := sources[n-1]
+			c := Citation{N: n, ID: r.ID, DocID: r.DocID, URI: r.DocID, Start: r.Start, End: r.End}
+			if uri != nil {
+				c.URI = uri(r.DocID)
+			}
+			cites = append(cites, c)
+		}
+	}
+	if len(unknown) > 0 {
+		slices.Sort(unknown)
+		return cites, &CitationError{Numbers: unknown}
+	}
+	return cites, nil
+}
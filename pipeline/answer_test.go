@@ -0,0 +1,49 @@
+package pipeline
+
+import (
+	"errors"
+	"slices"
+	"testing"
+
+	"vertex/vectorstore"
+)
+
+func TestCitations(t *testing.T) {
+	sources := []vectorstore.Result{
+		{ID: "a#0", DocID: "a", Start: 0, End: 10},
+		{ID: "b#2", DocID: "b", Start: 40, End: 55},
+	}
+	a := Citation{N: 1, ID: "a#0", DocID: "a", URI: "a", End: 10}
+	b := Citation{N: 2, ID: "b#2", DocID: "b", URI: "b", Start: 40, End: 55}
+	tests := []struct {
+		name    string
+		text    string
+		want    []Citation
+		unknown []int
+	}{
+		{"없음", "인용이 없는 답", nil, nil},
+		{"하나", "문장 [2].", []Citation{b}, nil},
+		// 처음 나온 순서를 따르고 같은 번호는 한 번만 센다
+		{"여러 개", "첫 문장 [2]. 둘째 [1, 2]. 셋째 [1]", []Citation{b, a}, nil},
+		{"쉼표 주변 공백", "문장 [2 ,1]", []Citation{b, a}, nil},
+		{"없는 번호", "문장 [1]. 지어낸 근거 [5][0]", []Citation{a}, []int{0, 5}},
+		// 숫자가 아닌 대괄호는 인용이 아니다
+		{"다른 대괄호", "배열 [a, b] 와 [1]", []Citation{a}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := citations(tt.text, sources, nil)
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("citations() = %+v, want %+v", got, tt.want)
+			}
+			var citeErr *CitationError
+			if tt.unknown == nil {
+				if err != nil {
+					t.Errorf("citations() error = %v", err)
+				}
+			} else if !errors.As(err, &citeErr) || !slices.Equal(citeErr.Numbers, tt.unknown) {
+				t.Errorf("citations() error = %v, want unknown %v", err, tt.unknown)
+			}
+		})
+	}
+}
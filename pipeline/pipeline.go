@@ -0,0 +1,256 @@
+// Package pipeline 은 RAG 의 흐름을 저장소와 상관없이 묶는다. 문서를 조각으로 나눠 임베딩해
+// vectorstore.VectorStore 에 넣고, 질문과 가까운 조각을 찾아 그 조각들을 근거로 답을 만든다.
+//
+// 저장소가 메모리든 pgvector 든 같은 Pipeline 을 쓰므로, 백엔드는 설정으로 바꾸면 된다.
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+
+	"cloud.google.com/go/vertexai/genai"
+
+	"vertex/chunker"
+	"vertex/embedding"
+	"vertex/prompt"
+	"vertex/rerank"
+	"vertex/response"
+	"vertex/vectorstore"
+)
+
+// NoContextTemplate 은 관련 문서를 찾지 못했을 때의 답을 담은 프롬프트 템플릿 이름.
+const NoContextTemplate = "no_context"
+
+// Generator 는 프롬프트로 답을 만든다.
+type Generator interface {
+	Generate(ctx context.Context, p prompt.Rendered) (string, error)
+}
+
+// GenAI 는 Gemini 모델로 답을 만드는 Generator.
+type GenAI struct {
+	Model *genai.GenerativeModel
+}
+
+// Generate 는 Generator 를 구현한다. 시스템 지시와 예시도 함께 보낸다.
+func (g GenAI) Generate(ctx context.Context, p prompt.Rendered) (string, error) {
+	res, err := response.Read(p.Generate(ctx, g.Model))
+	if err != nil {
+		return "", err
+	}
+	return res.Text, nil
+}
+
+// Pipeline 은 문서 넣기, 검색, 답변 생성을 잇는다.
+type Pipeline struct {
+	Chunker  chunker.Chunker
+	Embedder embedding.Embedder
+	Store    vectorstore.VectorStore
+	// Generator 는 Answer 에서만 쓴다. 검색만 할 때는 nil 이어도 된다.
+	Generator Generator
+	// Prompt 는 Answer 의 프롬프트 템플릿. 값은 PromptData 로 넘긴다. nil 이면 기본 "rag" 템플릿.
+	Prompt *prompt.Template
+	// Search 는 질문으로 찾을 때의 조건 (K, MinScore 등).
+	Search vectorstore.SearchOptions
+	// Reranker 가 nil 이 아니면 후보 RerankCandidates 개를 찾아 관련도로 다시 매긴 뒤 Search.K 개만 남긴다.
+	Reranker rerank.Scorer
+	// RerankCandidates 가 0 이면 rerank.DefaultCandidates.
+	RerankCandidates int
+	// SourceURI 가 nil 이 아니면 인용한 조각의 문서 ID 를 원문 위치(파일 경로, URL 등)로 바꾼다.
+	SourceURI func(docID string) string
+	// Warn 이 nil 이 아니면 멈추지 않고 넘어간 문제를 알린다 (일부가 잘린 임베딩 등).
+	Warn func(error)
+}
+
+// getter 는 ID 로 문서를 꺼낼 수 있는 저장소. Index 가 바뀌지 않은 조각을 건너뛰는 데 쓴다.
+type getter interface {
+	Get(id string) (vectorstore.Document, bool)
+}
+
+// pruner 는 문서마다 남길 조각만 두고 나머지를 지울 수 있는 저장소.
+// 이 저장소 구현들(Memory, HNSW, PGVector, hybrid.Store)은 모두 이를 만족한다.
+type pruner interface {
+	Prune(ctx context.Context, keep map[string][]string) error
+}
+
+// Index 는 docs(ID → 본문)를 조각으로 나눠 임베딩하고 저장소에 넣는다.
+// 저장소에서 문서를 꺼낼 수 있으면 이미 같은 내용으로 들어 있는 조각은 건너뛴다.
+// 문서가 짧아져 조각 수가 줄었으면 남은 예전 조각(doc#N 이후)을 먼저 지운다.
+// 새로 임베딩한 조각 수를 돌려준다.
+func (p *Pipeline) Index(ctx context.Context, docs map[string]string) (int, error) {
+	var chunks []chunker.Chunk
+	keep := make(map[string][]string, len(docs))
+	for _, id := range slices.Sorted(maps.Keys(docs)) {
+		split := p.Chunker.Split(id, docs[id])
+		keep[id] = make([]string, len(split))
+		for i, c := range split {
+			keep[id][i] = c.ID()
+		}
+		chunks = append(chunks, split...)
+	}
+	if pr, ok := p.Store.(pruner); ok {
+		if err := pr.Prune(ctx, keep); err != nil {
+			return 0, fmt.Errorf("pipeline: delete stale chunks: %w", err)
+		}
+	}
+	if g, ok := p.Store.(getter); ok {
+		chunks = slices.DeleteFunc(chunks, func(c chunker.Chunk) bool {
+			d, ok := g.Get(c.ID())
+			return ok && d.Content == c.Text
+		})
+	}
+	if len(chunks) == 0 {
+		return 0, nil
+	}
+
+	texts := make([]string, len(chunks))
+	for i, c := range chunks {
+		texts[i] = c.Text
+	}
+	vecs, err := p.Embedder.Embed(ctx, texts, embedding.RetrievalDocument)
+	var truncErr *embedding.TruncatedError
+	if errors.As(err, &truncErr) {
+		// 잘린 문서도 앞부분으로 검색은 되므로 알리기만 한다
+		p.warn(err)
+	} else if err != nil {
+		return 0, fmt.Errorf("pipeline: embed documents: %w", err)
+	}
+
+	out := make([]vectorstore.Document, len(chunks))
+	for i, c := range chunks {
+		out[i] = vectorstore.Document{
+			ID: c.ID(), Content: c.Text, Vector: vecs[i], DocID: c.DocID, Start: c.Start, End: c.End,
+		}
+	}
+	if err := p.Store.Upsert(ctx, out...); err != nil {
+		return 0, fmt.Errorf("pipeline: store documents: %w", err)
+	}
+	return len(chunks), nil
+}
+
+// hybridSearcher 는 질문의 글자와 임베딩을 함께 쓰는 저장소 (hybrid.Store).
+type hybridSearcher interface {
+	SearchHybrid(ctx context.Context, text string, vec []float32, opts vectorstore.SearchOptions) ([]vectorstore.Result, error)
+}
+
+// Retrieve 는 query 와 가까운 조각을 p.Search 조건으로 찾는다.
+// 저장소가 낱말 검색도 하면(hybrid.Store) 두 검색을 합친 결과를, Reranker 가 있으면
+// 다시 매긴 결과를 돌려준다.
+func (p *Pipeline) Retrieve(ctx context.Context, query string) ([]vectorstore.Result, error) {
+	vecs, err := p.Embedder.Embed(ctx, []string{query}, embedding.RetrievalQuery)
+	if err != nil {
+		return nil, fmt.Errorf("pipeline: embed query: %w", err)
+	}
+	opts := p.Search
+	if p.Reranker != nil {
+		opts.K = p.RerankCandidates
+		if opts.K <= 0 {
+			opts.K = rerank.DefaultCandidates
+		}
+	}
+	var results []vectorstore.Result
+	if h, ok := p.Store.(hybridSearcher); ok {
+		results, err = h.SearchHybrid(ctx, query, vecs[0], opts)
+	} else {
+		results, err = p.Store.Search(ctx, vecs[0], opts)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("pipeline: search: %w", err)
+	}
+	if p.Reranker != nil {
+		if results, err = rerank.Rerank(ctx, p.Reranker, query, results, p.Search.K); err != nil {
+			return nil, fmt.Errorf("pipeline: %w", err)
+		}
+	}
+	return results, nil
+}
+
+// Answer 는 query 로 조각을 찾아 번호를 붙여 프롬프트에 넣고, 답과 답이 인용한 조각을 돌려준다.
+// 관련 조각이 없으면 엉뚱한 문서로 답하지 않도록 모델을 부르지 않고 프롬프트의 NoContextTemplate 을
+// 답으로 돌려준다. Prompt 에 그 템플릿이 없으면 같은 언어의 기본 "rag" 템플릿 것을 쓴다.
+// 답이 없는 번호를 인용하면 올바른 인용만 담은 Answer 와 *CitationError 를 함께 돌려준다.
+func (p *Pipeline) Answer(ctx context.Context, query string) (Answer, error) {
+	results, err := p.Retrieve(ctx, query)
+	if err != nil {
+		return Answer{}, err
+	}
+	t := p.Prompt
+	if t == nil {
+		t = defaultPrompt
+	}
+	if len(results) == 0 {
+		text, err := noContext(t, query)
+		if err != nil {
+			return Answer{}, fmt.Errorf("pipeline: %w", err)
+		}
+		return Answer{Text: text}, nil
+	}
+	rendered, err := t.Render(NewPromptData(results, query))
+	if err != nil {
+		return Answer{Sources: results}, fmt.Errorf("pipeline: %w", err)
+	}
+	text, err := p.Generator.Generate(ctx, rendered)
+	if err != nil {
+		return Answer{Sources: results}, fmt.Errorf("pipeline: generate: %w", err)
+	}
+	ans := Answer{Text: text, Sources: results}
+	ans.Citations, err = citations(text, results, p.SourceURI)
+	return ans, err
+}
+
+func (p *Pipeline) warn(err error) {
+	if p.Warn != nil {
+		p.Warn(err)
+	}
+}
+
+// noContext 는 t 의 NoContextTemplate 답. t 에 없으면 t 와 같은 언어의 기본 템플릿 것을 쓴다.
+func noContext(t *prompt.Template, query string) (string, error) {
+	if !t.Has(NoContextTemplate) {
+		var err error
+		if t, err = defaultPrompts.Get("rag", 0, t.Language); err != nil {
+			return "", err
+		}
+	}
+	return t.Text(NoContextTemplate, NewPromptData(nil, query))
+}
+
+// defaultPrompts 는 바이너리에 든 기본 템플릿, defaultPrompt 는 Pipeline.Prompt 가 nil 일 때 쓰는 "rag" 템플릿.
+var defaultPrompts, defaultPrompt = mustDefaultPrompt()
+
+func mustDefaultPrompt() (*prompt.Library, *prompt.Template) {
+	lib, err := prompt.Load(prompt.Defaults())
+	if err != nil {
+		panic(err)
+	}
+	t, err := lib.Get("rag", 0, prompt.DefaultLanguage)
+	if err != nil {
+		panic(err)
+	}
+	return lib, t
+}
+
+// PromptData 는 RAG 프롬프트 템플릿에 넘기는 값.
+type PromptData struct {
+	Query   string
+	Sources []PromptSource
+}
+
+// PromptSource 는 프롬프트에 넣는 조각 하나. N 은 답에서 인용할 번호로 1 부터 센다.
+type PromptSource struct {
+	N       int
+	ID      string
+	DocID   string
+	Content string
+}
+
+// NewPromptData 는 검색된 조각들에 1 부터 번호를 붙여 PromptData 를 만든다.
+func NewPromptData(results []vectorstore.Result, query string) PromptData {
+	data := PromptData{Query: query, Sources: make([]PromptSource, len(results))}
+	for i, r := range results {
+		data.Sources[i] = PromptSource{N: i + 1, ID: r.ID, DocID: r.DocID, Content: r.Content}
+	}
+	return data
+}
@@ -0,0 +1,313 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"vertex/chunker"
+	"vertex/embedding"
+	"vertex/hybrid"
+	"vertex/prompt"
+	"vertex/rerank"
+	"vertex/vectorstore"
+)
+
+// fakeGenerator 는 받은 프롬프트를 기록하고 answer 를 돌려준다. answer 가 비어 있으면 "답변 [1]".
+type fakeGenerator struct {
+	answer  string
+	prompts []prompt.Rendered
+}
+
+func (g *fakeGenerator) Generate(ctx context.Context, p prompt.Rendered) (string, error) {
+	g.prompts = append(g.prompts, p)
+	if g.answer == "" {
+		return "답변 [1]", nil
+	}
+	return g.answer, nil
+}
+
+// countingEmbedder 는 임베딩한 글의 수를 센다.
+type countingEmbedder struct {
+	embedding.Embedder
+	texts int
+}
+
+func (e *countingEmbedder) Embed(ctx context.Context, texts []string, taskType embedding.TaskType) ([][]float32, error) {
+	e.texts += len(texts)
+	return e.Embedder.Embed(ctx, texts, taskType)
+}
+
+var docs = map[string]string{
+	"vertex": "Vertex AI는 Google Cloud의 머신러닝 플랫폼입니다.",
+	"rag":    "RAG는 검색과 생성을 결합한 접근법입니다.",
+}
+
+// TestPipeline 은 같은 흐름이 어느 저장소에서든 동작하는지 확인한다.
+func TestPipeline(t *testing.T) {
+	stores := []struct {
+		name  string
+		store vectorstore.VectorStore
+	}{
+		{"memory", vectorstore.NewMemory()},
+		{"hnsw", vectorstore.NewHNSW(vectorstore.HNSWConfig{Seed: 1})},
+		{"hybrid", &hybrid.Store{Vector: vectorstore.NewMemory(), Text: hybrid.NewBM25()}},
+	}
+	for _, s := range stores {
+		t.Run(s.name, func(t *testing.T) {
+			ctx := context.Background()
+			emb := &countingEmbedder{Embedder: embedding.NewLocal(64)}
+			gen := &fakeGenerator{}
+			p := &Pipeline{
+				Chunker:   &chunker.Sentences{},
+				Embedder:  emb,
+				Store:     s.store,
+				Generator: gen,
+				Search:    vectorstore.SearchOptions{K: 1},
+			}
+
+			n, err := p.Index(ctx, docs)
+			if err != nil || n != 2 {
+				t.Fatalf("Index() = %d, %v, want 2", n, err)
+			}
+			// 이미 들어 있는 조각은 다시 임베딩하지 않는다
+			if n, err := p.Index(ctx, docs); err != nil || n != 0 {
+				t.Errorf("second Index() = %d, %v, want 0", n, err)
+			}
+			if c, _ := s.store.Count(ctx); c != 2 {
+				t.Errorf("Count() = %d, want 2", c)
+			}
+
+			ans, err := p.Answer(ctx, "RAG는 검색과 생성을 결합한 접근법인가요?")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ans.Text != "답변 [1]" || len(ans.Sources) != 1 || ans.Sources[0].DocID != "rag" {
+				t.Errorf("Answer() = %+v", ans)
+			}
+			want := []Citation{{N: 1, ID: "rag#0", DocID: "rag", URI: "rag", End: len(docs["rag"])}}
+			if !slices.Equal(ans.Citations, want) {
+				t.Errorf("Citations = %+v, want %+v", ans.Citations, want)
+			}
+			if len(gen.prompts) != 1 || !strings.Contains(gen.prompts[0].User, "[1] RAG는") {
+				t.Errorf("prompts = %q", gen.prompts)
+			}
+		})
+	}
+}
+
+// TestIndexShrink 는 문서가 짧아지면 줄어든 뒤쪽 조각을 지워 더는 찾지 않는지 확인한다.
+func TestIndexShrink(t *testing.T) {
+	bm25 := hybrid.NewBM25()
+	stores := []struct {
+		name  string
+		store vectorstore.VectorStore
+		text  *hybrid.BM25
+	}{
+		{"memory", vectorstore.NewMemory(), nil},
+		{"hnsw", vectorstore.NewHNSW(vectorstore.HNSWConfig{Seed: 1}), nil},
+		{"hybrid", &hybrid.Store{Vector: vectorstore.NewMemory(), Text: bm25}, bm25},
+	}
+
+	for _, s := range stores {
+		t.Run(s.name, func(t *testing.T) {
+			ctx := context.Background()
+			p := &Pipeline{
+				Chunker:  &chunker.Sentences{Size: 20},
+				Embedder: embedding.NewLocal(64),
+				Store:    s.store,
+				Search:   vectorstore.SearchOptions{K: 10},
+			}
+			long := map[string]string{
+				"faq":   "환불은 7일 안에 됩니다. 배송은 사흘 걸립니다. 교환은 불가합니다.",
+				"other": "다른 문서입니다.",
+			}
+			if n, err := p.Index(ctx, long); err != nil || n != 4 {
+				t.Fatalf("Index() = %d, %v, want 4", n, err)
+			}
+			short := map[string]string{
+				"faq":   "환불은 7일 안에 됩니다.",
+				"other": long["other"],
+			}
+			if n, err := p.Index(ctx, short); err != nil || n != 0 {
+				t.Fatalf("Index() shorter = %d, %v, want 0", n, err)
+			}
+			if c, _ := s.store.Count(ctx); c != 2 {
+				t.Errorf("Count() = %d, want 2", c)
+			}
+			results, err := p.Retrieve(ctx, "교환은 불가합니다")
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, r := range results {
+				if r.ID == "faq#1" || r.ID == "faq#2" {
+					t.Errorf("Retrieve() returned stale chunk %s", r.ID)
+				}
+			}
+			if s.text != nil && s.text.Len() != 2 {
+				t.Errorf("BM25.Len() = %d, want 2", s.text.Len())
+			}
+		})
+	}
+}
+
+// TestRetrieveRerank 는 후보를 넉넉히 찾은 뒤 Reranker 순서로 K 개만 남기는지 확인한다.
+func TestRetrieveRerank(t *testing.T) {
+	ctx := context.Background()
+	p := &Pipeline{
+		Chunker:  &chunker.Sentences{},
+		Embedder: embedding.NewLocal(64),
+		Store:    vectorstore.NewMemory(),
+		Search:   vectorstore.SearchOptions{K: 1},
+	}
+	if _, err := p.Index(ctx, map[string]string{
+		"manual": "무선 이어폰 사용 설명서와 이어폰 충전 방법",
+		"stock":  "GA04834-US 재고 3개",
+		"refund": "환불 정책",
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	p.Reranker, p.RerankCandidates = rerank.Overlap{}, 3
+	results, err := p.Retrieve(ctx, "GA04834-US 재고")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || results[0].DocID != "stock" || results[0].Score != 1 {
+		t.Errorf("Retrieve() = %v, want stock with score 1", results)
+	}
+}
+
+// TestAnswerNoContext 는 관련 문서가 없으면 모델을 부르지 않고 템플릿의 no_context 로 답하는지 확인한다.
+func TestAnswerNoContext(t *testing.T) {
+	lib, err := prompt.Load(fstest.MapFS{
+		"rag/v1.en.tmpl": {Data: []byte(`{{define "user"}}{{.Query}}{{end}}`)},
+		"rag/v2.en.tmpl": {Data: []byte(`{{define "user"}}{{.Query}}{{end}}{{define "no_context"}}No idea: {{.Query}}{{end}}`)},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	get := func(lib *prompt.Library, version int, lang string) *prompt.Template {
+		tmpl, err := lib.Get("rag", version, lang)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return tmpl
+	}
+	tests := []struct {
+		name   string
+		prompt *prompt.Template
+		want   string
+	}{
+		{"default", nil, "관련 문서를 찾지 못해 답변할 수 없습니다."},
+		{"english", get(defaultPrompts, 0, "en"), "I couldn't find any relevant documents, so I can't answer."},
+		// no_context 가 없는 템플릿은 같은 언어의 기본 답을 쓴다
+		{"without no_context", get(lib, 1, "en"), "I couldn't find any relevant documents, so I can't answer."},
+		{"custom", get(lib, 2, "en"), "No idea: 전혀 관계없는 질문"},
+	}
+	ctx := context.Background()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gen := &fakeGenerator{}
+			p := &Pipeline{
+				Chunker:   &chunker.Sentences{},
+				Embedder:  embedding.NewLocal(64),
+				Store:     vectorstore.NewMemory(),
+				Generator: gen,
+				Prompt:    tt.prompt,
+				Search:    vectorstore.SearchOptions{MinScore: vectorstore.Threshold(0.99)},
+			}
+			if _, err := p.Index(ctx, docs); err != nil {
+				t.Fatal(err)
+			}
+			ans, err := p.Answer(ctx, "전혀 관계없는 질문")
+			if err != nil || ans.Text != tt.want || ans.Sources != nil || ans.Citations != nil {
+				t.Errorf("Answer() = %+v, %v, want %q", ans, err, tt.want)
+			}
+			if len(gen.prompts) != 0 {
+				t.Errorf("model called %d times without context", len(gen.prompts))
+			}
+		})
+	}
+}
+
+// TestAnswerUnknownCitation 은 없는 번호를 인용하면 올바른 인용은 남기고 CitationError 를 돌려주는지 확인한다.
+func TestAnswerUnknownCitation(t *testing.T) {
+	ctx := context.Background()
+	p := &Pipeline{
+		Chunker:   &chunker.Sentences{},
+		Embedder:  embedding.NewLocal(64),
+		Store:     vectorstore.NewMemory(),
+		Generator: &fakeGenerator{answer: "RAG는 검색과 생성을 합칩니다 [1]. 근거 없는 문장 [3, 1]."},
+		Search:    vectorstore.SearchOptions{K: 1},
+		SourceURI: func(docID string) string { return "file:///docs/" + docID + ".txt" },
+	}
+	if _, err := p.Index(ctx, docs); err != nil {
+		t.Fatal(err)
+	}
+	ans, err := p.Answer(ctx, "RAG는 검색과 생성을 결합한 접근법인가요?")
+	var citeErr *CitationError
+	if !errors.As(err, &citeErr) || !slices.Equal(citeErr.Numbers, []int{3}) {
+		t.Fatalf("Answer() error = %v, want CitationError [3]", err)
+	}
+	if len(ans.Citations) != 1 || ans.Citations[0].URI != "file:///docs/rag.txt" {
+		t.Errorf("Citations = %+v, want rag only", ans.Citations)
+	}
+}
+
+// TestDefaultPrompt 는 기본 "rag" 템플릿이 조각에 1 부터 번호를 붙이는지 확인한다.
+func TestDefaultPrompt(t *testing.T) {
+	got, err := defaultPrompt.Render(NewPromptData([]vectorstore.Result{{Content: "가"}, {Content: "나"}}, "질문?"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "다음 문서들을 기반으로 질문에 답하세요. 문서에 답이 없으면 모른다고 답하세요. " +
+		"각 문장 끝에는 근거로 쓴 문서 번호를 [1] 이나 [1, 2] 처럼 붙이세요:\n[1] 가\n[2] 나\n질문: 질문?"
+	if got.User != want || got.System != "" || got.Examples != nil {
+		t.Errorf("Render() = %+v, want user %q", got, want)
+	}
+}
+
+// TestAnswerPrompt 는 Pipeline.Prompt 로 준 템플릿의 시스템 지시와 예시가 Generator 에 그대로 가는지 확인한다.
+func TestAnswerPrompt(t *testing.T) {
+	lib, err := prompt.Load(fstest.MapFS{"rag/v1.en.tmpl": {Data: []byte(
+		`{{define "system"}}Answer in English.{{end}}` +
+			`{{define "example.1.user"}}Q{{end}}{{define "example.1.model"}}A [1]{{end}}` +
+			`{{define "user"}}{{range .Sources}}[{{.N}}] {{.DocID}}{{end}} {{.Query}}{{end}}`,
+	)}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl, err := lib.Get("rag", 0, "en")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	gen := &fakeGenerator{}
+	p := &Pipeline{
+		Chunker:   &chunker.Sentences{},
+		Embedder:  embedding.NewLocal(64),
+		Store:     vectorstore.NewMemory(),
+		Generator: gen,
+		Prompt:    tmpl,
+		Search:    vectorstore.SearchOptions{K: 1},
+	}
+	if _, err := p.Index(ctx, docs); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.Answer(ctx, "RAG는 검색과 생성을 결합한 접근법인가요?"); err != nil {
+		t.Fatal(err)
+	}
+	want := prompt.Rendered{
+		System:   "Answer in English.",
+		Examples: []prompt.Message{{Role: "user", Text: "Q"}, {Role: "model", Text: "A [1]"}},
+		User:     "[1] rag RAG는 검색과 생성을 결합한 접근법인가요?",
+	}
+	if len(gen.prompts) != 1 || !reflect.DeepEqual(gen.prompts[0], want) {
+		t.Errorf("prompts = %+v, want %+v", gen.prompts, want)
+	}
+}
@@ -0,0 +1,289 @@
+// Package prompt 는 모델에 보내는 프롬프트를 text/template 파일로 관리한다.
+// 프롬프트 문구를 Go 코드 밖에 두어, 다시 빌드하지 않고 파일만 바꿔 고치거나 번역할 수 있다.
+//
+// 템플릿 파일은 <이름>/v<버전>.<언어>.tmpl 에 둔다 (예: rag/v1.ko.tmpl). 한 파일에는 다음
+// 이름의 템플릿을 {{define}} 으로 정의한다.
+//
+//	user             모델에 보내는 질문 (꼭 있어야 한다)
+//	system           시스템 지시 (없어도 된다)
+//	example.N.user   N 번째 예시 질문 (few-shot, N 은 1 부터)
+//	example.N.model  N 번째 예시 답
+//
+// 그 밖의 이름은 프롬프트마다 따로 정해 Text 로 꺼낸다 (rag 의 no_context 등).
+//
+// 기본 템플릿은 바이너리에 들어 있고(Defaults), Open 에 디렉터리를 주면 같은 이름, 버전, 언어의
+// 파일이 기본 템플릿을 덮어쓴다.
+package prompt
+
+import (
+	"cmp"
+	"context"
+	"embed"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"cloud.google.com/go/vertexai/genai"
+)
+
+// DefaultLanguage 는 언어를 정하지 않았거나 요청한 언어의 템플릿이 없을 때 쓰는 언어.
+const DefaultLanguage = "ko"
+
+// EnvDir 은 기본 템플릿을 덮어쓸 디렉터리를 지정하는 환경 변수.
+const EnvDir = "VERTEX_PROMPTS"
+
+// EnvVersion 과 EnvLanguage 는 GetEnv 가 고르는 템플릿의 버전과 언어를 지정하는 환경 변수.
+// 플래그가 없는 샘플(image2text, function_call)에서 -prompt-version, -lang 대신 쓴다.
+const (
+	EnvVersion  = "VERTEX_PROMPT_VERSION"
+	EnvLanguage = "VERTEX_PROMPT_LANG"
+)
+
+//go:embed templates
+var templates embed.FS
+
+// Defaults 는 바이너리에 들어 있는 기본 템플릿 파일들.
+func Defaults() fs.FS {
+	sub, err := fs.Sub(templates, "templates")
+	if err != nil {
+		panic(err)
+	}
+	return sub
+}
+
+// fileRE 는 템플릿 파일 이름 (v1.ko.tmpl).
+var fileRE = regexp.MustCompile(`^v([1-9][0-9]*)\.([a-z]{2,3}(?:-[A-Za-z0-9]+)*)\.tmpl$`)
+
+// exampleRE 는 few-shot 예시 템플릿 이름 (example.1.user).
+var exampleRE = regexp.MustCompile(`^example\.([1-9][0-9]*)\.(user|model)$`)
+
+// Template 은 한 프롬프트의 한 버전, 한 언어.
+type Template struct {
+	Name     string
+	Version  int
+	Language string
+
+	tmpl     *template.Template
+	examples int // example.1 … example.N
+}
+
+// Message 는 few-shot 예시의 한 차례. Role 은 "user" 또는 "model".
+type Message struct {
+	Role string
+	Text string
+}
+
+// Rendered 는 값을 채운 프롬프트.
+type Rendered struct {
+	System   string
+	Examples []Message
+	User     string
+}
+
+// Render 는 data 로 템플릿을 채운다. 템플릿에서는 data 의 필드(또는 맵의 키)를 {{.Query}} 처럼 쓴다.
+// 맵에 없는 키를 쓰면 오류다.
+func (t *Template) Render(data any) (Rendered, error) {
+	var (
+		r   Rendered
+		err error
+	)
+	if t.tmpl.Lookup("system") != nil {
+		if r.System, err = t.execute("system", data); err != nil {
+			return Rendered{}, err
+		}
+	}
+	for i := 1; i <= t.examples; i++ {
+		for _, role := range []string{"user", "model"} {
+			text, err := t.execute(fmt.Sprintf("example.%d.%s", i, role), data)
+			if err != nil {
+				return Rendered{}, err
+			}
+			r.Examples = append(r.Examples, Message{Role: role, Text: text})
+		}
+	}
+	if r.User, err = t.execute("user", data); err != nil {
+		return Rendered{}, err
+	}
+	return r, nil
+}
+
+// Text 는 name 템플릿을 data 로 채운다. 그 이름의 템플릿이 없으면 오류다.
+func (t *Template) Text(name string, data any) (string, error) {
+	return t.execute(name, data)
+}
+
+// Has 는 name 템플릿이 정의되어 있는지 알려준다.
+func (t *Template) Has(name string) bool {
+	return t.tmpl.Lookup(name) != nil
+}
+
+func (t *Template) execute(name string, data any) (string, error) {
+	var sb strings.Builder
+	if err := t.tmpl.ExecuteTemplate(&sb, name, data); err != nil {
+		return "", fmt.Errorf("prompt: %s v%d (%s): %w", t.Name, t.Version, t.Language, err)
+	}
+	return sb.String(), nil
+}
+
+// Apply 는 model 에 시스템 지시를 넣는다. 시스템 지시가 없으면 model 을 그대로 둔다.
+func (r Rendered) Apply(model *genai.GenerativeModel) {
+	if r.System != "" {
+		model.SystemInstruction = genai.NewUserContent(genai.Text(r.System))
+	}
+}
+
+// History 는 few-shot 예시를 대화 기록으로 돌려준다.
+func (r Rendered) History() []*genai.Content {
+	var history []*genai.Content
+	for _, m := range r.Examples {
+		history = append(history, &genai.Content{Role: m.Role, Parts: []genai.Part{genai.Text(m.Text)}})
+	}
+	return history
+}
+
+// Generate 는 시스템 지시와 예시를 붙여 model 에 User 를 보낸다. parts 는 User 앞에 넣는다 (이미지 등).
+// model 은 바꾸지 않으므로 여러 고루틴에서 같은 model 로 불러도 된다.
+func (r Rendered) Generate(ctx context.Context, model *genai.GenerativeModel, parts ...genai.Part) (*genai.GenerateContentResponse, error) {
+	m := *model
+	r.Apply(&m)
+	parts = append(slices.Clip(parts), genai.Text(r.User))
+	if len(r.Examples) == 0 {
+		return m.GenerateContent(ctx, parts...)
+	}
+	cs := m.StartChat()
+	cs.History = r.History()
+	return cs.SendMessage(ctx, parts...)
+}
+
+// Library 는 이름, 버전, 언어로 찾는 템플릿 모음.
+type Library struct {
+	templates map[key]*Template
+}
+
+type key struct {
+	name     string
+	version  int
+	language string
+}
+
+// Open 은 기본 템플릿을 읽고, dir 이 비어 있지 않으면 dir 의 템플릿으로 덮어쓴 Library 를 돌려준다.
+func Open(dir string) (*Library, error) {
+	l, err := Load(Defaults())
+	if err != nil {
+		return nil, err
+	}
+	if dir != "" {
+		if err := l.load(os.DirFS(dir)); err != nil {
+			return nil, err
+		}
+	}
+	return l, nil
+}
+
+// Load 는 fsys 의 <이름>/v<버전>.<언어>.tmpl 파일들을 읽는다. .tmpl 이 아닌 파일은 건너뛴다.
+func Load(fsys fs.FS) (*Library, error) {
+	l := &Library{templates: make(map[key]*Template)}
+	if err := l.load(fsys); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+func (l *Library) load(fsys fs.FS) error {
+	files, err := fs.Glob(fsys, "*/*.tmpl")
+	if err != nil {
+		return fmt.Errorf("prompt: %w", err)
+	}
+	for _, file := range files {
+		t, err := parse(fsys, file)
+		if err != nil {
+			return err
+		}
+		l.templates[key{t.Name, t.Version, t.Language}] = t
+	}
+	return nil
+}
+
+// parse 는 file 하나를 읽어 user 와 예시 템플릿이 제대로 있는지 확인한다.
+func parse(fsys fs.FS, file string) (*Template, error) {
+	dir, base := path.Split(file)
+	m := fileRE.FindStringSubmatch(base)
+	if m == nil {
+		return nil, fmt.Errorf("prompt: %s: file name must be v<version>.<language>.tmpl", file)
+	}
+	version, _ := strconv.Atoi(m[1])
+	t := &Template{Name: strings.TrimSuffix(dir, "/"), Version: version, Language: m[2]}
+
+	text, err := fs.ReadFile(fsys, file)
+	if err != nil {
+		return nil, fmt.Errorf("prompt: %w", err)
+	}
+	t.tmpl, err = template.New(base).Option("missingkey=error").Parse(string(text))
+	if err != nil {
+		return nil, fmt.Errorf("prompt: %s: %w", file, err)
+	}
+	if t.tmpl.Lookup("user") == nil {
+		return nil, fmt.Errorf("prompt: %s: no \"user\" template", file)
+	}
+	roles := make(map[int][]string)
+	for _, d := range t.tmpl.Templates() {
+		if m := exampleRE.FindStringSubmatch(d.Name()); m != nil {
+			n, _ := strconv.Atoi(m[1])
+			roles[n] = append(roles[n], m[2])
+		}
+	}
+	// 예시는 1 부터 빠짐없이, 질문과 답이 짝을 이뤄야 한다
+	for len(roles[t.examples+1]) == 2 {
+		t.examples++
+	}
+	if len(roles) != t.examples {
+		return nil, fmt.Errorf("prompt: %s: examples must be numbered from 1 with both user and model", file)
+	}
+	return t, nil
+}
+
+// Get 은 name 템플릿의 version, language 판을 돌려준다. version 이 0 이면 가장 높은 버전을 쓴다.
+// language 가 비어 있거나 그 언어의 템플릿이 없으면 DefaultLanguage 판을 쓴다.
+func (l *Library) Get(name string, version int, language string) (*Template, error) {
+	for _, lang := range slices.Compact([]string{cmp.Or(language, DefaultLanguage), DefaultLanguage}) {
+		if t := l.find(name, version, lang); t != nil {
+			return t, nil
+		}
+	}
+	if version == 0 {
+		return nil, fmt.Errorf("prompt: no template %q for language %q", name, language)
+	}
+	return nil, fmt.Errorf("prompt: no template %q v%d for language %q", name, version, language)
+}
+
+// GetEnv 는 EnvVersion, EnvLanguage 환경 변수로 고른 name 템플릿을 돌려준다.
+// 비어 있으면 Get(name, 0, "") 과 같다.
+func (l *Library) GetEnv(name string) (*Template, error) {
+	version := 0
+	if v := os.Getenv(EnvVersion); v != "" {
+		var err error
+		if version, err = strconv.Atoi(v); err != nil || version < 0 {
+			return nil, fmt.Errorf("prompt: %s=%q: version must be a non-negative integer", EnvVersion, v)
+		}
+	}
+	return l.Get(name, version, os.Getenv(EnvLanguage))
+}
+
+func (l *Library) find(name string, version int, language string) *Template {
+	if version > 0 {
+		return l.templates[key{name, version, language}]
+	}
+	var latest *Template
+	for k, t := range l.templates {
+		if k.name == name && k.language == language && (latest == nil || t.Version > latest.Version) {
+			latest = t
+		}
+	}
+	return latest
+}
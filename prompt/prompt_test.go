@@ -0,0 +1,217 @@
+package prompt
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"cloud.google.com/go/vertexai/genai"
+
+	"vertex/vertextest"
+)
+
+func file(text string) *fstest.MapFile {
+	return &fstest.MapFile{Data: []byte(text)}
+}
+
+func TestLoad(t *testing.T) {
+	tests := []struct {
+		name    string
+		fsys    fstest.MapFS
+		wantErr string
+	}{
+		{"정상", fstest.MapFS{"a/v1.ko.tmpl": file(`{{define "user"}}질문{{end}}`), "a/README.md": file("설명")}, ""},
+		{"지역 언어", fstest.MapFS{"a/v1.pt-BR.tmpl": file(`{{define "user"}}q{{end}}`)}, ""},
+		{"버전 없는 이름", fstest.MapFS{"a/ko.tmpl": file(`{{define "user"}}질문{{end}}`)}, "file name"},
+		{"버전 0", fstest.MapFS{"a/v0.ko.tmpl": file(`{{define "user"}}질문{{end}}`)}, "file name"},
+		{"user 없음", fstest.MapFS{"a/v1.ko.tmpl": file(`{{define "system"}}지시{{end}}`)}, `no "user"`},
+		{"문법 오류", fstest.MapFS{"a/v1.ko.tmpl": file(`{{define "user"}}{{.Query}{{end}}`)}, "a/v1.ko.tmpl"},
+		// 예시는 질문과 답이 짝이어야 하고 번호가 빠지면 안 된다
+		{"답 없는 예시", fstest.MapFS{"a/v1.ko.tmpl": file(`{{define "user"}}q{{end}}{{define "example.1.user"}}e{{end}}`)}, "examples"},
+		{"빠진 예시 번호", fstest.MapFS{"a/v1.ko.tmpl": file(
+			`{{define "user"}}q{{end}}{{define "example.2.user"}}e{{end}}{{define "example.2.model"}}e{{end}}`)}, "examples"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Load(tt.fsys)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Load() error = %v", err)
+				}
+			} else if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Load() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestGet(t *testing.T) {
+	lib, err := Load(fstest.MapFS{
+		"rag/v1.ko.tmpl":  file(`{{define "user"}}ko1{{end}}`),
+		"rag/v2.ko.tmpl":  file(`{{define "user"}}ko2{{end}}`),
+		"rag/v10.ko.tmpl": file(`{{define "user"}}ko10{{end}}`),
+		"rag/v1.en.tmpl":  file(`{{define "user"}}en1{{end}}`),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name     string
+		version  int
+		language string
+		want     string // 비어 있으면 오류
+	}{
+		{"최신", 0, "", "ko10"},
+		{"버전 지정", 2, "ko", "ko2"},
+		{"언어", 0, "en", "en1"},
+		// 번역이 없는 버전이나 언어는 기본 언어로 대신한다
+		{"번역 없는 버전", 2, "en", "ko2"},
+		{"없는 언어", 0, "ja", "ko10"},
+		{"없는 버전", 3, "ko", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpl, err := lib.Get("rag", tt.version, tt.language)
+			if tt.want == "" {
+				if err == nil {
+					t.Errorf("Get() = %s v%d, want error", tmpl.Name, tmpl.Version)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if r, err := tmpl.Render(nil); err != nil || r.User != tt.want {
+				t.Errorf("Get() renders %q, %v, want %q", r.User, err, tt.want)
+			}
+		})
+	}
+	if _, err := lib.Get("chat", 0, ""); err == nil {
+		t.Error("Get(chat) error = nil, want not found")
+	}
+}
+
+func TestRender(t *testing.T) {
+	lib, err := Load(fstest.MapFS{"qa/v1.ko.tmpl": file(`
+{{define "system"}}{{.Lang}} 로 답하세요.{{end}}
+{{define "example.2.user"}}둘{{end}}
+{{define "example.2.model"}}2{{end}}
+{{define "example.1.user"}}하나{{end}}
+{{define "example.1.model"}}1{{end}}
+{{define "user"}}{{.Question}}{{end}}
+`)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl, err := lib.Get("qa", 1, "ko")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := tmpl.Render(map[string]string{"Lang": "한국어", "Question": "셋?"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Rendered{
+		System: "한국어 로 답하세요.",
+		Examples: []Message{
+			{Role: "user", Text: "하나"}, {Role: "model", Text: "1"},
+			{Role: "user", Text: "둘"}, {Role: "model", Text: "2"},
+		},
+		User: "셋?",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Render() = %+v, want %+v", got, want)
+	}
+	// 빠진 값은 빈 글자로 넘어가지 않고 오류가 된다
+	if _, err := tmpl.Render(map[string]string{"Lang": "한국어"}); err == nil {
+		t.Error("Render() without Question error = nil")
+	}
+}
+
+// TestOpen 은 기본 템플릿이 모두 읽히고, 디렉터리의 같은 판이 기본 템플릿을 덮어쓰는지 확인한다.
+func TestOpen(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(dir, "image2text"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "image2text", "v1.ko.tmpl"), []byte(`{{define "user"}}사진을 설명하세요.{{end}}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	lib, err := Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, want := range map[string]string{"image2text": "사진을 설명하세요.", "function_call": "질문?"} {
+		tmpl, err := lib.Get(name, 0, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if r, err := tmpl.Render(map[string]string{"Question": "질문?"}); err != nil || r.User != want {
+			t.Errorf("%s renders %q, %v, want %q", name, r.User, err, want)
+		}
+	}
+	if _, err := lib.Get("rag", 1, "en"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestGetEnv(t *testing.T) {
+	lib, err := Open("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv(EnvLanguage, "en")
+	tmpl, err := lib.GetEnv("image2text")
+	if err != nil || tmpl.Language != "en" || tmpl.Version != 1 {
+		t.Fatalf("GetEnv(image2text) = %+v, %v, want v1 en", tmpl, err)
+	}
+	t.Setenv(EnvVersion, "2")
+	if _, err := lib.GetEnv("image2text"); err == nil {
+		t.Error("GetEnv(image2text) v2 error = nil, want not found")
+	}
+	t.Setenv(EnvVersion, "latest")
+	if _, err := lib.GetEnv("image2text"); err == nil || !strings.Contains(err.Error(), EnvVersion) {
+		t.Errorf("GetEnv() with bad version error = %v", err)
+	}
+}
+
+// TestGenerate 는 시스템 지시와 예시가 요청에 들어가고 model 은 바뀌지 않는지 확인한다.
+func TestGenerate(t *testing.T) {
+	srv, err := vertextest.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Close()
+	srv.AddText("3")
+
+	ctx := context.Background()
+	client, err := genai.NewClient(ctx, "project", "us-central1", srv.ClientOptions()...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	model := client.GenerativeModel("gemini-2.0-flash")
+
+	r := Rendered{System: "숫자로 답하세요.", Examples: []Message{{"user", "1+1?"}, {"model", "2"}}, User: "1+2?"}
+	if _, err := r.Generate(ctx, model); err != nil {
+		t.Fatal(err)
+	}
+	req := srv.GenerateRequests()[0]
+	var texts []string
+	for _, c := range req.GetContents() {
+		texts = append(texts, c.GetRole()+":"+c.GetParts()[0].GetText())
+	}
+	if want := []string{"user:1+1?", "model:2", "user:1+2?"}; !reflect.DeepEqual(texts, want) {
+		t.Errorf("contents = %q, want %q", texts, want)
+	}
+	if got := req.GetSystemInstruction().GetParts()[0].GetText(); got != r.System {
+		t.Errorf("system instruction = %q, want %q", got, r.System)
+	}
+	if model.SystemInstruction != nil {
+		t.Error("Generate() changed the model")
+	}
+}
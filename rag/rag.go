@@ -2,178 +2,351 @@ package main
 
 import (
 	"context"
+	"errors"
+	"flag"
 	"fmt"
+	"io"
+	"io/fs"
 	"log"
-	"math"
+	"os"
 
 	aiplatform "cloud.google.com/go/aiplatform/apiv1"
-	"cloud.google.com/go/aiplatform/apiv1/aiplatformpb"
 	"cloud.google.com/go/vertexai/genai"
+	"github.com/jackc/pgx/v5/pgxpool"
 	"google.golang.org/api/option"
-	"google.golang.org/protobuf/types/known/structpb"
+
+	"vertex/chunker"
+	"vertex/embedding"
+	"vertex/hybrid"
+	"vertex/pipeline"
+	"vertex/prompt"
+	"vertex/rerank"
+	"vertex/vectorstore"
+)
+
+// 저장소 구현. -store 로 고른다.
+const (
+	storeMemory   = "memory"
+	storeHNSW     = "hnsw"
+	storePGVector = "pgvector"
+)
+
+// 다시 매기기 구현. -rerank 로 고른다.
+const (
+	rerankNone   = ""
+	rerankGemini = "gemini"
+	rerankLocal  = "local"
 )
 
 // 전역 클라이언트
 var (
 	genaiClient      *genai.Client
 	predictionClient *aiplatform.PredictionClient
+	embedder         embedding.Embedder
+	dbPool           *pgxpool.Pool
 	projectID        = "metanonia-53f36"
 	location         = "us-central1"
 	embeddingModel   = "text-multilingual-embedding-002"
+	embeddingDim     = 256
 	geminiModel      = "gemini-2.0-flash"
-	endpointPrefix   = "projects/" + projectID + "/locations/" + location + "/publishers/google/models/"
+	// embeddingCacheDir 가 비어 있지 않으면 임베딩을 이 디렉터리에 캐시한다
+	embeddingCacheDir string
+	// embeddingProvider 는 embedding.ProviderVertex 또는 embedding.ProviderLocal
+	embeddingProvider = embedding.ProviderVertex
+	// retrieveOnly 면 Gemini 를 부르지 않고 검색된 문서만 출력한다
+	retrieveOnly bool
+	// topK 개까지, 유사도 minScore 이상인 문서만 답변 근거로 쓴다
+	topK     = vectorstore.DefaultK
+	minScore = 0.5
+	// 문서는 chunkStrategy 방식으로 chunkSize 글자 안팎의 조각으로 나눠 임베딩한다
+	chunkStrategy = chunker.StrategySentence
+	chunkSize     = chunker.DefaultSize
+	chunkOverlap  = chunker.DefaultOverlap
+	// storeBackend 는 문서 벡터를 둘 곳: storeMemory, storeHNSW, storePGVector
+	storeBackend = storeMemory
+	// memoryConfig 는 storeMemory 저장소의 설정. 인덱스 파일에서 불러올 때도 쓴다
+	memoryConfig vectorstore.MemoryConfig
+	// hybridSearch 면 낱말 색인(BM25 또는 PostgreSQL 전문 검색)도 함께 두고 두 검색 결과를 합친다.
+	// 벡터 검색에 없는 낱말 검색 결과는 질문 토큰의 minMatch 비율 이상이 맞아야 쓴다
+	hybridSearch bool
+	minMatch     = hybrid.DefaultMinMatch
+	// rerankMode 가 비어 있지 않으면 후보 rerankCandidates 개를 찾아 관련도로 다시 매긴 뒤 topK 개를 쓴다
+	rerankMode       = rerankNone
+	rerankCandidates = rerank.DefaultCandidates
+	// dsn 은 storePGVector 의 연결 문자열. storePGVector 에서는 반드시 있어야 한다
+	dsn string
+	// indexPath 가 비어 있지 않으면 임베딩한 문서를 이 파일에 저장해 다음 실행에서 다시 쓴다 (storeMemory 만)
+	indexPath string
+	// 답변 프롬프트는 "rag" 템플릿의 promptVersion 판(0 이면 최신), promptLang 언어를 쓴다.
+	// promptDir 이 비어 있지 않으면 그 디렉터리의 템플릿이 기본 템플릿을 덮어쓴다
+	promptDir     string
+	promptVersion int
+	promptLang    = prompt.DefaultLanguage
 )
 
-func initClients(ctx context.Context) error {
+// documents 는 검색 대상 문서 (ID → 본문).
+var documents = map[string]string{
+	"doc1": "Vertex AI는 Google Cloud의 ML 플랫폼입니다",
+	"doc2": "RAG는 검색과 생성을 결합한 AI 접근법",
+}
+
+// initClients 는 전역 클라이언트를 만든다. opts 는 두 클라이언트에 모두 전달된다 (테스트의 카세트 재생 등).
+// 로컬 임베딩과 retrieveOnly 를 함께 쓰면 (Gemini 로 다시 매기지 않는 한) 자격 증명 없이 실행된다.
+// pgvector 저장소면 임베딩 캐시를 같은 데이터베이스에 두므로 openStore 뒤에 부른다.
+func initClients(ctx context.Context, opts ...option.ClientOption) error {
 	var err error
-	genaiClient, err = genai.NewClient(ctx, projectID, location)
-	if err != nil {
-		return fmt.Errorf("genai.NewClient: %v", err)
+	genaiClient, predictionClient = nil, nil
+	if !retrieveOnly || rerankMode == rerankGemini {
+		genaiClient, err = genai.NewClient(ctx, projectID, location, opts...)
+		if err != nil {
+			return fmt.Errorf("genai.NewClient: %v", err)
+		}
+	}
+
+	switch embeddingProvider {
+	case embedding.ProviderLocal:
+		embedder = embedding.NewLocal(embeddingDim)
+		return nil
+	case embedding.ProviderVertex:
+	default:
+		return fmt.Errorf("알 수 없는 임베딩 구현: %q", embeddingProvider)
 	}
 	predictionClient, err = aiplatform.NewPredictionClient(ctx,
-		option.WithEndpoint(location+"-aiplatform.googleapis.com:443"))
+		append([]option.ClientOption{option.WithEndpoint(location + "-aiplatform.googleapis.com:443")}, opts...)...)
 	if err != nil {
 		return fmt.Errorf("aiplatform.NewPredictionClient: %v", err)
 	}
+	embedder = embedding.NewVertex(predictionClient, projectID, location, embeddingModel, embeddingDim)
+	if dbPool != nil {
+		// pgvector 를 쓰면 임베딩 캐시도 같은 데이터베이스에 둔다
+		cache, err := embedding.NewPGCache(ctx, dbPool)
+		if err != nil {
+			return err
+		}
+		embedder = embedding.NewCached(embedder, cache, embeddingModel, embeddingDim)
+	} else if embeddingCacheDir != "" {
+		cache, err := embedding.NewDiskCache(embeddingCacheDir)
+		if err != nil {
+			return err
+		}
+		embedder = embedding.NewCached(embedder, cache, embeddingModel, embeddingDim)
+	}
 	return nil
 }
 
+func closeClients() {
+	if genaiClient != nil {
+		genaiClient.Close()
+	}
+	if predictionClient != nil {
+		predictionClient.Close()
+	}
+	if dbPool != nil {
+		dbPool.Close()
+		dbPool = nil
+	}
+}
+
 func main() {
-	ctx := context.Background()
-	if err := initClients(ctx); err != nil {
+	flag.StringVar(&embeddingProvider, "embedder", embeddingProvider, "임베딩 구현: vertex 또는 local (자격 증명 없이 개발할 때)")
+	flag.BoolVar(&retrieveOnly, "retrieve-only", false, "Gemini 답변 없이 검색된 문서만 출력")
+	flag.IntVar(&topK, "k", topK, "답변 근거로 쓸 최대 문서 수")
+	flag.Float64Var(&minScore, "min-score", minScore, "근거로 쓸 최소 코사인 유사도")
+	flag.StringVar(&chunkStrategy, "chunker", chunkStrategy, "문서 분할 방식: fixed, sentence, paragraph, markdown")
+	flag.IntVar(&chunkSize, "chunk-size", chunkSize, "조각의 최대 글자 수")
+	flag.IntVar(&chunkOverlap, "chunk-overlap", chunkOverlap, "앞 조각과 겹치는 글자 수")
+	flag.StringVar(&storeBackend, "store", storeBackend, "문서 벡터 저장소: memory, hnsw (근사 검색), pgvector (PostgreSQL)")
+	flag.BoolVar(&memoryConfig.Quantize, "quantize", false, "memory 저장소의 벡터를 int8 로 두어 메모리를 1/4 로 줄임 (유사도와 -index 에 저장하는 벡터는 근사값)")
+	flag.BoolVar(&hybridSearch, "hybrid", false, "벡터 검색에 낱말 검색(BM25, pgvector 면 PostgreSQL 전문 검색)을 더해 RRF 로 합침 (제품 코드, 이름 검색)")
+	flag.Float64Var(&minMatch, "min-match", minMatch, "-hybrid 에서 낱말 검색으로만 찾은 문서가 담아야 하는 질문 토큰의 비율")
+	flag.StringVar(&rerankMode, "rerank", rerankNone, "검색한 후보를 다시 매기는 방법: gemini, local (토큰 겹침). 비우면 하지 않음")
+	flag.IntVar(&rerankCandidates, "rerank-candidates", rerankCandidates, "다시 매길 후보 수")
+	flag.StringVar(&dsn, "dsn", os.Getenv(vectorstore.EnvDSN), "pgvector 저장소의 PostgreSQL 연결 문자열 (기본값 $"+vectorstore.EnvDSN+")")
+	flag.StringVar(&indexPath, "index", "", "임베딩한 문서를 저장하고 다시 불러올 인덱스 파일 (memory 저장소에서 Postgres 없이 재시작할 때)")
+	flag.StringVar(&promptDir, "prompts", os.Getenv(prompt.EnvDir), "기본 프롬프트 템플릿을 덮어쓸 디렉터리 (<이름>/v<버전>.<언어>.tmpl)")
+	flag.IntVar(&promptVersion, "prompt-version", 0, "답변 프롬프트 템플릿 버전 (0 이면 최신)")
+	flag.StringVar(&promptLang, "lang", promptLang, "답변 프롬프트 템플릿 언어 (없으면 "+prompt.DefaultLanguage+")")
+	flag.Parse()
+
+	// 같은 문서를 실행할 때마다 다시 임베딩하지 않도록 사용자 캐시 디렉터리를 쓴다
+	if dir, err := embedding.DefaultCacheDir(); err == nil {
+		embeddingCacheDir = dir
+	}
+	if err := run(context.Background(), os.Stdout); err != nil {
 		log.Fatal(err)
 	}
-	defer genaiClient.Close()
-	defer predictionClient.Close()
+}
 
-	// 1. 문서 임베딩 생성
-	documents := map[string]string{
-		"doc1": "Vertex AI는 Google Cloud의 ML 플랫폼입니다",
-		"doc2": "RAG는 검색과 생성을 결합한 AI 접근법",
+// run 은 문서 임베딩, 검색, 답변 생성을 차례로 실행하고 답변을 w 에 쓴다.
+func run(ctx context.Context, w io.Writer, opts ...option.ClientOption) error {
+	defer closeClients()
+	store, mem, err := openStore(ctx)
+	if err != nil {
+		return err
+	}
+	if err := initClients(ctx, opts...); err != nil {
+		return err
+	}
+	split, err := chunker.New(chunkStrategy, chunkSize, chunkOverlap)
+	if err != nil {
+		return err
+	}
+	p := &pipeline.Pipeline{
+		Chunker:  split,
+		Embedder: embedder,
+		Store:    store,
+		Search:   vectorstore.SearchOptions{K: topK, MinScore: vectorstore.Threshold(float32(minScore))},
+		Warn:     func(err error) { log.Printf("경고: %v", err) },
 	}
-	documentEmbeddings := make(map[string][]float32)
-	for id, content := range documents {
-		emb, err := getDocumentEmbedding(ctx, content)
+	if !retrieveOnly {
+		p.Generator = pipeline.GenAI{Model: genaiClient.GenerativeModel(geminiModel)}
+		prompts, err := prompt.Open(promptDir)
 		if err != nil {
-			log.Fatalf("문서 임베딩 실패: %v", err)
+			return err
 		}
-		documentEmbeddings[id] = emb
+		if p.Prompt, err = prompts.Get("rag", promptVersion, promptLang); err != nil {
+			return err
+		}
+	}
+	switch rerankMode {
+	case rerankNone:
+	case rerankGemini:
+		p.Reranker = rerank.NewGemini(genaiClient, geminiModel)
+	case rerankLocal:
+		p.Reranker = rerank.Overlap{}
+	default:
+		return fmt.Errorf("알 수 없는 다시 매기기 방법: %q", rerankMode)
 	}
+	p.RerankCandidates = rerankCandidates
 
-	// 2. 쿼리 임베딩 생성
-	query := "Vertex AI로 RAG를 어떻게 구현하나요?"
-	queryEmbedding, err := getQueryEmbedding(ctx, query)
+	// 1. 문서를 조각으로 나눠 임베딩해 저장. 인덱스에 이미 있는 조각은 건너뛴다
+	n, err := p.Index(ctx, documents)
 	if err != nil {
-		log.Fatalf("쿼리 임베딩 실패: %v", err)
+		return err
+	}
+	if n > 0 && mem != nil && indexPath != "" {
+		if err := mem.SaveFile(indexPath, indexModel()); err != nil {
+			return fmt.Errorf("인덱스 저장 실패: %v", err)
+		}
 	}
 
-	// 3. 유사도 기반 문서 검색
-	mostSimilarDocID := findMostSimilar(documentEmbeddings, queryEmbedding)
-	mostSimilarDocContent := documents[mostSimilarDocID]
-
-	// 4. Gemini 모델을 이용한 응답 생성
-	model := genaiClient.GenerativeModel(geminiModel)
-	prompt := fmt.Sprintf(`다음 문서를 기반으로 질문에 답하세요:
-문서: %s
-질문: %s`, mostSimilarDocContent, query)
-
-	resp, err := model.GenerateContent(ctx, genai.Text(prompt))
-	if err != nil {
-		log.Fatalf("Gemini 응답 생성 실패: %v", err)
+	// 2. 질문과 가까운 조각 검색: 상위 topK 개 중 minScore 이상만
+	query := "Vertex AI로 RAG를 어떻게 구현하나요?"
+	if retrieveOnly {
+		results, err := p.Retrieve(ctx, query)
+		if err != nil {
+			return err
+		}
+		for _, r := range results {
+			fmt.Fprintf(w, "%s (%.3f): %s\n", r.ID, r.Score, r.Content)
+		}
+		return nil
 	}
 
-	fmt.Println(resp.Candidates[0].Content.Parts[0])
+	// 3. 찾은 조각을 근거로 Gemini 답변 생성. 관련 문서가 없으면 모른다고 답한다
+	ans, err := p.Answer(ctx, query)
+	var citeErr *pipeline.CitationError
+	if errors.As(err, &citeErr) {
+		// 지어낸 인용은 빼고 나머지 답과 출처는 그대로 보여준다
+		log.Printf("경고: 검색되지 않은 문서 %v 를 인용했습니다", citeErr.Numbers)
+	} else if err != nil {
+		return err
+	}
+	fmt.Fprintln(w, ans.Text)
+	printCitations(w, ans.Citations)
+	return nil
 }
 
-// 문서 임베딩 생성 함수
-func getDocumentEmbedding(ctx context.Context, text string) ([]float32, error) {
-	req := &aiplatformpb.PredictRequest{
-		Endpoint: endpointPrefix + embeddingModel,
-		Instances: []*structpb.Value{
-			structpb.NewStructValue(&structpb.Struct{
-				Fields: map[string]*structpb.Value{
-					"content": structpb.NewStringValue(text),
-				},
-			}),
-		},
-		Parameters: structpb.NewStructValue(&structpb.Struct{
-			Fields: map[string]*structpb.Value{
-				"outputDimensionality": structpb.NewNumberValue(256),
-			},
-		}),
-	}
-	resp, err := predictionClient.Predict(ctx, req)
-	if err != nil {
-		return nil, fmt.Errorf("임베딩 API 호출 실패: %v", err)
+// printCitations 는 답이 인용한 조각의 ID 와 원문 위치를 [번호] 순서로 출력한다.
+func printCitations(w io.Writer, cites []pipeline.Citation) {
+	if len(cites) == 0 {
+		return
 	}
-	if len(resp.Predictions) == 0 {
-		return nil, fmt.Errorf("빈 임베딩 응답")
+	fmt.Fprintln(w, "\n출처:")
+	for _, c := range cites {
+		fmt.Fprintf(w, "[%d] %s (%s:%d-%d)\n", c.N, c.ID, c.URI, c.Start, c.End)
 	}
-	values := resp.Predictions[0].GetListValue().GetValues()
-	embedding := make([]float32, len(values))
-	for i, v := range values {
-		embedding[i] = float32(v.GetNumberValue())
-	}
-	return embedding, nil
 }
 
-// 쿼리 임베딩 생성 함수
-func getQueryEmbedding(ctx context.Context, query string) ([]float32, error) {
-	req := &aiplatformpb.PredictRequest{
-		Endpoint: endpointPrefix + embeddingModel,
-		Instances: []*structpb.Value{
-			structpb.NewStructValue(&structpb.Struct{
-				Fields: map[string]*structpb.Value{
-					"content":   structpb.NewStringValue(query),
-					"task_type": structpb.NewStringValue("RETRIEVAL_QUERY"),
-				},
-			}),
-		},
-		Parameters: structpb.NewStructValue(&structpb.Struct{
-			Fields: map[string]*structpb.Value{
-				"outputDimensionality": structpb.NewNumberValue(256),
-			},
-		}),
-	}
-	resp, err := predictionClient.Predict(ctx, req)
-	if err != nil {
-		return nil, fmt.Errorf("임베딩 API 호출 실패: %v", err)
+// openStore 는 storeBackend 저장소를 연다. storeMemory 면 인덱스 파일에 저장할 수 있도록
+// *vectorstore.Memory 도 함께 돌려준다. hybridSearch 면 낱말 색인을 붙인 hybrid.Store 를 돌려준다.
+func openStore(ctx context.Context) (vectorstore.VectorStore, *vectorstore.Memory, error) {
+	store, mem, err := openVectorStore(ctx)
+	if err != nil || !hybridSearch {
+		return store, mem, err
 	}
-	if len(resp.Predictions) == 0 {
-		return nil, fmt.Errorf("빈 임베딩 응답")
-	}
-	values := resp.Predictions[0].GetListValue().GetValues()
-	embedding := make([]float32, len(values))
-	for i, v := range values {
-		embedding[i] = float32(v.GetNumberValue())
+	var text hybrid.TextIndex
+	if dbPool != nil {
+		if text, err = hybrid.NewPGText(ctx, dbPool, ""); err != nil {
+			return nil, nil, err
+		}
+	} else {
+		// BM25 는 저장하지 않으므로 인덱스 파일에서 불러온 조각으로 다시 만든다
+		bm25 := hybrid.NewBM25()
+		if mem != nil {
+			if err := bm25.Upsert(ctx, mem.Documents()...); err != nil {
+				return nil, nil, err
+			}
+		}
+		text = bm25
 	}
-	return embedding, nil
+	return &hybrid.Store{Vector: store, Text: text, MinMatch: minMatch}, mem, nil
 }
 
-// 코사인 유사도 계산 함수
-func cosineSimilarity(a, b []float32) float32 {
-	var dot, normA, normB float32
-	for i := range a {
-		dot += a[i] * b[i]
-		normA += a[i] * a[i]
-		normB += b[i] * b[i]
+// openVectorStore 는 storeBackend 벡터 저장소를 연다. storePGVector 의 연결은 closeClients 가 닫는다.
+func openVectorStore(ctx context.Context) (vectorstore.VectorStore, *vectorstore.Memory, error) {
+	if indexPath != "" && storeBackend != storeMemory {
+		return nil, nil, fmt.Errorf("-index 는 %s 저장소에서만 쓸 수 있습니다", storeMemory)
 	}
-	if normA == 0 || normB == 0 {
-		return 0
+	switch storeBackend {
+	case storeMemory:
+		mem := loadIndex()
+		return mem, mem, nil
+	case storeHNSW:
+		return vectorstore.NewHNSW(vectorstore.HNSWConfig{}), nil, nil
+	case storePGVector:
+		if dsn == "" {
+			return nil, nil, fmt.Errorf("%s 저장소에는 -dsn 또는 %s 가 필요합니다", storePGVector, vectorstore.EnvDSN)
+		}
+		var err error
+		dbPool, err = vectorstore.ConnectPG(ctx, dsn)
+		if err != nil {
+			return nil, nil, err
+		}
+		store, err := vectorstore.NewPGVector(ctx, dbPool, vectorstore.DefaultPGTable, embeddingDim)
+		if err != nil {
+			return nil, nil, err
+		}
+		return store, nil, nil
+	default:
+		return nil, nil, fmt.Errorf("알 수 없는 저장소: %q", storeBackend)
 	}
-	return dot / (float32(math.Sqrt(float64(normA))) * float32(math.Sqrt(float64(normB))))
 }
 
-// 가장 유사한 문서 찾기
-func findMostSimilar(docs map[string][]float32, queryEmb []float32) string {
-	var maxScore float32 = -1
-	var bestDocID string
-	for docID, docEmb := range docs {
-		score := cosineSimilarity(queryEmb, docEmb)
-		if score > maxScore {
-			maxScore = score
-			bestDocID = docID
-		}
+// indexModel 은 인덱스 파일에 기록할 임베딩 모델 이름.
+func indexModel() string {
+	if embeddingProvider == embedding.ProviderLocal {
+		return embedding.LocalModel
+	}
+	return embeddingModel
+}
+
+// loadIndex 는 indexPath 의 인덱스를 불러온다. 파일이 없거나, 깨졌거나, 다른 임베딩 모델로
+// 만든 것이면 빈 저장소를 돌려준다. 그 경우 문서를 모두 다시 임베딩해 새로 저장하게 된다.
+func loadIndex() *vectorstore.Memory {
+	if indexPath == "" {
+		return vectorstore.NewMemoryWith(memoryConfig)
+	}
+	store, info, err := vectorstore.LoadFileWith(indexPath, memoryConfig)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		return vectorstore.NewMemoryWith(memoryConfig)
+	case err != nil:
+		log.Printf("경고: 인덱스를 읽지 못해 새로 만듭니다: %v", err)
+		return vectorstore.NewMemoryWith(memoryConfig)
+	case info.Count > 0 && (info.Model != indexModel() || info.Dimensionality != embeddingDim):
+		log.Printf("경고: 인덱스가 다른 임베딩(%s, %d차원)으로 만들어져 새로 만듭니다", info.Model, info.Dimensionality)
+		return vectorstore.NewMemoryWith(memoryConfig)
 	}
-	return bestDocID
+	return store
 }
@@ -0,0 +1,245 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"vertex/cassette"
+	"vertex/embedding"
+	"vertex/hybrid"
+	"vertex/prompt"
+	"vertex/vertextest"
+)
+
+// setFlags 는 테스트 동안 전역 설정을 바꾸고 끝나면 되돌린다.
+func setFlags(t *testing.T, provider string, retrieve bool, k int, score float64) {
+	oldProvider, oldRetrieve, oldK, oldScore := embeddingProvider, retrieveOnly, topK, minScore
+	embeddingProvider, retrieveOnly, topK, minScore = provider, retrieve, k, score
+	t.Cleanup(func() {
+		embeddingProvider, retrieveOnly, topK, minScore = oldProvider, oldRetrieve, oldK, oldScore
+	})
+}
+
+// replayAnswer 는 TestRunReplay 카세트에 기록한 모델 답변. 실제 Gemini 의 답이 아니라
+// 번호 붙은 근거를 출처로 바꾸는 과정을 확인하려고 정한 스크립트이다.
+const replayAnswer = "Vertex AI의 텍스트 임베딩 모델로 문서와 질문을 벡터로 바꾸고, 질문과 가장 비슷한 문서들을 찾아 Gemini 모델의 프롬프트에 함께 넣으면 RAG를 구현할 수 있습니다 [2]. RAG는 이렇게 검색과 생성을 결합한 접근법입니다 [1]."
+
+// TestRunReplay 는 카세트에 기록된 임베딩과 모델 응답으로 검색부터 답변 생성까지 재생한다.
+// 문서, 질문, 프롬프트 형식이 바뀌면 카세트와 요청이 달라져 실패한다.
+// 저장소의 카세트는 vertextest 의 임베딩과 replayAnswer 를 VERTEX_CASSETTE=fake 로 기록한 것이다.
+func TestRunReplay(t *testing.T) {
+	// 가짜 서버의 임베딩은 의미가 없는 벡터이므로 모든 문서를 근거로 쓰도록 한다
+	setFlags(t, embedding.ProviderVertex, false, 3, -1)
+	rec := cassette.Start(t, "rag")
+	opts := rec.ClientOptions()
+	if rec.Mode() == cassette.Fake {
+		srv, err := vertextest.NewServer()
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer srv.Close()
+		srv.AddText(replayAnswer)
+		opts = append(opts, srv.ClientOptions()...)
+	}
+
+	var buf bytes.Buffer
+	if err := run(context.Background(), &buf, opts...); err != nil {
+		t.Fatalf("run() error = %v", err)
+	}
+	if strings.TrimSpace(buf.String()) == "" {
+		t.Error("run() wrote no answer")
+	}
+	// 답에 붙은 [2], [1] 이 처음 나온 순서대로 원문 위치와 함께 출력된다
+	want := "\n출처:\n[2] doc1#0 (doc1:0-" + strconv.Itoa(len(documents["doc1"])) + ")\n[1] doc2#0 (doc2:0-" + strconv.Itoa(len(documents["doc2"])) + ")\n"
+	if !strings.HasSuffix(buf.String(), want) {
+		t.Errorf("output = %q, want citations %q", buf.String(), want)
+	}
+	t.Logf("answer:\n%s", buf.String())
+}
+
+// TestRunLocal 은 로컬 임베딩과 검색만으로 자격 증명 없이 실행되는지 확인한다.
+func TestRunLocal(t *testing.T) {
+	setFlags(t, embedding.ProviderLocal, true, 1, 0)
+
+	var buf bytes.Buffer
+	if err := run(context.Background(), &buf); err != nil {
+		t.Fatalf("run() error = %v", err)
+	}
+	if lines := strings.Split(strings.TrimSpace(buf.String()), "\n"); len(lines) != 1 || !strings.HasPrefix(lines[0], "doc") {
+		t.Errorf("output = %q, want one retrieved document", buf.String())
+	}
+}
+
+// TestRunIndex 는 인덱스 파일을 만든 뒤 다음 실행에서 다시 임베딩하지 않고 쓰는지 확인한다.
+func TestRunIndex(t *testing.T) {
+	setFlags(t, embedding.ProviderLocal, true, 1, 0)
+	indexPath = filepath.Join(t.TempDir(), "rag.vsnap")
+	t.Cleanup(func() { indexPath = "" })
+
+	var first bytes.Buffer
+	if err := run(context.Background(), &first); err != nil {
+		t.Fatal(err)
+	}
+	// 저장하지 않으면 수정 시각이 그대로 남는다
+	past := time.Now().Add(-time.Hour).Truncate(time.Second)
+	if err := os.Chtimes(indexPath, past, past); err != nil {
+		t.Fatalf("index not written: %v", err)
+	}
+
+	var second bytes.Buffer
+	if err := run(context.Background(), &second); err != nil {
+		t.Fatal(err)
+	}
+	if second.String() != first.String() {
+		t.Errorf("output with index = %q, want %q", second.String(), first.String())
+	}
+	if info, err := os.Stat(indexPath); err != nil || !info.ModTime().Equal(past) {
+		t.Errorf("index rewritten although nothing changed")
+	}
+
+	// 깨진 인덱스는 버리고 새로 만든다
+	if err := os.WriteFile(indexPath, []byte("broken"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	var third bytes.Buffer
+	if err := run(context.Background(), &third); err != nil {
+		t.Fatal(err)
+	}
+	if third.String() != first.String() {
+		t.Errorf("output after rebuild = %q, want %q", third.String(), first.String())
+	}
+}
+
+// TestRunHybrid 는 벡터 검색이 MinScore 로 모두 걸러도 낱말이 맞는 조각을 찾고,
+// 인덱스 파일에서 불러온 다음 실행에서도 BM25 를 다시 만들어 같은 결과를 내는지 확인한다.
+// 질문과 문서는 "Vertex", "AI", "RAG" 정도만 겹치므로 -min-match 를 낮춘다.
+func TestRunHybrid(t *testing.T) {
+	setFlags(t, embedding.ProviderLocal, true, 1, 0.99)
+	hybridSearch, indexPath, minMatch = true, filepath.Join(t.TempDir(), "rag.vsnap"), 0.1
+	t.Cleanup(func() { hybridSearch, indexPath, minMatch = false, "", hybrid.DefaultMinMatch })
+
+	var outputs [2]string
+	for i := range outputs {
+		var buf bytes.Buffer
+		if err := run(context.Background(), &buf); err != nil {
+			t.Fatal(err)
+		}
+		outputs[i] = buf.String()
+	}
+	if lines := strings.Split(strings.TrimSpace(outputs[0]), "\n"); len(lines) != 1 || !strings.HasPrefix(lines[0], "doc") {
+		t.Errorf("output = %q, want one retrieved document", outputs[0])
+	}
+	if outputs[1] != outputs[0] {
+		t.Errorf("output with index = %q, want %q", outputs[1], outputs[0])
+	}
+}
+
+// TestRunRerank 는 검색만 할 때도 Gemini 로 후보를 다시 매겨 가장 관련 깊은 조각을 고르는지 확인한다.
+func TestRunRerank(t *testing.T) {
+	setFlags(t, embedding.ProviderLocal, true, 1, -1)
+	rerankMode = rerankGemini
+	t.Cleanup(func() { rerankMode = rerankNone })
+	srv, err := vertextest.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Close()
+	// 검색 순서와 상관없이 두 번째 후보(문단 1)를 고르게 한다
+	srv.AddText(`[{"index": 0, "score": 1}, {"index": 1, "score": 9}]`)
+
+	var buf bytes.Buffer
+	if err := run(context.Background(), &buf, srv.ClientOptions()...); err != nil {
+		t.Fatalf("run() error = %v", err)
+	}
+	reqs := srv.GenerateRequests()
+	if len(reqs) != 1 {
+		t.Fatalf("model called %d times, want once for reranking", len(reqs))
+	}
+	prompt := reqs[0].GetContents()[0].GetParts()[0].GetText()
+	_, second, _ := strings.Cut(prompt, "문단 1: ")
+	second, _, _ = strings.Cut(second, "\n")
+	if out := strings.TrimSpace(buf.String()); second == "" || !strings.HasSuffix(out, ": "+second) || strings.Contains(out, "\n") {
+		t.Errorf("output = %q, want only %q", out, second)
+	}
+}
+
+// TestRunPromptDir 는 -prompts 디렉터리의 새 버전 템플릿이 기본 템플릿 대신 쓰이는지 확인한다.
+func TestRunPromptDir(t *testing.T) {
+	setFlags(t, embedding.ProviderLocal, false, 1, -1)
+	promptDir = t.TempDir()
+	t.Cleanup(func() { promptDir = "" })
+	if err := os.Mkdir(filepath.Join(promptDir, "rag"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	tmpl := `{{define "system"}}짧게 답하세요.{{end}}{{define "user"}}{{.Query}}{{range .Sources}} [{{.N}}] {{.Content}}{{end}}{{end}}`
+	if err := os.WriteFile(filepath.Join(promptDir, "rag", "v2.ko.tmpl"), []byte(tmpl), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	srv, err := vertextest.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Close()
+	srv.AddText("답 [1]")
+
+	var buf bytes.Buffer
+	if err := run(context.Background(), &buf, srv.ClientOptions()...); err != nil {
+		t.Fatalf("run() error = %v", err)
+	}
+	reqs := srv.GenerateRequests()
+	if len(reqs) != 1 {
+		t.Fatalf("model called %d times, want once", len(reqs))
+	}
+	system := reqs[0].GetSystemInstruction().GetParts()[0].GetText()
+	user := reqs[0].GetContents()[0].GetParts()[0].GetText()
+	if system != "짧게 답하세요." || !strings.HasPrefix(user, "Vertex AI로 RAG를 어떻게 구현하나요? [1] ") {
+		t.Errorf("request system = %q, user = %q", system, user)
+	}
+	if !strings.Contains(buf.String(), "출처:\n[1] doc") {
+		t.Errorf("output = %q, want one citation", buf.String())
+	}
+}
+
+// TestRunNoContext 는 관련 문서가 없으면 모델을 부르지 않고 -lang 언어로 모른다고 답하는지 확인한다.
+// -hybrid 에서도 질문과 낱말 몇 개만 겹치는 문서는 근거가 되지 않는다.
+func TestRunNoContext(t *testing.T) {
+	setFlags(t, embedding.ProviderLocal, false, 3, 0.99)
+	t.Cleanup(func() { hybridSearch, promptLang = false, prompt.DefaultLanguage })
+	tests := []struct {
+		name   string
+		hybrid bool
+		lang   string
+		want   string
+	}{
+		{"ko", false, "ko", "관련 문서를 찾지 못해 답변할 수 없습니다."},
+		{"en", false, "en", "I couldn't find any relevant documents, so I can't answer."},
+		{"hybrid", true, "ko", "관련 문서를 찾지 못해 답변할 수 없습니다."},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hybridSearch, promptLang = tt.hybrid, tt.lang
+			srv, err := vertextest.NewServer()
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer srv.Close()
+
+			var buf bytes.Buffer
+			if err := run(context.Background(), &buf, srv.ClientOptions()...); err != nil {
+				t.Fatalf("run() error = %v", err)
+			}
+			if got := strings.TrimSpace(buf.String()); got != tt.want {
+				t.Errorf("output = %q, want %q", got, tt.want)
+			}
+			if n := len(srv.GenerateRequests()); n != 0 {
+				t.Errorf("model called %d times without context", n)
+			}
+		})
+	}
+}
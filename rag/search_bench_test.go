@@ -0,0 +1,159 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"runtime"
+	"slices"
+	"sync"
+	"testing"
+
+	"vertex/embedding"
+	"vertex/vectorstore"
+)
+
+// 검색 벤치마크 말뭉치: embeddingDim 차원의 조각 benchChunks 개, 주제 benchTopics 개.
+const (
+	benchChunks = 100000
+	benchTopics = 500
+)
+
+var (
+	benchOnce    sync.Once
+	benchVectors map[string][]float32
+	benchQueries [][]float32
+)
+
+// benchCorpus 는 주제 중심 둘레에 모인 벡터들로 실제 임베딩 말뭉치를 흉내 낸다.
+func benchCorpus() (map[string][]float32, [][]float32) {
+	benchOnce.Do(func() {
+		rng := rand.New(rand.NewPCG(1, 2))
+		gauss := func() []float32 {
+			v := make([]float32, embeddingDim)
+			for i := range v {
+				v[i] = float32(rng.NormFloat64())
+			}
+			return v
+		}
+		centers := make([][]float32, benchTopics)
+		for i := range centers {
+			centers[i] = gauss()
+		}
+		near := func() []float32 {
+			c := centers[rng.IntN(benchTopics)]
+			v := gauss()
+			for i := range v {
+				v[i] = c[i] + 0.5*v[i]
+			}
+			return v
+		}
+		benchVectors = make(map[string][]float32, benchChunks)
+		for i := range benchChunks {
+			benchVectors[fmt.Sprintf("doc%06d#0", i)] = near()
+		}
+		for range 100 {
+			benchQueries = append(benchQueries, near())
+		}
+	})
+	return benchVectors, benchQueries
+}
+
+// findMostSimilar 는 예전 방식의 검색: 맵 전체를 돌며 매번 두 벡터의 노름까지 다시 계산한다.
+func findMostSimilar(docs map[string][]float32, query []float32, k int) []vectorstore.Result {
+	var results []vectorstore.Result
+	for id, v := range docs {
+		results = append(results, vectorstore.Result{ID: id, Score: embedding.Cosine(query, v)})
+	}
+	slices.SortFunc(results, func(a, b vectorstore.Result) int {
+		if c := cmp.Compare(b.Score, a.Score); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.ID, b.ID)
+	})
+	return results[:min(k, len(results))]
+}
+
+// BenchmarkRetrieve 는 예전 맵 검색과 vectorstore.Memory 의 검색(한 고루틴, 여러 고루틴, int8 양자화)을 비교한다.
+// 저장소마다 문서 하나가 차지하는 힙 크기(B/doc)를, 양자화는 정확한 검색 대비 recall@k 도 함께 보고한다.
+//
+//	go test ./rag -run '^$' -bench Retrieve
+func BenchmarkRetrieve(b *testing.B) {
+	docs, queries := benchCorpus()
+	ctx := context.Background()
+	opts := vectorstore.SearchOptions{K: topK}
+
+	b.Run("map-cosine", func(b *testing.B) {
+		for i := range b.N {
+			findMostSimilar(docs, queries[i%len(queries)], topK)
+		}
+	})
+
+	stores := map[string]*vectorstore.Memory{}
+	for _, c := range []struct {
+		name string
+		cfg  vectorstore.MemoryConfig
+	}{
+		{"memory/workers=1", vectorstore.MemoryConfig{Workers: 1}},
+		{"memory/parallel", vectorstore.MemoryConfig{}},
+		{"memory/int8/workers=1", vectorstore.MemoryConfig{Workers: 1, Quantize: true}},
+		{"memory/int8/parallel", vectorstore.MemoryConfig{Quantize: true}},
+	} {
+		b.Run(c.name, func(b *testing.B) {
+			// 임베딩 API 의 응답처럼 넣을 때마다 새 벡터를 주어, 저장소가 넣은 벡터를 붙잡아 두면 B/doc 에 드러나게 한다
+			before := heapAlloc()
+			store := vectorstore.NewMemoryWith(c.cfg)
+			for id, v := range docs {
+				if err := store.Upsert(ctx, vectorstore.Document{ID: id, Vector: slices.Clone(v)}); err != nil {
+					b.Fatal(err)
+				}
+			}
+			perDoc := float64(heapAlloc()-before) / float64(len(docs))
+			stores[c.name] = store
+			b.ResetTimer()
+			for i := range b.N {
+				if _, err := store.Search(ctx, queries[i%len(queries)], opts); err != nil {
+					b.Fatal(err)
+				}
+			}
+			b.StopTimer()
+			// ResetTimer 가 지우므로 측정이 끝난 뒤에 보고한다
+			b.ReportMetric(perDoc, "B/doc")
+			if exact := stores["memory/workers=1"]; c.cfg.Quantize && exact != nil {
+				b.ReportMetric(recallAt(b, exact, store, queries, opts), fmt.Sprintf("recall@%d", topK))
+			}
+		})
+	}
+}
+
+// heapAlloc 은 GC 를 한 뒤 살아 있는 힙 크기.
+func heapAlloc() int64 {
+	runtime.GC()
+	var ms runtime.MemStats
+	runtime.ReadMemStats(&ms)
+	return int64(ms.HeapAlloc)
+}
+
+// recallAt 은 exact 의 결과 중 approx 도 찾은 비율의 평균.
+func recallAt(b *testing.B, exact, approx *vectorstore.Memory, queries [][]float32, opts vectorstore.SearchOptions) float64 {
+	ctx := context.Background()
+	var hits, total int
+	for _, q := range queries {
+		want, err := exact.Search(ctx, q, opts)
+		if err != nil {
+			b.Fatal(err)
+		}
+		got, err := approx.Search(ctx, q, opts)
+		if err != nil {
+			b.Fatal(err)
+		}
+		for _, w := range want {
+			total++
+			if slices.ContainsFunc(got, func(r vectorstore.Result) bool { return r.ID == w.ID }) {
+				hits++
+			}
+		}
+	}
+	return float64(hits) / float64(total)
+}
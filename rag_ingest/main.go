@@ -0,0 +1,241 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+
+	aiplatform "cloud.google.com/go/aiplatform/apiv1"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"google.golang.org/api/option"
+
+	"vertex/chunker"
+	"vertex/embedding"
+	"vertex/hybrid"
+	"vertex/ingest"
+	"vertex/vectorstore"
+)
+
+// 저장소 구현. -store 로 고른다.
+const (
+	storeMemory   = "memory"
+	storePGVector = "pgvector"
+)
+
+var (
+	projectID      = "metanonia-53f36"
+	location       = "us-central1"
+	embeddingModel = "text-multilingual-embedding-002"
+	embeddingDim   = 256
+	// embeddingProvider 는 embedding.ProviderVertex 또는 embedding.ProviderLocal
+	embeddingProvider = embedding.ProviderVertex
+	chunkStrategy     = chunker.StrategyMarkdown
+	chunkSize         = chunker.DefaultSize
+	chunkOverlap      = chunker.DefaultOverlap
+	// storeBackend 는 조각을 넣을 곳: storeMemory (indexPath 스냅숏) 또는 storePGVector (dsn)
+	storeBackend = storeMemory
+	indexPath    string
+	dsn          string
+	// hybridSearch 면 rag -hybrid 가 쓰도록 PostgreSQL 전문 검색 색인에도 넣는다 (pgvector 만)
+	hybridSearch bool
+)
+
+func main() {
+	dir := flag.String("dir", "", "넣을 문서가 있는 디렉터리 (.txt, .md, .html, .pdf)")
+	checkpoint := flag.String("checkpoint", "ingest-checkpoint.json", "이어 하기용 체크포인트 파일. 비우면 매번 처음부터 넣는다")
+	flag.StringVar(&embeddingProvider, "embedder", embeddingProvider, "임베딩 구현: vertex 또는 local (자격 증명 없이 개발할 때)")
+	flag.StringVar(&chunkStrategy, "chunker", chunkStrategy, "문서 분할 방식: fixed, sentence, paragraph, markdown")
+	flag.IntVar(&chunkSize, "chunk-size", chunkSize, "조각의 최대 글자 수")
+	flag.IntVar(&chunkOverlap, "chunk-overlap", chunkOverlap, "앞 조각과 겹치는 글자 수")
+	flag.StringVar(&storeBackend, "store", storeBackend, "조각을 넣을 저장소: memory (-index 파일) 또는 pgvector (-dsn)")
+	flag.StringVar(&indexPath, "index", "", "memory 저장소의 인덱스 파일. rag -index 로 그대로 불러온다")
+	flag.StringVar(&dsn, "dsn", os.Getenv(vectorstore.EnvDSN), "pgvector 저장소의 PostgreSQL 연결 문자열 (기본값 $"+vectorstore.EnvDSN+")")
+	flag.BoolVar(&hybridSearch, "hybrid", false, "rag -hybrid 를 위해 PostgreSQL 낱말 검색 색인에도 넣음 (pgvector 만)")
+	flag.Parse()
+	if *dir == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	// Ctrl-C 로 멈춰도 이미 넣은 파일은 체크포인트에 남는다
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	if err := run(ctx, *dir, *checkpoint); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func run(ctx context.Context, dir, checkpointPath string) error {
+	split, err := chunker.New(chunkStrategy, chunkSize, chunkOverlap)
+	if err != nil {
+		return err
+	}
+	// 설정이 바뀌면 예전 조각과 섞이지 않도록 체크포인트를 새로 만들어야 한다.
+	// 저장소도 넣어, 다른 인덱스 파일이나 데이터베이스를 가리키면 빈 저장소를 두고 건너뛰지 않게 한다
+	target, err := storeTarget()
+	if err != nil {
+		return err
+	}
+	config := fmt.Sprintf("%s/%s/%d %s/%d/%d %s", embeddingProvider, embeddingModel, embeddingDim, chunkStrategy, chunkSize, chunkOverlap, target)
+	if hybridSearch {
+		config += " hybrid"
+	}
+	cp, err := ingest.LoadCheckpoint(checkpointPath, config)
+	if errors.Is(err, ingest.ErrCheckpointConfig) {
+		return fmt.Errorf("%v\n설정을 바꿨다면 %s 를 지우고 다시 실행하세요", err, checkpointPath)
+	} else if err != nil {
+		return err
+	}
+
+	var (
+		store ingest.Store
+		pool  *pgxpool.Pool
+	)
+	switch storeBackend {
+	case storeMemory:
+		if hybridSearch {
+			return fmt.Errorf("-hybrid 는 %s 저장소에서만 쓸 수 있습니다 (rag 는 인덱스 파일에서 BM25 를 만든다)", storePGVector)
+		}
+		mem, err := loadIndex()
+		if err != nil {
+			return err
+		}
+		store = &indexStore{Memory: mem}
+	case storePGVector:
+		pool, err = vectorstore.ConnectPG(ctx, dsn)
+		if err != nil {
+			return err
+		}
+		defer pool.Close()
+		pg, err := vectorstore.NewPGVector(ctx, pool, vectorstore.DefaultPGTable, embeddingDim)
+		if err != nil {
+			return err
+		}
+		store = pg
+		if hybridSearch {
+			text, err := hybrid.NewPGText(ctx, pool, "")
+			if err != nil {
+				return err
+			}
+			store = &hybrid.Store{Vector: pg, Text: text}
+		}
+	default:
+		return fmt.Errorf("알 수 없는 저장소: %q", storeBackend)
+	}
+
+	var embedder embedding.Embedder
+	switch embeddingProvider {
+	case embedding.ProviderLocal:
+		embedder = embedding.NewLocal(embeddingDim)
+	case embedding.ProviderVertex:
+		predictionClient, err := aiplatform.NewPredictionClient(ctx,
+			option.WithEndpoint(location+"-aiplatform.googleapis.com:443"))
+		if err != nil {
+			return fmt.Errorf("aiplatform.NewPredictionClient: %v", err)
+		}
+		defer predictionClient.Close()
+		embedder = embedding.NewVertex(predictionClient, projectID, location, embeddingModel, embeddingDim)
+		if pool != nil {
+			// pgvector 를 쓰면 임베딩 캐시도 같은 데이터베이스에 둔다
+			cache, err := embedding.NewPGCache(ctx, pool)
+			if err != nil {
+				return err
+			}
+			embedder = embedding.NewCached(embedder, cache, embeddingModel, embeddingDim)
+		}
+	default:
+		return fmt.Errorf("알 수 없는 임베딩 구현: %q", embeddingProvider)
+	}
+
+	in := &ingest.Ingester{
+		Chunker:    split,
+		Embedder:   embedder,
+		Store:      store,
+		Checkpoint: cp,
+		Progress: func(p ingest.Progress) {
+			switch {
+			case p.Removed:
+				log.Printf("%s: 디렉터리에 없어 조각을 지움", p.Path)
+			case p.Skipped:
+				log.Printf("[%d/%d] %s: 이미 넣음", p.Done, p.Total, p.Path)
+			case p.Err != nil && p.Chunks == 0:
+				log.Printf("[%d/%d] %s: 건너뜀: %v", p.Done, p.Total, p.Path, p.Err)
+			case p.Err != nil:
+				log.Printf("[%d/%d] %s: 조각 %d개 (경고: %v)", p.Done, p.Total, p.Path, p.Chunks, p.Err)
+			default:
+				log.Printf("[%d/%d] %s: 조각 %d개", p.Done, p.Total, p.Path, p.Chunks)
+			}
+		},
+	}
+	stats, err := in.Run(ctx, dir)
+	log.Printf("파일 %d개 중 %d개는 이미 넣었고 %d개는 실패, 조각 %d개를 새로 넣음, 사라진 파일 %d개의 조각을 지움",
+		stats.Files, stats.Skipped, stats.Failed, stats.Chunks, stats.Removed)
+	return err
+}
+
+// storeTarget 은 조각을 넣는 곳: memory 면 인덱스 파일의 절대 경로, pgvector 면 호스트, 데이터베이스와 테이블.
+// 비밀번호가 체크포인트에 남지 않도록 연결 문자열은 그대로 쓰지 않는다.
+func storeTarget() (string, error) {
+	switch storeBackend {
+	case storeMemory:
+		if indexPath == "" {
+			return "", fmt.Errorf("%s 저장소에는 -index 가 필요합니다", storeMemory)
+		}
+		abs, err := filepath.Abs(indexPath)
+		if err != nil {
+			return "", err
+		}
+		return storeMemory + ":" + abs, nil
+	case storePGVector:
+		if dsn == "" {
+			return "", fmt.Errorf("%s 저장소에는 -dsn 또는 %s 가 필요합니다", storePGVector, vectorstore.EnvDSN)
+		}
+		cfg, err := pgxpool.ParseConfig(dsn)
+		if err != nil {
+			return "", fmt.Errorf("-dsn: %w", err)
+		}
+		cc := cfg.ConnConfig
+		return fmt.Sprintf("%s:%s:%d/%s/%s", storePGVector, cc.Host, cc.Port, cc.Database, vectorstore.DefaultPGTable), nil
+	default:
+		return "", fmt.Errorf("알 수 없는 저장소: %q", storeBackend)
+	}
+}
+
+// indexModel 은 인덱스 파일에 기록할 임베딩 모델 이름. rag 의 indexModel 과 같아야 한다.
+func indexModel() string {
+	if embeddingProvider == embedding.ProviderLocal {
+		return embedding.LocalModel
+	}
+	return embeddingModel
+}
+
+// loadIndex 는 indexPath 의 인덱스를 불러온다. 파일이 없으면 빈 저장소를 돌려주고,
+// 다른 임베딩으로 만든 인덱스면 체크포인트와 어긋나지 않도록 덮어쓰지 않고 실패한다.
+func loadIndex() (*vectorstore.Memory, error) {
+	mem, info, err := vectorstore.LoadFile(indexPath)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		return vectorstore.NewMemory(), nil
+	case err != nil:
+		return nil, err
+	case info.Count > 0 && (info.Model != indexModel() || info.Dimensionality != embeddingDim):
+		return nil, fmt.Errorf("%s 는 다른 임베딩(%s, %d차원)으로 만든 인덱스입니다", indexPath, info.Model, info.Dimensionality)
+	}
+	return mem, nil
+}
+
+// indexStore 는 indexPath 에 스냅숏으로 저장하는 메모리 저장소. ingest.Flusher 이므로 Ingester 가
+// 여러 파일을 넣은 뒤 한 번씩, 체크포인트를 저장하기 전에 Flush 한다.
+type indexStore struct {
+	*vectorstore.Memory
+}
+
+func (s *indexStore) Flush(ctx context.Context) error {
+	return s.SaveFile(indexPath, indexModel())
+}
@@ -0,0 +1,81 @@
+package rerank
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"cloud.google.com/go/vertexai/genai"
+
+	"vertex/response"
+)
+
+// maxGeminiScore 는 Gemini 에게 매기라고 하는 관련도의 최댓값. Score 는 0~1 로 나눠 돌려준다.
+const maxGeminiScore = 10
+
+// Gemini 는 Gemini 호출 한 번으로 모든 조각의 관련도를 매기는 Scorer.
+// 응답은 JSON 스키마를 지정한 구조화 출력으로 받는다.
+type Gemini struct {
+	Model *genai.GenerativeModel
+}
+
+// NewGemini 는 client 의 model 로 관련도를 매기는 Gemini 를 만든다.
+// 같은 후보에 같은 점수가 나오도록 온도는 0 으로 둔다.
+func NewGemini(client *genai.Client, model string) *Gemini {
+	m := client.GenerativeModel(model)
+	m.SetTemperature(0)
+	m.ResponseMIMEType = "application/json"
+	m.ResponseSchema = scoreSchema
+	return &Gemini{Model: m}
+}
+
+// scoreSchema 는 [{"index": 0, "score": 7}, ...] 형태의 응답 스키마.
+var scoreSchema = &genai.Schema{
+	Type: genai.TypeArray,
+	Items: &genai.Schema{
+		Type: genai.TypeObject,
+		Properties: map[string]*genai.Schema{
+			"index": {Type: genai.TypeInteger, Description: "문단 번호"},
+			"score": {Type: genai.TypeNumber, Description: fmt.Sprintf("질문과의 관련도 (0~%d)", maxGeminiScore)},
+		},
+		Required: []string{"index", "score"},
+	},
+}
+
+type geminiScore struct {
+	Index int     `json:"index"`
+	Score float64 `json:"score"`
+}
+
+// Score 는 Scorer 를 구현한다. 응답에 빠진 문단은 0 점으로 본다.
+func (g *Gemini) Score(ctx context.Context, query string, passages []string) ([]float64, error) {
+	res, err := response.Read(g.Model.GenerateContent(ctx, genai.Text(buildPrompt(query, passages))))
+	if err != nil {
+		return nil, err
+	}
+	var got []geminiScore
+	if err := json.Unmarshal([]byte(res.Text), &got); err != nil {
+		return nil, fmt.Errorf("decode scores: %w", err)
+	}
+	scores := make([]float64, len(passages))
+	for _, s := range got {
+		if s.Index < 0 || s.Index >= len(passages) {
+			return nil, fmt.Errorf("score for unknown passage %d", s.Index)
+		}
+		scores[s.Index] = min(max(s.Score, 0), maxGeminiScore) / maxGeminiScore
+	}
+	return scores, nil
+}
+
+// buildPrompt 는 질문과 번호 붙인 문단들로 관련도를 묻는 프롬프트를 만든다.
+func buildPrompt(query string, passages []string) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "다음 각 문단이 질문에 답하는 데 얼마나 도움이 되는지 0~%d 점으로 매기세요. ", maxGeminiScore)
+	sb.WriteString("문단에 답이 직접 있으면 높게, 주제만 비슷하면 낮게 매기고, 모든 문단에 점수를 매기세요.\n")
+	fmt.Fprintf(&sb, "질문: %s\n", query)
+	for i, p := range passages {
+		fmt.Fprintf(&sb, "문단 %d: %s\n", i, p)
+	}
+	return sb.String()
+}
@@ -0,0 +1,55 @@
+package rerank
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+
+	"cloud.google.com/go/vertexai/genai"
+
+	"vertex/vertextest"
+)
+
+func TestGemini(t *testing.T) {
+	srv, err := vertextest.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Close()
+	ctx := context.Background()
+	client, err := genai.NewClient(ctx, "metanonia-53f36", "us-central1", srv.ClientOptions()...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	g := NewGemini(client, "gemini-2.0-flash")
+	passages := []string{"설명서", "GA04834-US 재고 3개", "배송 안내"}
+
+	// 빠진 문단은 0 점, 범위를 넘는 점수는 잘라 0~1 로 맞춘다
+	srv.AddText(`[{"index": 1, "score": 9}, {"index": 0, "score": 12}]`)
+	scores, err := g.Score(ctx, "GA04834-US 재고 있나요?", passages)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []float64{1, 0.9, 0}; !slices.Equal(scores, want) {
+		t.Errorf("Score() = %v, want %v", scores, want)
+	}
+	req := srv.GenerateRequests()[0]
+	if cfg := req.GetGenerationConfig(); cfg.GetResponseMimeType() != "application/json" || cfg.GetResponseSchema() == nil {
+		t.Errorf("request config = %v, want JSON schema output", cfg)
+	}
+	prompt := req.GetContents()[0].GetParts()[0].GetText()
+	for _, want := range []string{"질문: GA04834-US 재고 있나요?", "문단 0: 설명서", "문단 2: 배송 안내"} {
+		if !strings.Contains(prompt, want) {
+			t.Errorf("prompt %q has no %q", prompt, want)
+		}
+	}
+
+	for _, bad := range []string{`not json`, `[{"index": 3, "score": 1}]`} {
+		srv.AddText(bad)
+		if _, err := g.Score(ctx, "q", passages); err == nil {
+			t.Errorf("Score() with reply %s error = nil", bad)
+		}
+	}
+}
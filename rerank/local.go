@@ -0,0 +1,33 @@
+package rerank
+
+import (
+	"context"
+
+	"vertex/hybrid"
+)
+
+// Overlap 은 모델 없이 질문의 토큰 중 조각에 나오는 비율로 관련도를 매기는 Scorer.
+// 교차 인코더처럼 질문과 조각을 한 쌍씩 보지만 뜻은 모르므로, 테스트나 자격 증명 없이 개발할 때 쓴다.
+type Overlap struct{}
+
+// Score 는 Scorer 를 구현한다.
+func (Overlap) Score(ctx context.Context, query string, passages []string) ([]float64, error) {
+	want := make(map[string]bool)
+	for _, t := range hybrid.Tokenize(query) {
+		want[t] = true
+	}
+	scores := make([]float64, len(passages))
+	if len(want) == 0 {
+		return scores, nil
+	}
+	for i, p := range passages {
+		found := make(map[string]bool)
+		for _, t := range hybrid.Tokenize(p) {
+			if want[t] {
+				found[t] = true
+			}
+		}
+		scores[i] = float64(len(found)) / float64(len(want))
+	}
+	return scores, nil
+}
@@ -0,0 +1,19 @@
+package rerank
+
+import (
+	"context"
+	"slices"
+	"testing"
+)
+
+func TestOverlap(t *testing.T) {
+	scores, err := Overlap{}.Score(context.Background(), "GA04834-US 재고",
+		[]string{"GA04834-US 재고 3개", "재고 없음", "배송 안내"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 질문 토큰: ga04834-us, ga04834, us, 재고
+	if want := []float64{1, 0.25, 0}; !slices.Equal(scores, want) {
+		t.Errorf("Score() = %v, want %v", scores, want)
+	}
+}
@@ -0,0 +1,54 @@
+// Package rerank 는 검색된 조각들을 질문과의 관련도로 다시 매겨 상위 K 개만 남긴다.
+//
+// 벡터나 낱말 검색은 질문과 조각을 따로 보고 비교하므로 순위가 거칠다. 검색으로 후보 N 개를
+// 넉넉히 뽑은 뒤, 질문과 조각을 함께 보는 Scorer(Gemini, 로컬 점수기 등)로 다시 매긴다.
+package rerank
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+
+	"vertex/vectorstore"
+)
+
+// DefaultCandidates 는 다시 매길 후보 수의 기본값.
+const DefaultCandidates = 20
+
+// Scorer 는 질문과 조각마다의 관련도를 매긴다. 결과는 passages 와 같은 순서이며 클수록 관련이 깊다.
+type Scorer interface {
+	Score(ctx context.Context, query string, passages []string) ([]float64, error)
+}
+
+// Rerank 는 candidates 를 s 의 관련도 내림차순으로 다시 늘어놓고 최대 k 개를 돌려준다.
+// 관련도가 같으면 원래(검색) 순서를 따른다. 결과의 Score 는 관련도로 바뀐다.
+// k 가 0 이면 vectorstore.DefaultK.
+func Rerank(ctx context.Context, s Scorer, query string, candidates []vectorstore.Result, k int) ([]vectorstore.Result, error) {
+	if len(candidates) == 0 {
+		return nil, nil
+	}
+	if k <= 0 {
+		k = vectorstore.DefaultK
+	}
+	passages := make([]string, len(candidates))
+	for i, c := range candidates {
+		passages[i] = c.Content
+	}
+	scores, err := s.Score(ctx, query, passages)
+	if err != nil {
+		return nil, fmt.Errorf("rerank: %w", err)
+	}
+	if len(scores) != len(candidates) {
+		return nil, fmt.Errorf("rerank: got %d scores for %d passages", len(scores), len(candidates))
+	}
+
+	out := slices.Clone(candidates)
+	for i := range out {
+		out[i].Score = float32(scores[i])
+	}
+	slices.SortStableFunc(out, func(a, b vectorstore.Result) int {
+		return cmp.Compare(b.Score, a.Score)
+	})
+	return out[:min(k, len(out))], nil
+}
@@ -0,0 +1,62 @@
+package rerank
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"vertex/vectorstore"
+)
+
+// fixedScorer 는 정해진 점수를 돌려준다.
+type fixedScorer []float64
+
+func (s fixedScorer) Score(ctx context.Context, query string, passages []string) ([]float64, error) {
+	return s, nil
+}
+
+func TestRerank(t *testing.T) {
+	candidates := []vectorstore.Result{
+		{ID: "a", Content: "A", Score: 0.9},
+		{ID: "b", Content: "B", Score: 0.8},
+		{ID: "c", Content: "C", Score: 0.7},
+		{ID: "d", Content: "D", Score: 0.6},
+	}
+	tests := []struct {
+		name   string
+		scores fixedScorer
+		k      int
+		want   []string
+	}{
+		{"reorder", fixedScorer{0.1, 0.2, 0.9, 0.5}, 2, []string{"c", "d"}},
+		// 같은 점수는 검색 순서를 따른다
+		{"ties keep order", fixedScorer{0.5, 0.5, 0.5, 0.9}, 3, []string{"d", "a", "b"}},
+		{"default k", fixedScorer{0, 0, 0, 0}, 0, []string{"a", "b", "c"}},
+		{"k larger than candidates", fixedScorer{0.4, 0.3, 0.2, 0.1}, 10, []string{"a", "b", "c", "d"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Rerank(context.Background(), tt.scores, "q", candidates, tt.k)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var ids []string
+			for _, r := range got {
+				ids = append(ids, r.ID)
+			}
+			if !slices.Equal(ids, tt.want) {
+				t.Errorf("Rerank() = %v, want %v", ids, tt.want)
+			}
+		})
+	}
+	if candidates[0].Score != 0.9 {
+		t.Error("Rerank() modified candidates")
+	}
+
+	if _, err := Rerank(context.Background(), fixedScorer{1}, "q", candidates, 2); err == nil {
+		t.Error("Rerank() with too few scores error = nil")
+	}
+	if got, err := Rerank(context.Background(), fixedScorer{}, "q", nil, 2); got != nil || err != nil {
+		t.Errorf("Rerank(nil) = %v, %v", got, err)
+	}
+}
@@ -0,0 +1,149 @@
+// Package response 는 GenerateContent 응답에서 텍스트, 함수 호출, 종료 사유, 안전성 평가를
+// 빠짐없이 읽어오고, 비었거나 차단되었거나 잘린 응답을 타입이 있는 오류로 돌려준다.
+//
+// 호출 결과를 그대로 넘겨 쓸 수 있다:
+//
+//	res, err := response.Read(model.GenerateContent(ctx, parts...))
+package response
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"cloud.google.com/go/vertexai/genai"
+)
+
+// ErrEmpty 는 후보가 없거나 후보에 내용이 전혀 없을 때 반환된다.
+var ErrEmpty = errors.New("empty response from model")
+
+// ErrMalformedFunctionCall 은 모델이 잘못된 형식의 함수 호출을 만들어 생성이 중단되었을 때 반환된다.
+var ErrMalformedFunctionCall = errors.New("model produced a malformed function call")
+
+// Result 는 첫 번째 후보에서 읽은 내용.
+type Result struct {
+	// Text 는 모든 텍스트 파트를 순서대로 이어 붙인 값.
+	Text string
+	// Texts 는 텍스트 파트 각각.
+	Texts []string
+	// FunctionCalls 는 후보에 포함된 모든 함수 호출.
+	FunctionCalls []genai.FunctionCall
+	FinishReason  genai.FinishReason
+	FinishMessage string
+	SafetyRatings []*genai.SafetyRating
+	// Candidate 는 원본 후보. 인용 정보 등 나머지 필드가 필요할 때 사용한다.
+	Candidate *genai.Candidate
+}
+
+// BlockedError 는 프롬프트나 응답이 안전성/차단 목록 등의 이유로 막혔을 때 반환된다.
+type BlockedError struct {
+	// Prompt 가 true 면 프롬프트 단계에서 차단된 것이다.
+	Prompt        bool
+	BlockReason   genai.BlockedReason
+	FinishReason  genai.FinishReason
+	Message       string
+	SafetyRatings []*genai.SafetyRating
+}
+
+func (e *BlockedError) Error() string {
+	if e.Prompt {
+		return fmt.Sprintf("prompt blocked: %s %s", e.BlockReason, e.Message)
+	}
+	var blocked []string
+	for _, r := range e.SafetyRatings {
+		if r.Blocked {
+			blocked = append(blocked, r.Category.String())
+		}
+	}
+	if len(blocked) > 0 {
+		return fmt.Sprintf("response blocked: %s (%s)", e.FinishReason, strings.Join(blocked, ", "))
+	}
+	return fmt.Sprintf("response blocked: %s %s", e.FinishReason, e.Message)
+}
+
+// TruncatedError 는 출력 토큰 한도에 걸려 응답이 잘렸을 때 반환된다.
+// Result 에는 잘리기 전까지 생성된 내용이 들어 있다.
+type TruncatedError struct {
+	Result *Result
+}
+
+func (e *TruncatedError) Error() string {
+	return fmt.Sprintf("response truncated: %s", e.Result.FinishReason)
+}
+
+// Read 는 resp 의 첫 번째 후보를 읽는다. err 가 nil 이 아니면 분류만 해서 그대로 돌려준다.
+//
+// 응답이 잘린 경우 Result 와 *TruncatedError 를 함께 반환하므로 부분 결과를 쓸 수 있다.
+func Read(resp *genai.GenerateContentResponse, err error) (*Result, error) {
+	if err != nil {
+		var be *genai.BlockedError
+		if errors.As(err, &be) {
+			return nil, fromGenaiBlocked(be)
+		}
+		return nil, err
+	}
+	if resp == nil {
+		return nil, ErrEmpty
+	}
+	if pf := resp.PromptFeedback; pf != nil && pf.BlockReason != genai.BlockedReasonUnspecified {
+		return nil, &BlockedError{Prompt: true, BlockReason: pf.BlockReason, Message: pf.BlockReasonMessage, SafetyRatings: pf.SafetyRatings}
+	}
+	if len(resp.Candidates) == 0 {
+		return nil, ErrEmpty
+	}
+	return ReadCandidate(resp.Candidates[0])
+}
+
+// ReadCandidate 는 후보 하나를 읽는다. Read 와 같은 규칙으로 오류를 만든다.
+func ReadCandidate(c *genai.Candidate) (*Result, error) {
+	if c == nil {
+		return nil, ErrEmpty
+	}
+	res := &Result{
+		FinishReason:  c.FinishReason,
+		FinishMessage: c.FinishMessage,
+		SafetyRatings: c.SafetyRatings,
+		Candidate:     c,
+	}
+	if c.Content != nil {
+		for _, part := range c.Content.Parts {
+			switch v := part.(type) {
+			case genai.Text:
+				if v != "" {
+					res.Texts = append(res.Texts, string(v))
+				}
+			case genai.FunctionCall:
+				res.FunctionCalls = append(res.FunctionCalls, v)
+			case *genai.FunctionCall:
+				res.FunctionCalls = append(res.FunctionCalls, *v)
+			}
+		}
+	}
+	res.Text = strings.Join(res.Texts, "")
+
+	switch c.FinishReason {
+	case genai.FinishReasonSafety, genai.FinishReasonRecitation, genai.FinishReasonBlocklist,
+		genai.FinishReasonProhibitedContent, genai.FinishReasonSpii:
+		return nil, &BlockedError{FinishReason: c.FinishReason, Message: c.FinishMessage, SafetyRatings: c.SafetyRatings}
+	case genai.FinishReasonMalformedFunctionCall:
+		return nil, fmt.Errorf("%w: %s", ErrMalformedFunctionCall, c.FinishMessage)
+	case genai.FinishReasonMaxTokens:
+		return res, &TruncatedError{Result: res}
+	}
+	if len(res.Texts) == 0 && len(res.FunctionCalls) == 0 {
+		return nil, ErrEmpty
+	}
+	return res, nil
+}
+
+func fromGenaiBlocked(be *genai.BlockedError) *BlockedError {
+	if be.PromptFeedback != nil {
+		pf := be.PromptFeedback
+		return &BlockedError{Prompt: true, BlockReason: pf.BlockReason, Message: pf.BlockReasonMessage, SafetyRatings: pf.SafetyRatings}
+	}
+	e := &BlockedError{}
+	if c := be.Candidate; c != nil {
+		e.FinishReason, e.Message, e.SafetyRatings = c.FinishReason, c.FinishMessage, c.SafetyRatings
+	}
+	return e
+}
@@ -0,0 +1,94 @@
+package response
+
+import (
+	"errors"
+	"testing"
+
+	"cloud.google.com/go/vertexai/genai"
+)
+
+func candidate(reason genai.FinishReason, parts ...genai.Part) *genai.GenerateContentResponse {
+	return &genai.GenerateContentResponse{Candidates: []*genai.Candidate{{
+		Content:      &genai.Content{Role: "model", Parts: parts},
+		FinishReason: reason,
+	}}}
+}
+
+func TestRead(t *testing.T) {
+	res, err := Read(candidate(genai.FinishReasonStop,
+		genai.Text("서울은 "),
+		genai.FunctionCall{Name: "a"},
+		genai.Text("대한민국의 수도입니다."),
+		genai.FunctionCall{Name: "b"},
+	), nil)
+	if err != nil {
+		t.Fatalf("Read() error = %v", err)
+	}
+	if res.Text != "서울은 대한민국의 수도입니다." || len(res.Texts) != 2 {
+		t.Errorf("Text = %q, Texts = %q", res.Text, res.Texts)
+	}
+	if len(res.FunctionCalls) != 2 || res.FunctionCalls[1].Name != "b" {
+		t.Errorf("FunctionCalls = %+v", res.FunctionCalls)
+	}
+}
+
+func TestReadErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		resp  *genai.GenerateContentResponse
+		err   error
+		check func(error) bool
+	}{
+		{
+			name:  "No Candidates",
+			resp:  &genai.GenerateContentResponse{},
+			check: func(err error) bool { return errors.Is(err, ErrEmpty) },
+		},
+		{
+			name:  "Empty Content",
+			resp:  candidate(genai.FinishReasonStop),
+			check: func(err error) bool { return errors.Is(err, ErrEmpty) },
+		},
+		{
+			name: "Safety Block",
+			resp: candidate(genai.FinishReasonSafety),
+			check: func(err error) bool {
+				var be *BlockedError
+				return errors.As(err, &be) && !be.Prompt && be.FinishReason == genai.FinishReasonSafety
+			},
+		},
+		{
+			name: "Client Blocked Error",
+			err:  &genai.BlockedError{PromptFeedback: &genai.PromptFeedback{BlockReason: genai.BlockedReasonSafety}},
+			check: func(err error) bool {
+				var be *BlockedError
+				return errors.As(err, &be) && be.Prompt
+			},
+		},
+		{
+			name:  "Malformed Function Call",
+			resp:  candidate(genai.FinishReasonMalformedFunctionCall),
+			check: func(err error) bool { return errors.Is(err, ErrMalformedFunctionCall) },
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := Read(tt.resp, tt.err)
+			if res != nil || !tt.check(err) {
+				t.Errorf("Read() = %v, %v", res, err)
+			}
+		})
+	}
+}
+
+func TestReadTruncated(t *testing.T) {
+	res, err := Read(candidate(genai.FinishReasonMaxTokens, genai.Text("부분 답변")), nil)
+	var te *TruncatedError
+	if !errors.As(err, &te) {
+		t.Fatalf("Read() error = %v, want TruncatedError", err)
+	}
+	if res == nil || res.Text != "부분 답변" || te.Result != res {
+		t.Errorf("partial result = %+v", res)
+	}
+}
@@ -0,0 +1,173 @@
+package tools
+
+import (
+	"context"
+	"errors"
+
+	"cloud.google.com/go/vertexai/genai"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ErrorPolicy 는 도구 호출이 실패했을 때의 처리 방식.
+type ErrorPolicy int
+
+const (
+	// ReportErrors 는 실패를 ErrorKey 아래 오류 내용을 담은 FunctionResponse 로 모델에 돌려준다.
+	// 모델은 이를 보고 다른 도구를 고르거나 사용자에게 되물을 수 있다. 기본값.
+	ReportErrors ErrorPolicy = iota
+	// AbortOnError 는 실패를 호출자에게 오류로 반환하고 대화를 중단한다.
+	AbortOnError
+)
+
+// 모델에 전달되는 오류 코드
+const (
+	CodeUnknownFunction = "UNKNOWN_FUNCTION"
+	CodeInvalidArgument = "INVALID_ARGUMENT"
+	CodeToolError       = "TOOL_ERROR"
+)
+
+// ErrorKey 는 ErrorResponse 가 오류 내용을 담는 응답 키. 도구 핸들러의 결과에는 쓸 수 없어서
+// (Dispatch 가 거부한다) 이 키가 있으면 항상 ErrorResponse 로 만든 응답이다.
+const ErrorKey = "tool_error"
+
+// ErrorCode 는 err 를 모델에 전달할 오류 코드로 분류한다.
+func ErrorCode(err error) string {
+	var ae *ArgumentError
+	switch {
+	case errors.Is(err, ErrUnknownFunction):
+		return CodeUnknownFunction
+	case errors.As(err, &ae):
+		return CodeInvalidArgument
+	default:
+		return CodeToolError
+	}
+}
+
+// IsRetryable 은 err 가 다시 시도하면 성공할 수도 있는 일시적인 실패인지 알려준다.
+// RetryFailed 정책은 이런 실패만 재시도한다.
+//
+// 오류 사슬에 Retryable() bool 메서드가 있으면 그 값을 따른다 (Transient 로 감싼 오류 등).
+// 없으면 시간 초과(context.DeadlineExceeded, Timeout() 이 참인 네트워크 오류)와
+// gRPC 의 Unavailable, ResourceExhausted 만 일시적인 실패로 본다.
+func IsRetryable(err error) bool {
+	if err == nil || ErrorCode(err) != CodeToolError {
+		return false
+	}
+	var r interface{ Retryable() bool }
+	if errors.As(err, &r) {
+		return r.Retryable()
+	}
+	var t interface{ Timeout() bool }
+	if errors.Is(err, context.DeadlineExceeded) || errors.As(err, &t) && t.Timeout() {
+		return true
+	}
+	switch status.Code(err) {
+	case codes.Unavailable, codes.ResourceExhausted:
+		return true
+	}
+	return false
+}
+
+// Transient 는 err 를 RetryFailed 정책이 다시 시도하는 일시적인 실패로 표시한다.
+// 도구 핸들러가 백엔드의 일시적인 장애를 알릴 때 쓴다. err 가 nil 이면 nil.
+func Transient(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &transientError{err}
+}
+
+type transientError struct{ err error }
+
+func (e *transientError) Error() string   { return e.err.Error() }
+func (e *transientError) Unwrap() error   { return e.err }
+func (e *transientError) Retryable() bool { return true }
+
+// ErrorResponse 는 실패한 호출을 모델에 알리는 FunctionResponse 를 만든다.
+//
+//	{"tool_error": {"code": "INVALID_ARGUMENT", "message": "...", "fields": [...]}}
+func ErrorResponse(name string, err error) *genai.FunctionResponse {
+	payload := map[string]any{
+		"code":    ErrorCode(err),
+		"message": err.Error(),
+	}
+	var fields []any
+	for _, e := range flatten(err) {
+		var ae *ArgumentError
+		if errors.As(e, &ae) {
+			fields = append(fields, map[string]any{"path": ae.Path, "problem": ae.Msg})
+		}
+	}
+	if len(fields) > 0 {
+		payload["fields"] = fields
+	}
+	return &genai.FunctionResponse{
+		Name:     name,
+		Response: map[string]any{ErrorKey: payload},
+	}
+}
+
+// IsErrorResponse 는 resp 가 ErrorResponse 로 만들어진 응답인지 알려준다.
+func IsErrorResponse(resp *genai.FunctionResponse) bool {
+	if resp == nil {
+		return false
+	}
+	_, ok := resp.Response[ErrorKey]
+	return ok
+}
+
+// flatten 은 errors.Join 이나 %w 로 묶인 오류를 펼친다.
+func flatten(err error) []error {
+	switch x := err.(type) {
+	case interface{ Unwrap() []error }:
+		var out []error
+		for _, e := range x.Unwrap() {
+			out = append(out, flatten(e)...)
+		}
+		return out
+	case interface{ Unwrap() error }:
+		if inner := x.Unwrap(); inner != nil {
+			return flatten(inner)
+		}
+	}
+	return []error{err}
+}
+
+// Handle 은 call 을 디스패치하고 정책에 따라 실패를 처리한다.
+//
+// 실패한 호출은 도구의 OnError(등록되지 않은 함수면 Registry.OnUnknown) 정책이
+// ReportErrors 이면 ErrorResponse 로 바뀌어 nil 오류와 함께 반환되고,
+// AbortOnError 이면 디스패치 오류가 그대로 반환된다.
+func (r *Registry) Handle(ctx context.Context, call genai.FunctionCall) (*genai.FunctionResponse, error) {
+	resp, err := r.Dispatch(ctx, call)
+	return r.resolve(call, resp, err)
+}
+
+// policyFor 는 name 호출에 적용할 오류 정책. 등록되지 않은 함수면 OnUnknown.
+func (r *Registry) policyFor(name string) ErrorPolicy {
+	if t, ok := r.Lookup(name); ok {
+		return t.OnError
+	}
+	return r.OnUnknown
+}
+
+// resolve 는 디스패치 결과에 call 의 오류 정책을 적용한다.
+func (r *Registry) resolve(call genai.FunctionCall, resp *genai.FunctionResponse, err error) (*genai.FunctionResponse, error) {
+	if err == nil {
+		return resp, nil
+	}
+	if r.policyFor(call.Name) == AbortOnError {
+		return nil, err
+	}
+	resp = ErrorResponse(call.Name, err)
+	if errors.Is(err, ErrUnknownFunction) {
+		// 모델이 다른 도구를 고를 수 있도록 사용 가능한 목록을 함께 알려준다
+		var names []any
+		for _, d := range r.Declarations() {
+			names = append(names, d.Name)
+		}
+		resp.Response[ErrorKey].(map[string]any)["available"] = names
+	}
+	return resp, nil
+}
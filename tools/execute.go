@@ -0,0 +1,255 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/vertexai/genai"
+)
+
+// BatchPolicy 는 동시에 실행한 호출 중 일부가 실패했을 때 배치 전체를 어떻게 처리할지 정한다.
+type BatchPolicy int
+
+const (
+	// BestEffort 는 성공한 결과와 실패한 호출의 오류 응답을 함께 돌려준다. 기본값.
+	BestEffort BatchPolicy = iota
+	// FailFast 는 하나라도 실패하면 나머지를 취소하고 배치를 실패시킨다.
+	FailFast
+	// RetryFailed 는 일시적인 실패(IsRetryable)만 Retries 번까지 다시 실행한 뒤 BestEffort 처럼 처리한다.
+	// 알 수 없는 함수, 잘못된 인자, 없는 데이터처럼 다시 해도 같은 결과인 실패는 재시도하지 않는다.
+	RetryFailed
+)
+
+// ErrNotRun 은 배치가 취소되어 실행되지 못한 호출의 CallResult.Err 가 감싸는 오류.
+var ErrNotRun = errors.New("tools: call not run")
+
+// DefaultRetries 는 RetryFailed 정책에서 Retries 가 0 일 때 쓰는 재시도 횟수.
+const DefaultRetries = 2
+
+// ExecOptions 는 여러 함수 호출을 동시에 실행할 때의 설정.
+type ExecOptions struct {
+	// Workers 는 동시에 실행할 최대 호출 수. 0 이하면 모든 호출을 한꺼번에 실행한다.
+	Workers int
+	// Timeout 은 호출 하나에 허용하는 시간. 0 이면 제한 없음.
+	// 시간을 넘긴 호출은 context.DeadlineExceeded 로 실패한 것으로 처리된다.
+	Timeout time.Duration
+	// Policy 는 일부 호출이 실패했을 때의 배치 처리 방식.
+	Policy BatchPolicy
+	// Retries 와 RetryBackoff 는 RetryFailed 정책의 재시도 횟수와 재시도 사이 대기 시간.
+	Retries      int
+	RetryBackoff time.Duration
+}
+
+// CallResult 는 배치 안의 호출 하나의 실행 결과.
+type CallResult struct {
+	Call genai.FunctionCall
+	// Response 는 모델에 돌려줄 응답. 실패가 보고된 호출이면 ErrorResponse 이다.
+	Response *genai.FunctionResponse
+	// Err 는 마지막 시도의 실패 원인. 성공했으면 nil, 실행되지 못했으면 ErrNotRun 을 감싼다.
+	Err      error
+	Attempts int
+	Duration time.Duration
+}
+
+// BatchSummary 는 ExecuteBatch 한 번의 결과를 호출 순서대로 담는다.
+type BatchSummary struct {
+	Results []CallResult
+}
+
+// Responses 는 모델에 돌려줄 응답을 호출 순서대로 반환한다.
+func (s *BatchSummary) Responses() []*genai.FunctionResponse {
+	out := make([]*genai.FunctionResponse, len(s.Results))
+	for i, r := range s.Results {
+		out[i] = r.Response
+	}
+	return out
+}
+
+// Succeeded 는 성공한 호출의 결과.
+func (s *BatchSummary) Succeeded() []CallResult {
+	var out []CallResult
+	for _, r := range s.Results {
+		if r.Response != nil && r.Err == nil {
+			out = append(out, r)
+		}
+	}
+	return out
+}
+
+// Failed 는 실패했거나 실행되지 못한 호출의 결과.
+func (s *BatchSummary) Failed() []CallResult {
+	var out []CallResult
+	for _, r := range s.Results {
+		if r.Err != nil {
+			out = append(out, r)
+		}
+	}
+	return out
+}
+
+// String 은 "2/3 calls succeeded; failed: getCurrentWeather#1 (...)" 형태의 요약.
+func (s *BatchSummary) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d/%d calls succeeded", len(s.Succeeded()), len(s.Results))
+	sep := "; failed: "
+	for i, r := range s.Results {
+		if r.Err == nil {
+			continue
+		}
+		fmt.Fprintf(&b, "%s%s#%d (%v, %d attempts)", sep, r.Call.Name, i, r.Err, r.Attempts)
+		sep = ", "
+	}
+	return b.String()
+}
+
+// Execute 는 calls 를 ExecuteBatch 로 실행하고 모델에 돌려줄 응답만 호출 순서대로 반환한다.
+func (r *Registry) Execute(ctx context.Context, calls []genai.FunctionCall, opts ExecOptions) ([]*genai.FunctionResponse, error) {
+	summary, err := r.ExecuteBatch(ctx, calls, opts)
+	if err != nil {
+		return nil, err
+	}
+	return summary.Responses(), nil
+}
+
+// ExecuteBatch 는 calls 를 제한된 수의 워커로 동시에 실행하고, 결과를 calls 와 같은 순서로 요약한다.
+//
+// 실패한 호출은 opts.Policy 에 따라 처리된다. FailFast 이거나 실패한 도구의 OnError 가
+// AbortOnError 이면 실행 중인 나머지 호출을 취소하고 그 실패를 *CallError 로 반환한다.
+// 이때도 그때까지의 결과를 담은 요약이 함께 반환된다.
+func (r *Registry) ExecuteBatch(ctx context.Context, calls []genai.FunctionCall, opts ExecOptions) (*BatchSummary, error) {
+	summary := &BatchSummary{Results: make([]CallResult, len(calls))}
+	pending := make([]int, len(calls))
+	for i, call := range calls {
+		summary.Results[i].Call = call
+		pending[i] = i
+	}
+
+	attempts := 1
+	if opts.Policy == RetryFailed {
+		retries := opts.Retries
+		if retries <= 0 {
+			retries = DefaultRetries
+		}
+		attempts += retries
+	}
+
+	for attempt := 1; len(pending) > 0; attempt++ {
+		if err := r.runRound(ctx, summary, pending, opts); err != nil {
+			return summary, err
+		}
+		var retry []int
+		for _, i := range pending {
+			if err := summary.Results[i].Err; IsRetryable(err) {
+				retry = append(retry, i)
+			}
+		}
+		if len(retry) == 0 || attempt == attempts {
+			break
+		}
+		pending = retry
+		if opts.RetryBackoff > 0 {
+			select {
+			case <-time.After(opts.RetryBackoff):
+			case <-ctx.Done():
+				return summary, ctx.Err()
+			}
+		}
+	}
+
+	// 끝내 실패한 호출은 도구별 오류 정책을 적용해 오류 응답으로 바꾼다
+	for i := range summary.Results {
+		res := &summary.Results[i]
+		if res.Err == nil {
+			continue
+		}
+		resp, err := r.resolve(res.Call, nil, res.Err)
+		if err != nil {
+			return summary, &CallError{Call: res.Call, Err: err}
+		}
+		res.Response = resp
+	}
+	return summary, nil
+}
+
+// runRound 는 pending 에 해당하는 호출을 한 번씩 동시에 실행해 summary 에 기록한다.
+// 중단해야 하는 실패가 생기면 나머지를 취소하고 *CallError 를 반환한다.
+func (r *Registry) runRound(ctx context.Context, summary *BatchSummary, pending []int, opts ExecOptions) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	workers := opts.Workers
+	if workers <= 0 || workers > len(pending) {
+		workers = len(pending)
+	}
+	sem := make(chan struct{}, workers)
+
+	var (
+		wg        sync.WaitGroup
+		abortOnce sync.Once
+		abortErr  error
+	)
+	for _, i := range pending {
+		res := &summary.Results[i]
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			select {
+			case sem <- struct{}{}:
+				defer func() { <-sem }()
+			case <-ctx.Done():
+			}
+			// 자리를 기다리는 동안 배치가 취소되었으면 실행하지 않은 것으로 기록한다
+			if err := ctx.Err(); err != nil {
+				res.Err = fmt.Errorf("%s: %w: %w", res.Call.Name, ErrNotRun, err)
+				return
+			}
+
+			start := time.Now()
+			resp, err := r.dispatchTimeout(ctx, res.Call, opts.Timeout)
+			res.Attempts++
+			res.Duration += time.Since(start)
+			res.Response, res.Err = resp, err
+			if err != nil && (opts.Policy == FailFast || r.policyFor(res.Call.Name) == AbortOnError) {
+				abortOnce.Do(func() {
+					abortErr = &CallError{Call: res.Call, Err: err}
+					cancel()
+				})
+			}
+		}()
+	}
+	wg.Wait()
+
+	if abortErr != nil {
+		return abortErr
+	}
+	return ctx.Err()
+}
+
+// dispatchTimeout 은 Dispatch 를 timeout 안에 끝내지 못하면 핸들러를 기다리지 않고 실패를 반환한다.
+func (r *Registry) dispatchTimeout(ctx context.Context, call genai.FunctionCall, timeout time.Duration) (*genai.FunctionResponse, error) {
+	if timeout <= 0 {
+		return r.Dispatch(ctx, call)
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	type result struct {
+		resp *genai.FunctionResponse
+		err  error
+	}
+	done := make(chan result, 1)
+	go func() {
+		resp, err := r.Dispatch(ctx, call)
+		done <- result{resp, err}
+	}()
+	select {
+	case res := <-done:
+		return res.resp, res.err
+	case <-ctx.Done():
+		return nil, fmt.Errorf("%s: %w", call.Name, ctx.Err())
+	}
+}
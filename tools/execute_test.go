@@ -0,0 +1,193 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/vertexai/genai"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestExecuteOrderAndConcurrency(t *testing.T) {
+	var running, peak atomic.Int32
+	r := NewRegistry()
+	r.MustRegister(Tool{Name: "slow", Handler: func(ctx context.Context, args map[string]any) (map[string]any, error) {
+		n := running.Add(1)
+		defer running.Add(-1)
+		for {
+			p := peak.Load()
+			if n <= p || peak.CompareAndSwap(p, n) {
+				break
+			}
+		}
+		// 뒤쪽 호출이 먼저 끝나도 결과 순서는 유지되어야 한다
+		time.Sleep(time.Duration(5-int(args["i"].(float64))) * 5 * time.Millisecond)
+		return map[string]any{"i": args["i"]}, nil
+	}})
+
+	var calls []genai.FunctionCall
+	for i := 0; i < 5; i++ {
+		calls = append(calls, genai.FunctionCall{Name: "slow", Args: map[string]any{"i": float64(i)}})
+	}
+	out, err := r.Execute(context.Background(), calls, ExecOptions{Workers: 2})
+	if err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+	for i, resp := range out {
+		if resp.Response["i"] != float64(i) {
+			t.Errorf("out[%d] = %v", i, resp.Response)
+		}
+	}
+	if peak.Load() > 2 {
+		t.Errorf("peak concurrency = %d, want <= 2", peak.Load())
+	}
+}
+
+func TestExecuteTimeout(t *testing.T) {
+	block := make(chan struct{})
+	defer close(block)
+	r := NewRegistry()
+	r.MustRegister(Tool{Name: "hang", Handler: func(ctx context.Context, args map[string]any) (map[string]any, error) {
+		<-block
+		return nil, nil
+	}})
+	r.MustRegister(Tool{Name: "echo", Handler: echoHandler})
+
+	calls := []genai.FunctionCall{{Name: "hang"}, {Name: "echo", Args: map[string]any{"value": "ok"}}}
+	out, err := r.Execute(context.Background(), calls, ExecOptions{Timeout: 20 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+	// 시간 초과는 오류 응답으로 보고되고 나머지 호출은 그대로 성공한다
+	if !IsErrorResponse(out[0]) || out[1].Response["echo"] != "ok" {
+		t.Errorf("Execute() = %+v, %+v", out[0], out[1])
+	}
+
+	r.MustRegister(Tool{Name: "hangAbort", OnError: AbortOnError, Handler: func(ctx context.Context, args map[string]any) (map[string]any, error) {
+		<-block
+		return nil, nil
+	}})
+	_, err = r.Execute(context.Background(), []genai.FunctionCall{{Name: "hangAbort"}}, ExecOptions{Timeout: 20 * time.Millisecond})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Execute(hangAbort) error = %v, want DeadlineExceeded", err)
+	}
+}
+
+// flakyRegistry 는 "flaky" 가 처음 failures 번 일시적으로 실패하고, "broken" 은 항상 일시적으로,
+// "permanent" 는 다시 해도 같은 오류로 실패하는 레지스트리.
+func flakyRegistry(failures int32) *Registry {
+	var n atomic.Int32
+	r := NewRegistry()
+	r.MustRegister(Tool{Name: "ok", Handler: echoHandler})
+	r.MustRegister(Tool{Name: "flaky", Handler: func(ctx context.Context, args map[string]any) (map[string]any, error) {
+		if n.Add(1) <= failures {
+			return nil, Transient(errors.New("temporary failure"))
+		}
+		return map[string]any{"ok": true}, nil
+	}})
+	r.MustRegister(Tool{Name: "broken", Handler: func(ctx context.Context, args map[string]any) (map[string]any, error) {
+		return nil, Transient(errors.New("backend down"))
+	}})
+	r.MustRegister(Tool{Name: "permanent", Handler: func(ctx context.Context, args map[string]any) (map[string]any, error) {
+		return nil, errors.New("not found")
+	}})
+	return r
+}
+
+func TestExecuteBatchPolicies(t *testing.T) {
+	calls := []genai.FunctionCall{{Name: "ok"}, {Name: "flaky"}, {Name: "broken"}, {Name: "missing"}}
+	ctx := context.Background()
+
+	t.Run("BestEffort", func(t *testing.T) {
+		summary, err := flakyRegistry(1).ExecuteBatch(ctx, calls, ExecOptions{})
+		if err != nil {
+			t.Fatalf("ExecuteBatch() error = %v", err)
+		}
+		if len(summary.Succeeded()) != 1 || len(summary.Failed()) != 3 {
+			t.Errorf("summary = %s", summary)
+		}
+		for i, resp := range summary.Responses() {
+			if IsErrorResponse(resp) != (i != 0) {
+				t.Errorf("response %d = %+v", i, resp)
+			}
+		}
+	})
+
+	t.Run("FailFast", func(t *testing.T) {
+		summary, err := flakyRegistry(1).ExecuteBatch(ctx, calls, ExecOptions{Policy: FailFast, Workers: 1})
+		var callErr *CallError
+		if !errors.As(err, &callErr) || summary == nil {
+			t.Fatalf("ExecuteBatch() = %v, %v, want CallError with summary", summary, err)
+		}
+
+		// 워커가 하나뿐이면 첫 실패 뒤의 호출은 실행되지 않고, 성공으로 집계되지도 않는다
+		broken := []genai.FunctionCall{{Name: "broken"}, {Name: "broken"}, {Name: "broken"}, {Name: "broken"}}
+		summary, err = flakyRegistry(0).ExecuteBatch(ctx, broken, ExecOptions{Policy: FailFast, Workers: 1})
+		if !errors.As(err, &callErr) {
+			t.Fatalf("ExecuteBatch(broken) error = %v, want CallError", err)
+		}
+		notRun := 0
+		for _, res := range summary.Results {
+			if errors.Is(res.Err, ErrNotRun) && res.Attempts == 0 {
+				notRun++
+			}
+		}
+		if notRun != 3 || len(summary.Succeeded()) != 0 || len(summary.Failed()) != 4 {
+			t.Errorf("not run = %d, summary = %s", notRun, summary)
+		}
+	})
+
+	t.Run("RetryFailed", func(t *testing.T) {
+		calls := append(calls, genai.FunctionCall{Name: "permanent"})
+		summary, err := flakyRegistry(2).ExecuteBatch(ctx, calls, ExecOptions{Policy: RetryFailed, Retries: 2})
+		if err != nil {
+			t.Fatalf("ExecuteBatch() error = %v", err)
+		}
+		// flaky 는 세 번째 시도에 성공, broken 은 재시도 후에도 실패,
+		// missing 과 일시적이지 않은 permanent 는 재시도하지 않는다
+		attempts := []int{1, 3, 3, 1, 1}
+		for i, res := range summary.Results {
+			if res.Attempts != attempts[i] {
+				t.Errorf("%s attempts = %d, want %d", res.Call.Name, res.Attempts, attempts[i])
+			}
+		}
+		if len(summary.Succeeded()) != 2 {
+			t.Errorf("summary = %s", summary)
+		}
+	})
+}
+
+func TestIsRetryable(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"nil", nil, false},
+		{"plain", errors.New("not found"), false},
+		{"transient", fmt.Errorf("lookup: %w", Transient(errors.New("backend down"))), true},
+		{"timeout", fmt.Errorf("call: %w", context.DeadlineExceeded), true},
+		{"canceled", context.Canceled, false},
+		{"unavailable", status.Error(codes.Unavailable, "try again"), true},
+		{"not found", status.Error(codes.NotFound, "no such thing"), false},
+		{"opt out", retryable(false), false},
+		{"unknown function", fmt.Errorf("%w: nope", ErrUnknownFunction), false},
+		{"invalid argument", &ArgumentError{Path: "x", Msg: "required"}, false},
+	}
+	for _, tt := range tests {
+		if got := IsRetryable(tt.err); got != tt.want {
+			t.Errorf("IsRetryable(%s) = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+// retryable 은 Retryable() 로 재시도 여부를 직접 밝히는 오류.
+type retryable bool
+
+func (r retryable) Error() string   { return "retryable" }
+func (r retryable) Retryable() bool { return bool(r) }
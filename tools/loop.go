@@ -0,0 +1,82 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"cloud.google.com/go/vertexai/genai"
+
+	"vertex/response"
+)
+
+// DefaultMaxSteps 는 Loop.MaxSteps 가 0 일 때 쓰는 함수 호출 단계 상한.
+const DefaultMaxSteps = 5
+
+// ErrMaxSteps 는 모델이 MaxSteps 를 넘겨서도 계속 함수 호출을 요청할 때 반환된다.
+var ErrMaxSteps = errors.New("tools: max steps exceeded")
+
+// Sender 는 대화 세션. *genai.ChatSession 이 이를 만족한다.
+type Sender interface {
+	SendMessage(ctx context.Context, parts ...genai.Part) (*genai.GenerateContentResponse, error)
+}
+
+// CallError 는 루프 도중 AbortOnError 정책인 함수 호출이 실패했을 때 반환된다.
+type CallError struct {
+	Call genai.FunctionCall
+	Err  error
+}
+
+func (e *CallError) Error() string { return fmt.Sprintf("function call %s: %v", e.Call.Name, e.Err) }
+func (e *CallError) Unwrap() error { return e.Err }
+
+// Loop 는 모델이 텍스트로 답할 때까지 함수 호출을 실행하고 결과를 돌려보내는 에이전트 루프.
+type Loop struct {
+	Registry *Registry
+	// MaxSteps 는 함수 호출을 실행하는 최대 왕복 횟수. 0 이면 DefaultMaxSteps.
+	MaxSteps int
+	// Exec 은 한 응답에 담긴 여러 함수 호출을 동시에 실행할 때의 설정.
+	Exec ExecOptions
+	// OnCall 은 함수 호출이 실행될 때마다 호출된다 (로그 출력 등). nil 이면 무시.
+	// 실패가 모델에 보고된 호출이면 resp 는 ErrorResponse 이다.
+	OnCall func(call genai.FunctionCall, resp *genai.FunctionResponse)
+}
+
+// Run 은 parts 를 보내고, 응답에 함수 호출이 있으면 실행 결과를 다시 보내는 과정을 반복한다.
+// 함수 호출이 없는 응답을 받으면 그 응답을 읽은 결과를 반환한다.
+// 상한에 도달하면 마지막 결과와 ErrMaxSteps 를 함께 반환한다.
+// 응답 오류는 response.Read 의 분류(*response.BlockedError 등)를 따른다.
+func (l *Loop) Run(ctx context.Context, s Sender, parts ...genai.Part) (*response.Result, error) {
+	maxSteps := l.MaxSteps
+	if maxSteps <= 0 {
+		maxSteps = DefaultMaxSteps
+	}
+
+	res, err := response.Read(s.SendMessage(ctx, parts...))
+	for step := 0; ; step++ {
+		if err != nil {
+			return res, err
+		}
+		if len(res.FunctionCalls) == 0 {
+			return res, nil
+		}
+		if step == maxSteps {
+			return res, ErrMaxSteps
+		}
+
+		// 한 응답에 담긴 여러 호출은 동시에 실행하고 원래 순서대로 돌려보낸다
+		funresps, execErr := l.Registry.Execute(ctx, res.FunctionCalls, l.Exec)
+		if execErr != nil {
+			return res, execErr
+		}
+		replies := make([]genai.Part, 0, len(funresps))
+		for i, funresp := range funresps {
+			if l.OnCall != nil {
+				l.OnCall(res.FunctionCalls[i], funresp)
+			}
+			replies = append(replies, funresp)
+		}
+
+		res, err = response.Read(s.SendMessage(ctx, replies...))
+	}
+}
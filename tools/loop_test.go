@@ -0,0 +1,105 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"cloud.google.com/go/vertexai/genai"
+)
+
+// scriptedSender 는 미리 정한 응답을 순서대로 돌려주고 보낸 메시지를 기록한다.
+type scriptedSender struct {
+	replies []*genai.GenerateContentResponse
+	sent    [][]genai.Part
+}
+
+func (s *scriptedSender) SendMessage(ctx context.Context, parts ...genai.Part) (*genai.GenerateContentResponse, error) {
+	s.sent = append(s.sent, parts)
+	if len(s.replies) == 0 {
+		return nil, errors.New("no scripted reply")
+	}
+	resp := s.replies[0]
+	s.replies = s.replies[1:]
+	return resp, nil
+}
+
+func modelReply(parts ...genai.Part) *genai.GenerateContentResponse {
+	return &genai.GenerateContentResponse{Candidates: []*genai.Candidate{{
+		Content:      &genai.Content{Role: "model", Parts: parts},
+		FinishReason: genai.FinishReasonStop,
+	}}}
+}
+
+func TestLoopChainsCalls(t *testing.T) {
+	r := NewRegistry()
+	r.MustRegister(Tool{Name: "echo", Handler: echoHandler})
+
+	s := &scriptedSender{replies: []*genai.GenerateContentResponse{
+		modelReply(genai.FunctionCall{Name: "echo", Args: map[string]any{"value": "sku"}}),
+		modelReply(genai.FunctionCall{Name: "echo", Args: map[string]any{"value": "store"}}),
+		modelReply(genai.Text("done")),
+	}}
+	var called []string
+	loop := &Loop{Registry: r, OnCall: func(call genai.FunctionCall, resp *genai.FunctionResponse) {
+		called = append(called, call.Args["value"].(string))
+	}}
+
+	res, err := loop.Run(context.Background(), s, genai.Text("hi"))
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if res.Text != "done" {
+		t.Errorf("final answer = %q", res.Text)
+	}
+	if len(called) != 2 || called[0] != "sku" || called[1] != "store" || len(s.sent) != 3 {
+		t.Errorf("called = %v, sent %d messages", called, len(s.sent))
+	}
+}
+
+func TestLoopMaxSteps(t *testing.T) {
+	r := NewRegistry()
+	r.MustRegister(Tool{Name: "echo", Handler: echoHandler})
+
+	call := modelReply(genai.FunctionCall{Name: "echo"})
+	s := &scriptedSender{replies: []*genai.GenerateContentResponse{call, call, call}}
+	loop := &Loop{Registry: r, MaxSteps: 2}
+
+	res, err := loop.Run(context.Background(), s, genai.Text("hi"))
+	if !errors.Is(err, ErrMaxSteps) || res == nil {
+		t.Errorf("Run() = %v, %v, want last result and ErrMaxSteps", res, err)
+	}
+}
+
+func TestLoopUnknownFunction(t *testing.T) {
+	s := &scriptedSender{replies: []*genai.GenerateContentResponse{
+		modelReply(genai.FunctionCall{Name: "missing"}),
+	}}
+	r := NewRegistry()
+	r.OnUnknown = AbortOnError
+	_, err := (&Loop{Registry: r}).Run(context.Background(), s, genai.Text("hi"))
+	var callErr *CallError
+	if !errors.As(err, &callErr) || callErr.Call.Name != "missing" || !errors.Is(err, ErrUnknownFunction) {
+		t.Errorf("Run() error = %v, want CallError wrapping ErrUnknownFunction", err)
+	}
+}
+
+func TestLoopReportsFailures(t *testing.T) {
+	r := NewRegistry()
+	r.MustRegister(Tool{Name: "echo", Handler: echoHandler})
+
+	s := &scriptedSender{replies: []*genai.GenerateContentResponse{
+		modelReply(genai.FunctionCall{Name: "missing"}),
+		modelReply(genai.FunctionCall{Name: "echo", Args: map[string]any{"value": "x"}}),
+		modelReply(genai.Text("recovered")),
+	}}
+	res, err := (&Loop{Registry: r}).Run(context.Background(), s, genai.Text("hi"))
+	if err != nil || res.Text != "recovered" {
+		t.Fatalf("Run() = %v, %v", res, err)
+	}
+	// 알 수 없는 함수는 오류 응답으로 모델에 전달되어 대화가 이어진다
+	funresp := s.sent[1][0].(*genai.FunctionResponse)
+	if !IsErrorResponse(funresp) || funresp.Name != "missing" {
+		t.Errorf("sent %+v, want error response for missing", funresp)
+	}
+}
@@ -0,0 +1,127 @@
+// Package tools 는 모델에 노출할 함수(도구)의 선언과 핸들러를 한 곳에서 관리한다.
+//
+// 도구는 이름, 설명, 파라미터 스키마, Go 핸들러를 묶어 한 번만 등록하고,
+// Registry 가 model.Tools 생성과 genai.FunctionCall 디스패치를 모두 담당한다.
+package tools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"cloud.google.com/go/vertexai/genai"
+)
+
+// ErrUnknownFunction 은 등록되지 않은 함수 호출을 디스패치할 때 반환된다.
+var ErrUnknownFunction = errors.New("unknown function")
+
+// Handler 는 모델이 요청한 함수 호출을 실제로 수행한다.
+// args 는 genai.FunctionCall.Args 그대로이며, 반환값은 FunctionResponse.Response 로 전달된다.
+type Handler func(ctx context.Context, args map[string]any) (map[string]any, error)
+
+// Tool 은 모델에 노출되는 함수 하나의 선언과 핸들러.
+type Tool struct {
+	Name        string
+	Description string
+	Parameters  *genai.Schema
+	Handler     Handler
+	// OnError 는 이 도구가 실패하거나 인자가 잘못되었을 때의 처리 방식. 기본값은 ReportErrors.
+	OnError ErrorPolicy
+}
+
+// Declaration 은 tool 을 모델에 전달할 FunctionDeclaration 으로 변환한다.
+func (t *Tool) Declaration() *genai.FunctionDeclaration {
+	return &genai.FunctionDeclaration{
+		Name:        t.Name,
+		Description: t.Description,
+		Parameters:  t.Parameters,
+	}
+}
+
+// Registry 는 등록된 도구 목록. 등록 순서를 유지하며 동시 사용에 안전하다.
+type Registry struct {
+	// OnUnknown 은 등록되지 않은 함수 호출의 처리 방식. 기본값은 ReportErrors.
+	OnUnknown ErrorPolicy
+
+	mu    sync.RWMutex
+	tools map[string]*Tool
+	order []string
+}
+
+// NewRegistry 는 빈 Registry 를 만든다.
+func NewRegistry() *Registry {
+	return &Registry{tools: make(map[string]*Tool)}
+}
+
+// Register 는 tool 을 등록한다. 이름이 비었거나 핸들러가 없거나 이미 등록된 이름이면 오류.
+func (r *Registry) Register(t Tool) error {
+	if t.Name == "" {
+		return errors.New("tools: empty tool name")
+	}
+	if t.Handler == nil {
+		return fmt.Errorf("tools: nil handler for %q", t.Name)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.tools[t.Name]; ok {
+		return fmt.Errorf("tools: duplicate tool %q", t.Name)
+	}
+	r.tools[t.Name] = &t
+	r.order = append(r.order, t.Name)
+	return nil
+}
+
+// MustRegister 는 Register 와 같지만 실패하면 panic 한다. 초기화 코드용.
+func (r *Registry) MustRegister(t Tool) {
+	if err := r.Register(t); err != nil {
+		panic(err)
+	}
+}
+
+// Lookup 은 이름으로 등록된 도구를 찾는다.
+func (r *Registry) Lookup(name string) (*Tool, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	t, ok := r.tools[name]
+	return t, ok
+}
+
+// Declarations 는 등록 순서대로 FunctionDeclaration 목록을 만든다.
+func (r *Registry) Declarations() []*genai.FunctionDeclaration {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	decls := make([]*genai.FunctionDeclaration, 0, len(r.order))
+	for _, name := range r.order {
+		decls = append(decls, r.tools[name].Declaration())
+	}
+	return decls
+}
+
+// Tools 는 model.Tools 에 그대로 대입할 수 있는 값을 만든다.
+func (r *Registry) Tools() []*genai.Tool {
+	return []*genai.Tool{{FunctionDeclarations: r.Declarations()}}
+}
+
+// Dispatch 는 call 을 등록된 핸들러로 실행하고 모델에 돌려줄 FunctionResponse 를 만든다.
+// 등록되지 않은 함수면 ErrUnknownFunction 을 감싼 오류를 반환하고,
+// 핸들러 결과에 ErrorKey 가 있으면 오류 응답과 구별할 수 없으므로 실패로 처리한다.
+// 오류 정책을 적용하려면 Handle 을 사용한다.
+func (r *Registry) Dispatch(ctx context.Context, call genai.FunctionCall) (*genai.FunctionResponse, error) {
+	t, ok := r.Lookup(call.Name)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownFunction, call.Name)
+	}
+	result, err := t.Handler(ctx, call.Args)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", call.Name, err)
+	}
+	if _, ok := result[ErrorKey]; ok {
+		return nil, fmt.Errorf("%s: result uses reserved key %q", call.Name, ErrorKey)
+	}
+	return &genai.FunctionResponse{
+		Name:     call.Name,
+		Response: result,
+	}, nil
+}
@@ -0,0 +1,91 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"cloud.google.com/go/vertexai/genai"
+)
+
+func echoHandler(ctx context.Context, args map[string]any) (map[string]any, error) {
+	return map[string]any{"echo": args["value"]}, nil
+}
+
+func TestRegistry(t *testing.T) {
+	r := NewRegistry()
+	r.MustRegister(Tool{Name: "b", Description: "second", Handler: echoHandler})
+	r.MustRegister(Tool{Name: "a", Description: "first", Handler: echoHandler})
+
+	// 선언은 등록 순서를 유지해야 한다
+	decls := r.Tools()[0].FunctionDeclarations
+	if len(decls) != 2 || decls[0].Name != "b" || decls[1].Name != "a" {
+		t.Fatalf("unexpected declarations: %+v", decls)
+	}
+
+	if err := r.Register(Tool{Name: "a", Handler: echoHandler}); err == nil {
+		t.Error("duplicate Register() error = nil")
+	}
+	if err := r.Register(Tool{Name: "c"}); err == nil {
+		t.Error("Register() without handler error = nil")
+	}
+
+	resp, err := r.Dispatch(context.Background(), genai.FunctionCall{Name: "a", Args: map[string]any{"value": "x"}})
+	if err != nil {
+		t.Fatalf("Dispatch() error = %v", err)
+	}
+	if resp.Name != "a" || resp.Response["echo"] != "x" {
+		t.Errorf("Dispatch() = %+v", resp)
+	}
+
+	_, err = r.Dispatch(context.Background(), genai.FunctionCall{Name: "missing"})
+	if !errors.Is(err, ErrUnknownFunction) {
+		t.Errorf("Dispatch(missing) error = %v, want ErrUnknownFunction", err)
+	}
+}
+
+func TestHandlePolicies(t *testing.T) {
+	type args struct {
+		Count int `json:"count" schema:"required,min=1"`
+	}
+	r := NewRegistry()
+	r.MustRegister(MustNewTool("count", "", func(ctx context.Context, a args) (map[string]any, error) {
+		return map[string]any{"count": a.Count}, nil
+	}))
+	r.MustRegister(Tool{Name: "fail", OnError: AbortOnError, Handler: func(ctx context.Context, args map[string]any) (map[string]any, error) {
+		return nil, errors.New("backend down")
+	}})
+	ctx := context.Background()
+
+	resp, err := r.Handle(ctx, genai.FunctionCall{Name: "count", Args: map[string]any{"count": 0.0}})
+	if err != nil || !IsErrorResponse(resp) {
+		t.Fatalf("Handle(invalid) = %+v, %v", resp, err)
+	}
+	payload := resp.Response[ErrorKey].(map[string]any)
+	if payload["code"] != CodeInvalidArgument || len(payload["fields"].([]any)) != 1 {
+		t.Errorf("invalid argument payload = %v", payload)
+	}
+
+	resp, err = r.Handle(ctx, genai.FunctionCall{Name: "nope"})
+	if err != nil || resp.Response[ErrorKey].(map[string]any)["code"] != CodeUnknownFunction {
+		t.Errorf("Handle(unknown) = %+v, %v", resp, err)
+	}
+
+	if _, err := r.Handle(ctx, genai.FunctionCall{Name: "fail"}); err == nil {
+		t.Error("Handle(fail) with AbortOnError error = nil")
+	}
+
+	// 결과에 "error" 가 있어도 성공한 호출이고, 예약된 ErrorKey 는 쓸 수 없다
+	r.MustRegister(Tool{Name: "lookup", Handler: func(ctx context.Context, args map[string]any) (map[string]any, error) {
+		return map[string]any{"error": "none", "status": "ok"}, nil
+	}})
+	r.MustRegister(Tool{Name: "forge", Handler: func(ctx context.Context, args map[string]any) (map[string]any, error) {
+		return map[string]any{ErrorKey: "fake"}, nil
+	}})
+	if resp, err := r.Handle(ctx, genai.FunctionCall{Name: "lookup"}); err != nil || IsErrorResponse(resp) {
+		t.Errorf("Handle(lookup) = %+v, %v, want a successful response", resp, err)
+	}
+	if _, err := r.Dispatch(ctx, genai.FunctionCall{Name: "forge"}); err == nil {
+		t.Error("Dispatch(forge) error = nil, want reserved key error")
+	}
+}
@@ -0,0 +1,510 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+
+	"cloud.google.com/go/vertexai/genai"
+)
+
+// 구조체 필드 태그 규칙
+//
+//	json:"name"           파라미터 이름 (없으면 필드 이름, "-" 이면 제외)
+//	                      이름이 없는 임베디드 구조체는 encoding/json 처럼 필드를 바깥으로 펼친다
+//	description:"..."     파라미터 설명
+//	schema:"required,enum=a|b,min=1,max=10,format=email"
+//
+// min/max 는 숫자 타입이면 값의 범위, 문자열이면 길이, 슬라이스면 원소 개수에 적용된다.
+// 배열([N]T)은 정확히 N 개의 원소를 요구한다.
+
+// ArgumentError 는 FunctionCall.Args 를 구조체로 디코딩하다 발견한 문제 하나.
+type ArgumentError struct {
+	Path string // 예: "items[2].quantity"
+	Msg  string
+}
+
+func (e *ArgumentError) Error() string {
+	return fmt.Sprintf("argument %s: %s", e.Path, e.Msg)
+}
+
+type fieldSpec struct {
+	index       []int // reflect.Type.FieldByIndex 경로
+	name        string
+	description string
+	required    bool
+	enum        []string
+	min, max    *float64
+	format      string
+}
+
+func parseField(f reflect.StructField) (fieldSpec, bool, error) {
+	spec := fieldSpec{index: f.Index, name: f.Name, description: f.Tag.Get("description")}
+	if tag, ok := f.Tag.Lookup("json"); ok {
+		name, _, _ := strings.Cut(tag, ",")
+		if name == "-" {
+			return spec, false, nil
+		}
+		if name != "" {
+			spec.name = name
+		}
+	}
+	tag := f.Tag.Get("schema")
+	if tag == "" {
+		return spec, true, nil
+	}
+	for _, opt := range strings.Split(tag, ",") {
+		key, val, _ := strings.Cut(strings.TrimSpace(opt), "=")
+		switch key {
+		case "required":
+			spec.required = true
+		case "enum":
+			spec.enum = strings.Split(val, "|")
+		case "min", "max":
+			n, err := strconv.ParseFloat(val, 64)
+			if err != nil {
+				return spec, false, fmt.Errorf("tools: field %s: invalid %s %q", f.Name, key, val)
+			}
+			if key == "min" {
+				spec.min = &n
+			} else {
+				spec.max = &n
+			}
+		case "format":
+			spec.format = val
+		case "":
+		default:
+			return spec, false, fmt.Errorf("tools: field %s: unknown schema option %q", f.Name, key)
+		}
+	}
+	return spec, true, nil
+}
+
+// structFields 는 t 의 인자 필드들을 encoding/json 과 같은 규칙으로 모은다.
+// json 이름이 없는 임베디드 구조체(와 그 포인터)의 필드는 바깥 구조체의 필드로 펼친다.
+// 같은 이름이 여럿이면 덜 깊이 임베디드된 필드가, 같은 깊이에서는 json 태그로 이름을 붙인
+// 필드 하나가 이기며, 그래도 가릴 수 없으면 그 이름은 모두 뺀다.
+func structFields(t reflect.Type) ([]fieldSpec, error) {
+	type embedded struct {
+		t     reflect.Type
+		index []int
+	}
+	type candidate struct {
+		spec   fieldSpec
+		tagged bool
+	}
+	byName := make(map[string][]candidate)
+	visited := make(map[reflect.Type]bool)
+	for level := []embedded{{t, nil}}; len(level) > 0; {
+		var next []embedded
+		for _, e := range level {
+			if visited[e.t] {
+				continue
+			}
+			visited[e.t] = true
+			for i := 0; i < e.t.NumField(); i++ {
+				f := e.t.Field(i)
+				f.Index = append(slices.Clone(e.index), i)
+				name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+				ft := f.Type
+				if ft.Kind() == reflect.Pointer {
+					ft = ft.Elem()
+				}
+				if f.Anonymous && name == "" && ft.Kind() == reflect.Struct {
+					// 내보내지 않은 구조체의 포인터는 Decode 가 만들 수 없다
+					if f.IsExported() || f.Type.Kind() == reflect.Struct {
+						next = append(next, embedded{ft, f.Index})
+					}
+					continue
+				}
+				if !f.IsExported() {
+					continue
+				}
+				spec, ok, err := parseField(f)
+				if err != nil {
+					return nil, err
+				}
+				if ok {
+					byName[spec.name] = append(byName[spec.name], candidate{spec, name != ""})
+				}
+			}
+		}
+		level = next
+	}
+
+	var specs []fieldSpec
+	for _, cands := range byName {
+		depth := len(cands[0].spec.index)
+		var top []candidate
+		for _, c := range cands {
+			switch d := len(c.spec.index); {
+			case d < depth:
+				depth, top = d, []candidate{c}
+			case d == depth:
+				top = append(top, c)
+			}
+		}
+		if len(top) > 1 {
+			top = slices.DeleteFunc(top, func(c candidate) bool { return !c.tagged })
+		}
+		if len(top) == 1 {
+			specs = append(specs, top[0].spec)
+		}
+	}
+	slices.SortFunc(specs, func(a, b fieldSpec) int { return slices.Compare(a.index, b.index) })
+	return specs, nil
+}
+
+// SchemaOf 는 v 의 타입(보통 인자 구조체)에서 genai.Schema 를 만든다.
+func SchemaOf(v any) (*genai.Schema, error) {
+	t := reflect.TypeOf(v)
+	if t == nil {
+		return nil, errors.New("tools: SchemaOf(nil)")
+	}
+	return schemaForType(t, make(map[reflect.Type]bool))
+}
+
+// schemaForType 은 t 의 스키마를 만든다. inProgress 는 만들고 있는 구조체 타입들로,
+// 자기 자신을 품는 타입(type Node struct{ Children []Node })은 스키마로 펼칠 수 없어 오류가 된다.
+func schemaForType(t reflect.Type, inProgress map[reflect.Type]bool) (*genai.Schema, error) {
+	switch t.Kind() {
+	case reflect.Pointer:
+		s, err := schemaForType(t.Elem(), inProgress)
+		if err != nil {
+			return nil, err
+		}
+		s.Nullable = true
+		return s, nil
+	case reflect.String:
+		return &genai.Schema{Type: genai.TypeString}, nil
+	case reflect.Bool:
+		return &genai.Schema{Type: genai.TypeBoolean}, nil
+	case reflect.Int, reflect.Int64, reflect.Uint, reflect.Uint64:
+		return &genai.Schema{Type: genai.TypeInteger, Format: "int64"}, nil
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return &genai.Schema{Type: genai.TypeInteger, Format: "int32"}, nil
+	case reflect.Float32:
+		return &genai.Schema{Type: genai.TypeNumber, Format: "float"}, nil
+	case reflect.Float64:
+		return &genai.Schema{Type: genai.TypeNumber, Format: "double"}, nil
+	case reflect.Slice, reflect.Array:
+		items, err := schemaForType(t.Elem(), inProgress)
+		if err != nil {
+			return nil, err
+		}
+		s := &genai.Schema{Type: genai.TypeArray, Items: items}
+		if t.Kind() == reflect.Array {
+			s.MinItems, s.MaxItems = int64(t.Len()), int64(t.Len())
+		}
+		return s, nil
+	case reflect.Map:
+		if t.Key().Kind() != reflect.String {
+			return nil, fmt.Errorf("tools: unsupported map key type %s", t.Key())
+		}
+		return &genai.Schema{Type: genai.TypeObject}, nil
+	case reflect.Struct:
+		if inProgress[t] {
+			return nil, fmt.Errorf("tools: recursive type %s is not supported", t)
+		}
+		inProgress[t] = true
+		defer delete(inProgress, t)
+		fields, err := structFields(t)
+		if err != nil {
+			return nil, err
+		}
+		s := &genai.Schema{Type: genai.TypeObject, Properties: make(map[string]*genai.Schema, len(fields))}
+		for _, f := range fields {
+			fs, err := schemaForType(t.FieldByIndex(f.index).Type, inProgress)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", f.name, err)
+			}
+			applySpec(fs, f)
+			s.Properties[f.name] = fs
+			if f.required {
+				s.Required = append(s.Required, f.name)
+			}
+		}
+		return s, nil
+	default:
+		return nil, fmt.Errorf("tools: unsupported type %s", t)
+	}
+}
+
+func applySpec(s *genai.Schema, f fieldSpec) {
+	s.Description = f.description
+	if f.format != "" {
+		s.Format = f.format
+	}
+	if len(f.enum) > 0 {
+		s.Enum = f.enum
+		if s.Type == genai.TypeString && s.Format == "" {
+			s.Format = "enum"
+		}
+	}
+	switch s.Type {
+	case genai.TypeInteger, genai.TypeNumber:
+		if f.min != nil {
+			s.Minimum = *f.min
+		}
+		if f.max != nil {
+			s.Maximum = *f.max
+		}
+	case genai.TypeString:
+		if f.min != nil {
+			s.MinLength = int64(*f.min)
+		}
+		if f.max != nil {
+			s.MaxLength = int64(*f.max)
+		}
+	case genai.TypeArray:
+		if f.min != nil {
+			s.MinItems = int64(*f.min)
+		}
+		if f.max != nil {
+			s.MaxItems = int64(*f.max)
+		}
+	}
+}
+
+// Decode 는 FunctionCall.Args 를 dst(구조체 포인터)로 디코딩하고 태그 제약을 검사한다.
+// 문제가 있으면 모든 *ArgumentError 를 errors.Join 으로 묶어 반환한다.
+func Decode(args map[string]any, dst any) error {
+	rv := reflect.ValueOf(dst)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() {
+		return errors.New("tools: Decode requires a non-nil pointer")
+	}
+	var errs []error
+	decodeValue(args, rv.Elem(), "", &errs)
+	return errors.Join(errs...)
+}
+
+func decodeValue(src any, dst reflect.Value, path string, errs *[]error) {
+	fail := func(format string, a ...any) {
+		p := path
+		if p == "" {
+			p = "(root)"
+		}
+		*errs = append(*errs, &ArgumentError{Path: p, Msg: fmt.Sprintf(format, a...)})
+	}
+
+	if src == nil {
+		return
+	}
+	switch dst.Kind() {
+	case reflect.Pointer:
+		v := reflect.New(dst.Type().Elem())
+		decodeValue(src, v.Elem(), path, errs)
+		dst.Set(v)
+	case reflect.String:
+		s, ok := src.(string)
+		if !ok {
+			fail("expected string, got %T", src)
+			return
+		}
+		dst.SetString(s)
+	case reflect.Bool:
+		b, ok := src.(bool)
+		if !ok {
+			fail("expected boolean, got %T", src)
+			return
+		}
+		dst.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, ok := src.(float64)
+		if !ok || n != math.Trunc(n) {
+			fail("expected integer, got %v", src)
+			return
+		}
+		if dst.OverflowInt(int64(n)) {
+			fail("integer %v out of range", n)
+			return
+		}
+		dst.SetInt(int64(n))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, ok := src.(float64)
+		if !ok || n != math.Trunc(n) || n < 0 {
+			fail("expected non-negative integer, got %v", src)
+			return
+		}
+		if dst.OverflowUint(uint64(n)) {
+			fail("integer %v out of range", n)
+			return
+		}
+		dst.SetUint(uint64(n))
+	case reflect.Float32, reflect.Float64:
+		n, ok := src.(float64)
+		if !ok {
+			fail("expected number, got %T", src)
+			return
+		}
+		dst.SetFloat(n)
+	case reflect.Slice:
+		list, ok := src.([]any)
+		if !ok {
+			fail("expected array, got %T", src)
+			return
+		}
+		s := reflect.MakeSlice(dst.Type(), len(list), len(list))
+		for i, item := range list {
+			decodeValue(item, s.Index(i), fmt.Sprintf("%s[%d]", path, i), errs)
+		}
+		dst.Set(s)
+	case reflect.Array:
+		list, ok := src.([]any)
+		if !ok {
+			fail("expected array, got %T", src)
+			return
+		}
+		if len(list) != dst.Len() {
+			fail("expected %d items, got %d", dst.Len(), len(list))
+			return
+		}
+		for i, item := range list {
+			decodeValue(item, dst.Index(i), fmt.Sprintf("%s[%d]", path, i), errs)
+		}
+	case reflect.Map:
+		m, ok := src.(map[string]any)
+		if !ok {
+			fail("expected object, got %T", src)
+			return
+		}
+		out := reflect.MakeMapWithSize(dst.Type(), len(m))
+		for k, item := range m {
+			v := reflect.New(dst.Type().Elem()).Elem()
+			decodeValue(item, v, joinPath(path, k), errs)
+			out.SetMapIndex(reflect.ValueOf(k), v)
+		}
+		dst.Set(out)
+	case reflect.Struct:
+		m, ok := src.(map[string]any)
+		if !ok {
+			fail("expected object, got %T", src)
+			return
+		}
+		fields, err := structFields(dst.Type())
+		if err != nil {
+			fail("%v", err)
+			return
+		}
+		for _, f := range fields {
+			fpath := joinPath(path, f.name)
+			raw, ok := m[f.name]
+			if !ok || raw == nil {
+				if f.required {
+					*errs = append(*errs, &ArgumentError{Path: fpath, Msg: "required"})
+				}
+				continue
+			}
+			fv := field(dst, f.index)
+			before := len(*errs)
+			decodeValue(raw, fv, fpath, errs)
+			if len(*errs) == before {
+				if msg := checkConstraints(fv, f); msg != "" {
+					*errs = append(*errs, &ArgumentError{Path: fpath, Msg: msg})
+				}
+			}
+		}
+	default:
+		fail("unsupported type %s", dst.Type())
+	}
+}
+
+// field 는 index 경로의 필드. 가는 길의 nil 임베디드 포인터는 새로 만든다.
+func field(v reflect.Value, index []int) reflect.Value {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Pointer {
+			if v.IsNil() {
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v
+}
+
+func joinPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+func checkConstraints(v reflect.Value, f fieldSpec) string {
+	for v.Kind() == reflect.Pointer {
+		v = v.Elem()
+	}
+	var n float64
+	var what string
+	switch v.Kind() {
+	case reflect.String:
+		if len(f.enum) > 0 && !containsString(f.enum, v.String()) {
+			return fmt.Sprintf("%q is not one of %v", v.String(), f.enum)
+		}
+		n, what = float64(len([]rune(v.String()))), "length"
+	case reflect.Slice:
+		n, what = float64(v.Len()), "item count"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, what = float64(v.Int()), "value"
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, what = float64(v.Uint()), "value"
+	case reflect.Float32, reflect.Float64:
+		n, what = v.Float(), "value"
+	default:
+		return ""
+	}
+	if f.min != nil && n < *f.min {
+		return fmt.Sprintf("%s %v is less than minimum %v", what, n, *f.min)
+	}
+	if f.max != nil && n > *f.max {
+		return fmt.Sprintf("%s %v is greater than maximum %v", what, n, *f.max)
+	}
+	return ""
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// NewTool 은 인자 구조체 T 에서 스키마를 만들고, 호출 시 Args 를 T 로 디코딩해 fn 에 넘기는 Tool 을 만든다.
+func NewTool[T any](name, description string, fn func(ctx context.Context, args T) (map[string]any, error)) (Tool, error) {
+	var zero T
+	params, err := SchemaOf(zero)
+	if err != nil {
+		return Tool{}, fmt.Errorf("tools: %s: %w", name, err)
+	}
+	if params.Type != genai.TypeObject {
+		return Tool{}, fmt.Errorf("tools: %s: arguments must be a struct, got %T", name, zero)
+	}
+	return Tool{
+		Name:        name,
+		Description: description,
+		Parameters:  params,
+		Handler: func(ctx context.Context, raw map[string]any) (map[string]any, error) {
+			var args T
+			if err := Decode(raw, &args); err != nil {
+				return nil, err
+			}
+			return fn(ctx, args)
+		},
+	}, nil
+}
+
+// MustNewTool 은 NewTool 과 같지만 실패하면 panic 한다.
+func MustNewTool[T any](name, description string, fn func(ctx context.Context, args T) (map[string]any, error)) Tool {
+	t, err := NewTool(name, description, fn)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
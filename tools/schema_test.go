@@ -0,0 +1,217 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+
+	"cloud.google.com/go/vertexai/genai"
+)
+
+type orderItem struct {
+	SKU      string `json:"sku" schema:"required"`
+	Quantity int    `json:"quantity" schema:"min=1,max=10"`
+}
+
+type orderArgs struct {
+	Customer string      `json:"customer" description:"Customer name" schema:"required,max=20"`
+	Channel  string      `json:"channel" schema:"enum=web|store"`
+	Express  *bool       `json:"express"`
+	Items    []orderItem `json:"items" schema:"min=1"`
+	internal string
+}
+
+func TestSchemaOf(t *testing.T) {
+	s, err := SchemaOf(orderArgs{})
+	if err != nil {
+		t.Fatalf("SchemaOf() error = %v", err)
+	}
+	if s.Type != genai.TypeObject || !reflect.DeepEqual(s.Required, []string{"customer"}) {
+		t.Fatalf("unexpected object schema: %+v", s)
+	}
+	if len(s.Properties) != 4 {
+		t.Errorf("got %d properties, want 4 (unexported fields skipped)", len(s.Properties))
+	}
+	if c := s.Properties["customer"]; c.Description != "Customer name" || c.MaxLength != 20 {
+		t.Errorf("customer schema = %+v", c)
+	}
+	if ch := s.Properties["channel"]; !reflect.DeepEqual(ch.Enum, []string{"web", "store"}) {
+		t.Errorf("channel enum = %v", ch.Enum)
+	}
+	if e := s.Properties["express"]; e.Type != genai.TypeBoolean || !e.Nullable {
+		t.Errorf("express schema = %+v", e)
+	}
+	items := s.Properties["items"]
+	if items.Type != genai.TypeArray || items.MinItems != 1 || items.Items.Properties["quantity"].Maximum != 10 {
+		t.Errorf("items schema = %+v", items)
+	}
+
+	if _, err := SchemaOf(struct{ C chan int }{}); err == nil {
+		t.Error("SchemaOf(chan) error = nil")
+	}
+}
+
+func TestDecode(t *testing.T) {
+	var got orderArgs
+	err := Decode(map[string]any{
+		"customer": "kim",
+		"channel":  "web",
+		"express":  true,
+		"items":    []any{map[string]any{"sku": "GA04834-US", "quantity": float64(2)}},
+	}, &got)
+	if err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+	if got.Customer != "kim" || got.Express == nil || !*got.Express || got.Items[0].Quantity != 2 {
+		t.Errorf("Decode() = %+v", got)
+	}
+
+	// 타입 오류와 제약 위반은 필드 경로와 함께 모두 보고된다
+	err = Decode(map[string]any{
+		"channel": "phone",
+		"items":   []any{map[string]any{"sku": 1.0, "quantity": 2.5}},
+	}, &orderArgs{})
+	var paths []string
+	for _, e := range err.(interface{ Unwrap() []error }).Unwrap() {
+		var ae *ArgumentError
+		if !errors.As(e, &ae) {
+			t.Fatalf("unexpected error type %T", e)
+		}
+		paths = append(paths, ae.Path)
+	}
+	want := []string{"customer", "channel", "items[0].sku", "items[0].quantity"}
+	if !reflect.DeepEqual(paths, want) {
+		t.Errorf("error paths = %v, want %v", paths, want)
+	}
+}
+
+func TestArrayField(t *testing.T) {
+	type point struct {
+		XY [2]float64 `json:"xy" schema:"required"`
+	}
+	s, err := SchemaOf(point{})
+	if err != nil {
+		t.Fatalf("SchemaOf() error = %v", err)
+	}
+	if xy := s.Properties["xy"]; xy.Type != genai.TypeArray || xy.MinItems != 2 || xy.MaxItems != 2 {
+		t.Errorf("xy schema = %+v", xy)
+	}
+
+	var got point
+	if err := Decode(map[string]any{"xy": []any{1.5, 2.0}}, &got); err != nil || got.XY != [2]float64{1.5, 2} {
+		t.Errorf("Decode() = %+v, %v", got, err)
+	}
+	var ae *ArgumentError
+	if err := Decode(map[string]any{"xy": []any{1.0}}, &point{}); !errors.As(err, &ae) || ae.Path != "xy" {
+		t.Errorf("Decode(short array) error = %v", err)
+	}
+}
+
+type pageArgs struct {
+	Cursor string `json:"cursor"`
+	Limit  int    `json:"limit" schema:"max=100"`
+}
+
+type Filter struct {
+	Tag    string `json:"tag" schema:"required"`
+	Cursor string `json:"cursor"` // 같은 깊이의 pageArgs.Cursor 와 겹쳐 둘 다 빠진다
+}
+
+type Meta struct {
+	Source string `json:"source" schema:"required"`
+}
+
+type searchArgs struct {
+	Query string `json:"query" schema:"required"`
+	pageArgs
+	*Filter
+	Meta  `json:"meta"` // 태그로 이름을 붙이면 펼치지 않는다
+	Limit int           `json:"limit"` // 임베디드된 pageArgs.Limit 보다 얕아서 이긴다
+}
+
+func TestEmbeddedFields(t *testing.T) {
+	s, err := SchemaOf(searchArgs{})
+	if err != nil {
+		t.Fatalf("SchemaOf() error = %v", err)
+	}
+	var names []string
+	for name := range s.Properties {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	if want := []string{"limit", "meta", "query", "tag"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("properties = %v, want %v", names, want)
+	}
+	if !reflect.DeepEqual(s.Required, []string{"query", "tag"}) {
+		t.Errorf("required = %v", s.Required)
+	}
+	if l := s.Properties["limit"]; l.Maximum != 0 {
+		t.Errorf("limit schema = %+v, want the outer field", l)
+	}
+	if m := s.Properties["meta"]; m.Type != genai.TypeObject || !reflect.DeepEqual(m.Required, []string{"source"}) {
+		t.Errorf("meta schema = %+v", m)
+	}
+
+	var got searchArgs
+	err = Decode(map[string]any{
+		"query": "vertex",
+		"limit": float64(5),
+		"tag":   "go",
+		"meta":  map[string]any{"source": "web"},
+	}, &got)
+	if err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+	if got.Limit != 5 || got.pageArgs.Limit != 0 || got.Filter == nil || got.Tag != "go" || got.Source != "web" {
+		t.Errorf("Decode() = %+v", got)
+	}
+
+	// 임베디드 포인터는 그 필드가 올 때만 만든다
+	got = searchArgs{}
+	var ae *ArgumentError
+	if err := Decode(map[string]any{"query": "vertex"}, &got); !errors.As(err, &ae) || ae.Path != "tag" {
+		t.Errorf("Decode(no tag) error = %v", err)
+	}
+	if got.Filter != nil {
+		t.Errorf("Filter = %+v, want nil", got.Filter)
+	}
+}
+
+func TestRecursiveType(t *testing.T) {
+	type node struct {
+		Name     string `json:"name"`
+		Children []node `json:"children"`
+	}
+	type list struct {
+		Next *list `json:"next"`
+	}
+	for _, v := range []any{node{}, list{}} {
+		if _, err := SchemaOf(v); err == nil || !strings.Contains(err.Error(), "recursive type") {
+			t.Errorf("SchemaOf(%T) error = %v, want recursive type error", v, err)
+		}
+	}
+
+	// 같은 타입이 여러 번 나오는 것만으로는 재귀가 아니다
+	if _, err := SchemaOf(struct{ A, B orderItem }{}); err != nil {
+		t.Errorf("SchemaOf(repeated type) error = %v", err)
+	}
+}
+
+func TestNewTool(t *testing.T) {
+	tool := MustNewTool("order", "Place an order", func(ctx context.Context, args orderArgs) (map[string]any, error) {
+		return map[string]any{"customer": args.Customer}, nil
+	})
+	if tool.Parameters.Properties["customer"] == nil {
+		t.Fatalf("tool parameters = %+v", tool.Parameters)
+	}
+	out, err := tool.Handler(context.Background(), map[string]any{"customer": "lee"})
+	if err != nil || out["customer"] != "lee" {
+		t.Errorf("Handler() = %v, %v", out, err)
+	}
+	if _, err := tool.Handler(context.Background(), map[string]any{}); err == nil {
+		t.Error("Handler() without required argument error = nil")
+	}
+}
@@ -0,0 +1,443 @@
+package vectorstore
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"slices"
+	"sync"
+)
+
+// HNSW 기본 설정. 값이 클수록 재현율이 오르고 느려진다.
+const (
+	DefaultM              = 16
+	DefaultEfConstruction = 200
+	DefaultEfSearch       = 64
+)
+
+// HNSWConfig 는 HNSW 색인의 설정. 0 인 값은 기본값을 쓴다.
+type HNSWConfig struct {
+	// M 은 노드마다 잇는 이웃 수 (맨 아래 층은 2M). 메모리와 재현율을 함께 늘린다.
+	M int
+	// EfConstruction 은 넣을 때 살펴보는 후보 수. 색인 품질과 넣는 시간을 함께 늘린다.
+	EfConstruction int
+	// EfSearch 는 찾을 때 살펴보는 후보 수. K 보다 작으면 K 를 쓴다.
+	EfSearch int
+	// Seed 는 층을 고르는 난수의 씨앗. 같으면 같은 순서로 넣은 색인이 같아진다.
+	Seed uint64
+}
+
+// HNSW 는 Hierarchical Navigable Small World 그래프로 가까운 문서를 근사적으로 찾는 저장소.
+// 전부 비교하는 Memory 보다 훨씬 빠르지만 가장 가까운 문서를 놓칠 수 있다.
+// 여러 고루틴에서 함께 넣고 찾아도 된다.
+//
+// 같은 ID 로 벡터가 다른 문서를 다시 넣거나 지우면 예전 노드는 검색 결과에서만 빠지고
+// 그래프에는 남는다. 많이 바뀌었으면 새로 만드는 편이 낫다.
+type HNSW struct {
+	cfg HNSWConfig
+	ml  float64 // 층 분포의 정규화 계수 1/ln(M)
+
+	mu       sync.RWMutex
+	dim      int
+	nodes    []*hnswNode
+	byID     map[string]int
+	entry    int // 맨 위층의 진입점, 비어 있으면 -1
+	maxLevel int
+	rng      *rand.Rand
+	visited  sync.Pool
+}
+
+type hnswNode struct {
+	doc     Document
+	vec     []float32 // 길이 1 로 정규화한 벡터
+	links   [][]int32 // 층마다 이웃
+	deleted bool
+}
+
+// NewHNSW 는 빈 HNSW 색인을 만든다. 차원은 처음 넣는 문서로 정해진다.
+func NewHNSW(cfg HNSWConfig) *HNSW {
+	if cfg.M <= 1 {
+		cfg.M = DefaultM
+	}
+	if cfg.EfConstruction <= 0 {
+		cfg.EfConstruction = DefaultEfConstruction
+	}
+	if cfg.EfSearch <= 0 {
+		cfg.EfSearch = DefaultEfSearch
+	}
+	return &HNSW{
+		cfg:   cfg,
+		ml:    1 / math.Log(float64(cfg.M)),
+		byID:  make(map[string]int),
+		entry: -1,
+		rng:   rand.New(rand.NewPCG(cfg.Seed, 0x9e3779b97f4a7c15)),
+	}
+}
+
+// Len 은 저장된 문서 수 (바뀌어 빠진 예전 노드는 세지 않는다).
+func (h *HNSW) Len() int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return len(h.byID)
+}
+
+// Get 은 id 문서를 돌려준다.
+func (h *HNSW) Get(id string) (Document, bool) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	i, ok := h.byID[id]
+	if !ok {
+		return Document{}, false
+	}
+	return h.nodes[i].doc, true
+}
+
+// Count 는 VectorStore 를 구현한다.
+func (h *HNSW) Count(ctx context.Context) (int, error) {
+	return h.Len(), nil
+}
+
+// Upsert 는 문서들을 색인에 넣는다. 같은 ID 가 있으면 바꾼다.
+func (h *HNSW) Upsert(ctx context.Context, docs ...Document) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, d := range docs {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if len(d.Vector) == 0 {
+			return fmt.Errorf("%w: document %q has no vector", ErrDimensionMismatch, d.ID)
+		}
+		if h.dim == 0 {
+			h.dim = len(d.Vector)
+		}
+		if len(d.Vector) != h.dim {
+			return fmt.Errorf("%w: document %q has %d dimensions, store has %d", ErrDimensionMismatch, d.ID, len(d.Vector), h.dim)
+		}
+		if i, ok := h.byID[d.ID]; ok {
+			if slices.Equal(h.nodes[i].doc.Vector, d.Vector) {
+				// 벡터가 같으면 그래프는 그대로 두고 내용만 바꾼다
+				h.nodes[i].doc = d
+				continue
+			}
+			h.nodes[i].deleted = true
+		}
+		h.insert(d)
+	}
+	return nil
+}
+
+// Delete 는 ids 문서들을 지운다.
+func (h *HNSW) Delete(ctx context.Context, ids ...string) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, id := range ids {
+		if i, ok := h.byID[id]; ok {
+			h.nodes[i].deleted = true
+			delete(h.byID, id)
+		}
+	}
+	return nil
+}
+
+// Prune 은 keep 의 문서(DocID)마다 조각 ID 가 keep[DocID] 에 없는 조각을 지운다.
+func (h *HNSW) Prune(ctx context.Context, keep map[string][]string) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for id, i := range h.byID {
+		if stale(keep, h.nodes[i].doc.DocID, id) {
+			h.nodes[i].deleted = true
+			delete(h.byID, id)
+		}
+	}
+	return nil
+}
+
+// Search 는 query 와 가까운 문서를 유사도 내림차순으로 최대 K 개 돌려준다.
+// 결과는 근사값이며, EfSearch 를 늘리면 정확해진다. DocIDs 조건은 찾은 후보에서 거르므로
+// 조건에 맞는 문서가 드물면 K 개보다 적게 나올 수 있다.
+func (h *HNSW) Search(ctx context.Context, query []float32, opts SearchOptions) ([]Result, error) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	if h.dim != 0 && len(query) != h.dim {
+		return nil, fmt.Errorf("%w: query has %d dimensions, store has %d", ErrDimensionMismatch, len(query), h.dim)
+	}
+	if h.entry < 0 {
+		return nil, nil
+	}
+	k := opts.k()
+	q := normalize(query)
+	ep := h.entry
+	for l := h.maxLevel; l > 0; l-- {
+		ep = h.greedy(q, ep, l)
+	}
+	found := h.searchLayer(q, ep, max(h.cfg.EfSearch, k), 0)
+
+	var results []Result
+	for _, c := range found {
+		n := h.nodes[c.id]
+		score := 1 - c.dist
+		if n.deleted || score < opts.minScore() || !opts.match(n.doc) {
+			continue
+		}
+		results = append(results, result(n.doc, score))
+	}
+	slices.SortFunc(results, func(a, b Result) int {
+		if c := cmp.Compare(b.Score, a.Score); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.ID, b.ID)
+	})
+	if len(results) > k {
+		results = results[:k]
+	}
+	return results, nil
+}
+
+func (h *HNSW) maxLinks(level int) int {
+	if level == 0 {
+		return 2 * h.cfg.M
+	}
+	return h.cfg.M
+}
+
+// insert 는 새 노드를 그래프에 잇는다. h.mu 를 잡은 채로 부른다.
+func (h *HNSW) insert(d Document) {
+	level := int(-math.Log(1-h.rng.Float64()) * h.ml)
+	id := len(h.nodes)
+	n := &hnswNode{doc: d, vec: normalize(d.Vector), links: make([][]int32, level+1)}
+	h.nodes = append(h.nodes, n)
+	h.byID[d.ID] = id
+	if h.entry < 0 {
+		h.entry, h.maxLevel = id, level
+		return
+	}
+
+	ep := h.entry
+	for l := h.maxLevel; l > level; l-- {
+		ep = h.greedy(n.vec, ep, l)
+	}
+	for l := min(level, h.maxLevel); l >= 0; l-- {
+		found := h.searchLayer(n.vec, ep, h.cfg.EfConstruction, l)
+		n.links[l] = h.selectNeighbors(found, h.cfg.M)
+		for _, nb := range n.links[l] {
+			h.link(int(nb), id, l)
+		}
+		ep = found[0].id
+	}
+	if level > h.maxLevel {
+		h.entry, h.maxLevel = id, level
+	}
+}
+
+// link 는 from 에서 to 로 가는 이음을 더하고, 넘치면 이웃을 다시 고른다.
+func (h *HNSW) link(from, to, level int) {
+	n := h.nodes[from]
+	n.links[level] = append(n.links[level], int32(to))
+	if len(n.links[level]) <= h.maxLinks(level) {
+		return
+	}
+	cands := make([]candidate, len(n.links[level]))
+	for i, nb := range n.links[level] {
+		cands[i] = candidate{int(nb), h.dist(n.vec, h.nodes[nb].vec)}
+	}
+	slices.SortFunc(cands, compareCandidates)
+	n.links[level] = h.selectNeighbors(cands, h.maxLinks(level))
+}
+
+// selectNeighbors 는 가까운 순으로 정렬된 후보에서 이웃 m 개를 고른다 (논문의 휴리스틱).
+// 이미 고른 이웃보다 새 노드에 더 가까운 후보만 골라 여러 방향으로 이어지게 하고,
+// 모자라면 남은 가까운 후보로 채운다.
+func (h *HNSW) selectNeighbors(cands []candidate, m int) []int32 {
+	out := make([]int32, 0, m)
+	var pruned []int32
+	for _, c := range cands {
+		if len(out) == m {
+			break
+		}
+		keep := true
+		for _, s := range out {
+			if h.dist(h.nodes[c.id].vec, h.nodes[s].vec) < c.dist {
+				keep = false
+				break
+			}
+		}
+		if keep {
+			out = append(out, int32(c.id))
+		} else {
+			pruned = append(pruned, int32(c.id))
+		}
+	}
+	for _, p := range pruned {
+		if len(out) == m {
+			break
+		}
+		out = append(out, p)
+	}
+	return out
+}
+
+// greedy 는 level 층에서 q 에 더 가까운 이웃이 없을 때까지 옮겨 간다.
+func (h *HNSW) greedy(q []float32, ep, level int) int {
+	best := h.dist(q, h.nodes[ep].vec)
+	for changed := true; changed; {
+		changed = false
+		for _, nb := range h.nodes[ep].links[level] {
+			if d := h.dist(q, h.nodes[nb].vec); d < best {
+				ep, best, changed = int(nb), d, true
+			}
+		}
+	}
+	return ep
+}
+
+// searchLayer 는 level 층에서 ep 부터 q 에 가까운 노드를 최대 ef 개 찾아 가까운 순으로 돌려준다.
+func (h *HNSW) searchLayer(q []float32, ep, ef, level int) []candidate {
+	visited := h.visitedList()
+	defer h.visited.Put(visited)
+
+	start := candidate{ep, h.dist(q, h.nodes[ep].vec)}
+	visited.visit(ep)
+	frontier := candidateHeap{less: func(a, b candidate) bool { return a.dist < b.dist }}
+	results := candidateHeap{less: func(a, b candidate) bool { return a.dist > b.dist }}
+	frontier.push(start)
+	results.push(start)
+	for frontier.len() > 0 {
+		c := frontier.pop()
+		if c.dist > results.top().dist && results.len() >= ef {
+			break
+		}
+		for _, nb := range h.nodes[c.id].links[level] {
+			if !visited.visit(int(nb)) {
+				continue
+			}
+			d := h.dist(q, h.nodes[nb].vec)
+			if results.len() < ef || d < results.top().dist {
+				frontier.push(candidate{int(nb), d})
+				results.push(candidate{int(nb), d})
+				if results.len() > ef {
+					results.pop()
+				}
+			}
+		}
+	}
+	out := results.items
+	slices.SortFunc(out, compareCandidates)
+	return out
+}
+
+func (h *HNSW) dist(a, b []float32) float32 {
+	return 1 - dotFloat32(a, b)
+}
+
+// visitedList 는 노드 방문 표시. 세대 번호를 써서 매번 지우지 않고 다시 쓴다.
+type visitedList struct {
+	marks []uint32
+	gen   uint32
+}
+
+func (h *HNSW) visitedList() *visitedList {
+	v, _ := h.visited.Get().(*visitedList)
+	if v == nil {
+		v = &visitedList{}
+	}
+	if len(v.marks) < len(h.nodes) {
+		v.marks = make([]uint32, len(h.nodes)+len(h.nodes)/4)
+		v.gen = 0
+	}
+	v.gen++
+	if v.gen == 0 {
+		clear(v.marks)
+		v.gen = 1
+	}
+	return v
+}
+
+// visit 은 i 를 처음 방문하면 true.
+func (v *visitedList) visit(i int) bool {
+	if v.marks[i] == v.gen {
+		return false
+	}
+	v.marks[i] = v.gen
+	return true
+}
+
+type candidate struct {
+	id   int
+	dist float32
+}
+
+func compareCandidates(a, b candidate) int {
+	if c := cmp.Compare(a.dist, b.dist); c != 0 {
+		return c
+	}
+	return cmp.Compare(a.id, b.id)
+}
+
+// candidateHeap 은 less 가 참인 쪽이 위로 오는 이진 힙.
+type candidateHeap struct {
+	items []candidate
+	less  func(a, b candidate) bool
+}
+
+func (h *candidateHeap) len() int         { return len(h.items) }
+func (h *candidateHeap) top() candidate   { return h.items[0] }
+func (h *candidateHeap) push(c candidate) { h.items = append(h.items, c); h.up(len(h.items) - 1) }
+
+func (h *candidateHeap) pop() candidate {
+	top := h.items[0]
+	last := len(h.items) - 1
+	h.items[0] = h.items[last]
+	h.items = h.items[:last]
+	if last > 0 {
+		h.down(0)
+	}
+	return top
+}
+
+func (h *candidateHeap) up(i int) {
+	for i > 0 {
+		p := (i - 1) / 2
+		if !h.less(h.items[i], h.items[p]) {
+			return
+		}
+		h.items[i], h.items[p] = h.items[p], h.items[i]
+		i = p
+	}
+}
+
+func (h *candidateHeap) down(i int) {
+	for {
+		l, best := 2*i+1, i
+		if l < len(h.items) && h.less(h.items[l], h.items[best]) {
+			best = l
+		}
+		if r := l + 1; r < len(h.items) && h.less(h.items[r], h.items[best]) {
+			best = r
+		}
+		if best == i {
+			return
+		}
+		h.items[i], h.items[best] = h.items[best], h.items[i]
+		i = best
+	}
+}
+
+// normalize 는 길이 1 로 맞춘 복사본을 돌려준다. 영벡터는 그대로 둔다.
+func normalize(v []float32) []float32 {
+	var norm float64
+	for _, x := range v {
+		norm += float64(x) * float64(x)
+	}
+	out := make([]float32, len(v))
+	if norm == 0 {
+		return out
+	}
+	inv := float32(1 / math.Sqrt(norm))
+	for i, x := range v {
+		out[i] = x * inv
+	}
+	return out
+}
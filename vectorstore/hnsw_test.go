@@ -0,0 +1,303 @@
+package vectorstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"sync"
+	"testing"
+)
+
+// randomDocs 는 차원 dim 의 정규분포 벡터 n 개를 만든다.
+func randomDocs(n, dim int, seed uint64) []Document {
+	rng := rand.New(rand.NewPCG(seed, 1))
+	docs := make([]Document, n)
+	for i := range docs {
+		v := make([]float32, dim)
+		for j := range v {
+			v[j] = float32(rng.NormFloat64())
+		}
+		docs[i] = Document{ID: fmt.Sprintf("doc%06d", i), Content: fmt.Sprint(i), Vector: v}
+	}
+	return docs
+}
+
+// clusteredDocs 는 실제 임베딩처럼 주제별로 모인 벡터를 흉내 낸다. clusters 개의 중심 둘레에
+// 흩어진 n 개를 만든다. 고차원에서 완전히 무작위인 벡터는 서로 거리가 거의 같아
+// 어떤 근사 색인으로도 재현율이 낮으므로 벤치마크에는 이쪽을 쓴다.
+func clusteredDocs(n, dim, clusters int, seed uint64) []Document {
+	rng := rand.New(rand.NewPCG(seed, 2))
+	centers := randomDocs(clusters, dim, seed+1000)
+	docs := make([]Document, n)
+	for i := range docs {
+		c := centers[rng.IntN(clusters)].Vector
+		v := make([]float32, dim)
+		for j := range v {
+			v[j] = c[j] + 0.5*float32(rng.NormFloat64())
+		}
+		docs[i] = Document{ID: fmt.Sprintf("doc%06d", i), Content: fmt.Sprint(i), Vector: v}
+	}
+	return docs
+}
+
+// recall 은 정답(got 과 같은 K) 중 근사 결과에 들어 있는 비율.
+func recall(exact, approx []Result) float64 {
+	if len(exact) == 0 {
+		return 1
+	}
+	found := make(map[string]bool, len(approx))
+	for _, r := range approx {
+		found[r.ID] = true
+	}
+	hits := 0
+	for _, r := range exact {
+		if found[r.ID] {
+			hits++
+		}
+	}
+	return float64(hits) / float64(len(exact))
+}
+
+// meanRecall 은 queries 마다 brute 와 h 의 상위 k 개를 비교한 평균 재현율.
+func meanRecall(t testing.TB, brute *Memory, h *HNSW, queries []Document, k int) float64 {
+	ctx := context.Background()
+	opts := SearchOptions{K: k}
+	var sum float64
+	for _, q := range queries {
+		exact, err := brute.Search(ctx, q.Vector, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		approx, err := h.Search(ctx, q.Vector, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sum += recall(exact, approx)
+	}
+	return sum / float64(len(queries))
+}
+
+func TestHNSWRecall(t *testing.T) {
+	ctx := context.Background()
+	docs := randomDocs(3000, 32, 1)
+	queries := randomDocs(100, 32, 2)
+
+	brute := NewMemory()
+	h := NewHNSW(HNSWConfig{Seed: 1})
+	if err := brute.Upsert(ctx, docs...); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.Upsert(ctx, docs...); err != nil {
+		t.Fatal(err)
+	}
+	if h.Len() != len(docs) {
+		t.Errorf("Len() = %d, want %d", h.Len(), len(docs))
+	}
+	if r := meanRecall(t, brute, h, queries, 10); r < 0.95 {
+		t.Errorf("recall@10 = %.3f, want >= 0.95", r)
+	}
+
+	// 저장된 문서 자신으로 찾으면 항상 첫 번째로 나온다
+	for _, d := range docs[:50] {
+		res, err := h.Search(ctx, d.Vector, SearchOptions{K: 1})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(res) != 1 || res[0].ID != d.ID {
+			t.Errorf("Search(%s) = %v", d.ID, res)
+		}
+	}
+}
+
+func TestHNSWSearchOptions(t *testing.T) {
+	ctx := context.Background()
+	h := NewHNSW(HNSWConfig{})
+	if res, err := h.Search(ctx, []float32{1, 0}, SearchOptions{}); err != nil || res != nil {
+		t.Errorf("Search() on empty = %v, %v", res, err)
+	}
+	err := h.Upsert(ctx,
+		Document{ID: "a", Content: "A", Vector: []float32{1, 0, 0}},
+		Document{ID: "b", Content: "B", Vector: []float32{0.8, 0.6, 0}},
+		Document{ID: "c", Content: "C", Vector: []float32{0, 1, 0}},
+		Document{ID: "d", Content: "D", Vector: []float32{0, 0, 1}, DocID: "y"},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := h.Search(ctx, []float32{1, 0, 0}, SearchOptions{K: 10, MinScore: Threshold(0.5)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res) != 2 || res[0].ID != "a" || res[1].ID != "b" {
+		t.Errorf("Search() = %v, want a, b", res)
+	}
+
+	// 같은 ID 로 바꾸면 예전 벡터로는 찾히지 않는다
+	if err := h.Upsert(ctx, Document{ID: "a", Content: "A2", Vector: []float32{0, 0, -1}}); err != nil {
+		t.Fatal(err)
+	}
+	res, _ = h.Search(ctx, []float32{1, 0, 0}, SearchOptions{K: 1})
+	if len(res) != 1 || res[0].ID != "b" {
+		t.Errorf("Search() after replace = %v, want b", res)
+	}
+	if d, _ := h.Get("a"); d.Content != "A2" || h.Len() != 4 {
+		t.Errorf("Get(a) = %+v, Len() = %d", d, h.Len())
+	}
+
+	// 지운 문서는 찾히지 않는다
+	if err := h.Delete(ctx, "a", "b", "missing"); err != nil {
+		t.Fatal(err)
+	}
+	res, _ = h.Search(ctx, []float32{1, 0, 0}, SearchOptions{K: 1})
+	if n, _ := h.Count(ctx); len(res) != 1 || res[0].ID != "c" || n != 2 {
+		t.Errorf("Search() after delete = %v, Count() = %d, want c and 2", res, n)
+	}
+	res, _ = h.Search(ctx, []float32{1, 0, 0}, SearchOptions{K: 10, DocIDs: []string{"y"}})
+	if len(res) != 1 || res[0].ID != "d" {
+		t.Errorf("Search() with DocIDs = %v, want d", res)
+	}
+
+	if _, err := h.Search(ctx, []float32{1, 0}, SearchOptions{}); !errors.Is(err, ErrDimensionMismatch) {
+		t.Errorf("Search() with wrong dimension error = %v", err)
+	}
+	if err := h.Upsert(ctx, Document{ID: "e", Vector: []float32{1}}); !errors.Is(err, ErrDimensionMismatch) {
+		t.Errorf("Upsert() with wrong dimension error = %v", err)
+	}
+}
+
+// TestHNSWConcurrent 는 -race 로 돌릴 때 넣기와 찾기가 함께 일어나도 안전한지 확인한다.
+func TestHNSWConcurrent(t *testing.T) {
+	ctx := context.Background()
+	docs := randomDocs(800, 16, 3)
+	h := NewHNSW(HNSWConfig{M: 8, EfConstruction: 50})
+
+	var wg sync.WaitGroup
+	for w := range 4 {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for i := w; i < len(docs); i += 4 {
+				if err := h.Upsert(ctx, docs[i]); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for i := range 100 {
+				if _, err := h.Search(ctx, docs[i].Vector, SearchOptions{K: 5}); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	if h.Len() != len(docs) {
+		t.Errorf("Len() = %d, want %d", h.Len(), len(docs))
+	}
+}
+
+// 벤치마크 말뭉치. 색인 만들기는 느리므로 설정마다 한 번만 만든다.
+const (
+	benchDocs    = 20000
+	benchDim     = 256
+	benchQueries = 200
+	benchK       = 10
+	// 주제 수. 실제 말뭉치처럼 비슷한 조각들이 모이게 한다
+	benchClusters = 200
+)
+
+var (
+	benchOnce  sync.Once
+	benchBrute *Memory
+	benchData  []Document
+	benchQuery []Document
+	benchIndex = map[HNSWConfig]*HNSW{}
+	benchMu    sync.Mutex
+)
+
+func benchSetup(b *testing.B) {
+	benchOnce.Do(func() {
+		// 질의도 같은 중심들 둘레에서 뽑는다 (같은 seed 의 중심)
+		all := clusteredDocs(benchDocs+benchQueries, benchDim, benchClusters, 10)
+		benchData, benchQuery = all[:benchDocs], all[benchDocs:]
+		benchBrute = NewMemory()
+		if err := benchBrute.Upsert(context.Background(), benchData...); err != nil {
+			b.Fatal(err)
+		}
+	})
+}
+
+func benchHNSW(b *testing.B, cfg HNSWConfig) *HNSW {
+	benchSetup(b)
+	benchMu.Lock()
+	defer benchMu.Unlock()
+	if h, ok := benchIndex[cfg]; ok {
+		return h
+	}
+	h := NewHNSW(cfg)
+	if err := h.Upsert(context.Background(), benchData...); err != nil {
+		b.Fatal(err)
+	}
+	benchIndex[cfg] = h
+	return h
+}
+
+// BenchmarkSearch 는 전부 비교하는 검색과 설정별 HNSW 의 검색 시간과 재현율(recall@10)을 비교한다.
+//
+//	go test ./vectorstore -run '^$' -bench Search
+func BenchmarkSearch(b *testing.B) {
+	ctx := context.Background()
+	opts := SearchOptions{K: benchK}
+
+	b.Run("brute", func(b *testing.B) {
+		benchSetup(b)
+		b.ResetTimer()
+		for i := range b.N {
+			if _, err := benchBrute.Search(ctx, benchQuery[i%benchQueries].Vector, opts); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	for _, m := range []int{8, 16, 32} {
+		for _, ef := range []int{16, 64, 256} {
+			cfg := HNSWConfig{M: m, EfConstruction: DefaultEfConstruction, EfSearch: ef, Seed: 1}
+			b.Run(fmt.Sprintf("hnsw/M=%d/ef=%d", m, ef), func(b *testing.B) {
+				// 색인은 EfSearch 와 상관없이 만들어지므로 EfSearch 만 바꿔 재사용한다
+				built := cfg
+				built.EfSearch = DefaultEfSearch
+				h := benchHNSW(b, built)
+				h.mu.Lock()
+				h.cfg.EfSearch = ef
+				h.mu.Unlock()
+
+				b.ResetTimer()
+				for i := range b.N {
+					if _, err := h.Search(ctx, benchQuery[i%benchQueries].Vector, opts); err != nil {
+						b.Fatal(err)
+					}
+				}
+				// ResetTimer 가 지우지 않도록 시간을 잰 뒤에 기록한다
+				b.StopTimer()
+				b.ReportMetric(meanRecall(b, benchBrute, h, benchQuery, benchK), "recall@10")
+			})
+		}
+	}
+}
+
+// BenchmarkHNSWUpsert 는 문서 하나를 넣는 시간을 잰다.
+func BenchmarkHNSWUpsert(b *testing.B) {
+	docs := clusteredDocs(b.N, benchDim, benchClusters, 12)
+	h := NewHNSW(HNSWConfig{Seed: 1})
+	b.ResetTimer()
+	for i := range b.N {
+		if err := h.Upsert(context.Background(), docs[i]); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
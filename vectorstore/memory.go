@@ -0,0 +1,399 @@
+package vectorstore
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"math"
+	"runtime"
+	"slices"
+	"sync"
+)
+
+// minParallel 은 고루틴 하나가 맡을 최소 문서 수. 이보다 적으면 나눠 봐야 느려진다.
+const minParallel = 4096
+
+// MemoryConfig 는 Memory 의 검색 설정. 0 인 값은 기본값을 쓴다.
+type MemoryConfig struct {
+	// Workers 는 검색에 쓸 최대 고루틴 수. 기본은 GOMAXPROCS.
+	Workers int
+	// Quantize 면 정규화한 벡터를 float32 대신 int8 로만 두어 벡터 메모리를 1/4 로 줄인다.
+	// 유사도는 int8 로 셈한 근사값이라 드물게 순위가 조금 달라지고, Get, Documents, Save 의
+	// 벡터도 근사값이다. 순수 Go 에서는 int8 과 float32 의 비교 속도가 비슷하므로 빨라지지는 않는다.
+	Quantize bool
+}
+
+// Memory 는 문서를 메모리에 두고 전부 비교해 찾는 저장소. 여러 고루틴에서 함께 써도 된다.
+//
+// 벡터는 넣을 때 길이 1 로 정규화한 사본 하나만 두므로 코사인 유사도가 내적 한 번이 되고,
+// 문서가 많으면 여러 고루틴이 나눠 비교한 뒤 상위 K 개를 합친다. Get 등이 돌려주는 벡터는
+// 이 사본에서 다시 만든 것이라 넣은 벡터가 아니라 정규화한 벡터이다.
+type Memory struct {
+	cfg MemoryConfig
+
+	mu   sync.RWMutex
+	dim  int
+	docs []Document // Vector 는 비워 둔다
+	byID map[string]int
+	// vecs 는 정규화한 벡터들을 이어 붙인 것 (i 번째 문서는 vecs[i*dim:(i+1)*dim]).
+	// Quantize 면 대신 qvecs 와 문서마다의 배율 scales 를 쓴다.
+	vecs   []float32
+	qvecs  []int8
+	scales []float32
+}
+
+// NewMemory 는 기본 설정의 빈 Memory 를 만든다. 차원은 처음 넣는 문서로 정해진다.
+func NewMemory() *Memory {
+	return NewMemoryWith(MemoryConfig{})
+}
+
+// NewMemoryWith 는 cfg 설정의 빈 Memory 를 만든다.
+func NewMemoryWith(cfg MemoryConfig) *Memory {
+	if cfg.Workers <= 0 {
+		cfg.Workers = runtime.GOMAXPROCS(0)
+	}
+	return &Memory{cfg: cfg, byID: make(map[string]int)}
+}
+
+// Len 은 저장된 문서 수.
+func (m *Memory) Len() int {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return len(m.docs)
+}
+
+// Get 은 id 문서를 돌려준다.
+func (m *Memory) Get(id string) (Document, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	i, ok := m.byID[id]
+	if !ok {
+		return Document{}, false
+	}
+	return m.document(i), true
+}
+
+// Documents 는 저장된 문서 전부를 넣은 순서대로 돌려준다 (지우면 순서가 바뀐다).
+func (m *Memory) Documents() []Document {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	docs := make([]Document, len(m.docs))
+	for i := range docs {
+		docs[i] = m.document(i)
+	}
+	return docs
+}
+
+// document 는 i 번째 문서에 정규화한 벡터를 다시 채워 돌려준다. m.mu 를 잡은 채로 부른다.
+func (m *Memory) document(i int) Document {
+	d := m.docs[i]
+	d.Vector = make([]float32, m.dim)
+	if m.cfg.Quantize {
+		for j, x := range m.qvecs[i*m.dim : (i+1)*m.dim] {
+			d.Vector[j] = float32(x) * m.scales[i]
+		}
+	} else {
+		copy(d.Vector, m.vecs[i*m.dim:])
+	}
+	return d
+}
+
+// Count 는 VectorStore 를 구현한다.
+func (m *Memory) Count(ctx context.Context) (int, error) {
+	return m.Len(), nil
+}
+
+// Upsert 는 문서들을 넣는다. 같은 ID 가 있으면 바꾼다.
+func (m *Memory) Upsert(ctx context.Context, docs ...Document) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, d := range docs {
+		if err := m.add(d); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// add 는 문서 하나를 넣는다. m.mu 를 잡은 채로 부른다.
+func (m *Memory) add(d Document) error {
+	if len(d.Vector) == 0 {
+		return fmt.Errorf("%w: document %q has no vector", ErrDimensionMismatch, d.ID)
+	}
+	if m.dim == 0 {
+		m.dim = len(d.Vector)
+	}
+	if len(d.Vector) != m.dim {
+		return fmt.Errorf("%w: document %q has %d dimensions, store has %d", ErrDimensionMismatch, d.ID, len(d.Vector), m.dim)
+	}
+	i, ok := m.byID[d.ID]
+	if !ok {
+		i = len(m.docs)
+		m.byID[d.ID] = i
+		m.docs = append(m.docs, Document{})
+		if m.cfg.Quantize {
+			m.qvecs = append(m.qvecs, make([]int8, m.dim)...)
+			m.scales = append(m.scales, 0)
+		} else {
+			m.vecs = append(m.vecs, make([]float32, m.dim)...)
+		}
+	}
+	v := normalize(d.Vector)
+	d.Vector = nil
+	m.docs[i] = d
+	if m.cfg.Quantize {
+		m.scales[i] = quantize(m.qvecs[i*m.dim:(i+1)*m.dim], v)
+	} else {
+		copy(m.vecs[i*m.dim:], v)
+	}
+	return nil
+}
+
+// Delete 는 ids 문서들을 지운다. 마지막 문서를 빈자리로 옮겨 벡터 배열을 이어 붙인 채로 둔다.
+func (m *Memory) Delete(ctx context.Context, ids ...string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, id := range ids {
+		i, ok := m.byID[id]
+		if !ok {
+			continue
+		}
+		last := len(m.docs) - 1
+		if i != last {
+			m.docs[i] = m.docs[last]
+			m.byID[m.docs[i].ID] = i
+			if m.cfg.Quantize {
+				copy(m.qvecs[i*m.dim:(i+1)*m.dim], m.qvecs[last*m.dim:])
+				m.scales[i] = m.scales[last]
+			} else {
+				copy(m.vecs[i*m.dim:(i+1)*m.dim], m.vecs[last*m.dim:])
+			}
+		}
+		delete(m.byID, id)
+		m.docs = m.docs[:last]
+		if m.cfg.Quantize {
+			m.qvecs = m.qvecs[:last*m.dim]
+			m.scales = m.scales[:last]
+		} else {
+			m.vecs = m.vecs[:last*m.dim]
+		}
+	}
+	return nil
+}
+
+// Prune 은 keep 의 문서(DocID)마다 조각 ID 가 keep[DocID] 에 없는 조각을 지운다.
+// 다시 나눈 문서가 짧아졌을 때 예전 조각을 지우는 데 쓴다. keep 에 없는 문서는 그대로 둔다.
+func (m *Memory) Prune(ctx context.Context, keep map[string][]string) error {
+	m.mu.RLock()
+	var ids []string
+	for _, d := range m.docs {
+		if stale(keep, d.DocID, d.ID) {
+			ids = append(ids, d.ID)
+		}
+	}
+	m.mu.RUnlock()
+	return m.Delete(ctx, ids...)
+}
+
+// Search 는 query 와 가장 가까운 문서를 유사도 내림차순으로 최대 K 개 돌려준다.
+// 유사도가 같으면 ID 순서를 따르므로 결과는 항상 같다.
+func (m *Memory) Search(ctx context.Context, query []float32, opts SearchOptions) ([]Result, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if m.dim != 0 && len(query) != m.dim {
+		return nil, fmt.Errorf("%w: query has %d dimensions, store has %d", ErrDimensionMismatch, len(query), m.dim)
+	}
+	if len(m.docs) == 0 {
+		return nil, nil
+	}
+
+	k := opts.k()
+	q := normalize(query)
+	var keep func(i int) bool
+	if len(opts.DocIDs) > 0 {
+		keep = func(i int) bool { return opts.match(m.docs[i]) }
+	}
+	var top []scored
+	if m.cfg.Quantize {
+		qq := make([]int8, m.dim)
+		qs := quantize(qq, q)
+		top = m.scan(k, keep, func(i int) float32 {
+			return qs * m.scales[i] * dotInt8(qq, m.qvecs[i*m.dim:(i+1)*m.dim])
+		}, opts.minScore())
+	} else {
+		top = m.scan(k, keep, func(i int) float32 {
+			return dotFloat32(q, m.vecs[i*m.dim:(i+1)*m.dim])
+		}, opts.minScore())
+	}
+
+	slices.SortFunc(top, m.compare)
+	if len(top) > k {
+		top = top[:k]
+	}
+	results := make([]Result, len(top))
+	for j, s := range top {
+		results[j] = result(m.docs[s.i], s.score)
+	}
+	return results, nil
+}
+
+// scored 는 문서 번호와 유사도.
+type scored struct {
+	i     int
+	score float32
+}
+
+// compare 는 유사도 내림차순, 같으면 ID 오름차순.
+func (m *Memory) compare(a, b scored) int {
+	if c := cmp.Compare(b.score, a.score); c != 0 {
+		return c
+	}
+	return cmp.Compare(m.docs[a.i].ID, m.docs[b.i].ID)
+}
+
+// scan 은 keep 을 통과한 문서(nil 이면 전부)를 score 로 매겨 minScore 이상 중 상위 k 개를
+// 돌려준다 (순서 없음). 문서가 많으면 구간을 나눠 고루틴마다 상위 k 개를 구한 뒤 합친다.
+func (m *Memory) scan(k int, keep func(i int) bool, score func(i int) float32, minScore float32) []scored {
+	n := len(m.docs)
+	workers := min(m.cfg.Workers, max(1, n/minParallel))
+	if workers == 1 {
+		return m.scanRange(0, n, k, keep, score, minScore)
+	}
+
+	parts := make([][]scored, workers)
+	var wg sync.WaitGroup
+	for w := range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			parts[w] = m.scanRange(w*n/workers, (w+1)*n/workers, k, keep, score, minScore)
+		}()
+	}
+	wg.Wait()
+
+	h := topK{k: k, compare: m.compare}
+	for _, p := range parts {
+		for _, s := range p {
+			h.offer(s)
+		}
+	}
+	return h.items
+}
+
+func (m *Memory) scanRange(lo, hi, k int, keep func(i int) bool, score func(i int) float32, minScore float32) []scored {
+	h := topK{k: k, compare: m.compare}
+	for i := lo; i < hi; i++ {
+		if keep != nil && !keep(i) {
+			continue
+		}
+		if s := score(i); s >= minScore {
+			h.offer(scored{i, s})
+		}
+	}
+	return h.items
+}
+
+// topK 는 가장 좋은 k 개만 남기는 힙. 맨 위(items[0])가 남은 것 중 가장 나쁜 것이다.
+type topK struct {
+	k       int
+	items   []scored
+	compare func(a, b scored) int
+}
+
+func (h *topK) offer(s scored) {
+	if len(h.items) < h.k {
+		h.items = append(h.items, s)
+		h.up(len(h.items) - 1)
+		return
+	}
+	// 가장 나쁜 것보다 좋을 때만 바꾼다
+	if h.compare(s, h.items[0]) >= 0 {
+		return
+	}
+	h.items[0] = s
+	h.down(0)
+}
+
+// worse 는 a 가 b 보다 나쁜지 (힙에서 위로 올라갈지).
+func (h *topK) worse(a, b scored) bool {
+	return h.compare(a, b) > 0
+}
+
+func (h *topK) up(i int) {
+	for i > 0 {
+		p := (i - 1) / 2
+		if !h.worse(h.items[i], h.items[p]) {
+			return
+		}
+		h.items[i], h.items[p] = h.items[p], h.items[i]
+		i = p
+	}
+}
+
+func (h *topK) down(i int) {
+	for {
+		l, worst := 2*i+1, i
+		if l < len(h.items) && h.worse(h.items[l], h.items[worst]) {
+			worst = l
+		}
+		if r := l + 1; r < len(h.items) && h.worse(h.items[r], h.items[worst]) {
+			worst = r
+		}
+		if worst == i {
+			return
+		}
+		h.items[i], h.items[worst] = h.items[worst], h.items[i]
+		i = worst
+	}
+}
+
+// dotFloat32 는 내적. 네 개씩 풀어 더해 파이프라인을 채운다.
+func dotFloat32(a, b []float32) float32 {
+	b = b[:len(a)]
+	var s0, s1, s2, s3 float32
+	i := 0
+	for ; i+4 <= len(a); i += 4 {
+		s0 += a[i] * b[i]
+		s1 += a[i+1] * b[i+1]
+		s2 += a[i+2] * b[i+2]
+		s3 += a[i+3] * b[i+3]
+	}
+	for ; i < len(a); i++ {
+		s0 += a[i] * b[i]
+	}
+	return s0 + s1 + s2 + s3
+}
+
+// dotInt8 는 int8 벡터의 내적.
+func dotInt8(a, b []int8) float32 {
+	b = b[:len(a)]
+	var s0, s1, s2, s3 int32
+	i := 0
+	for ; i+4 <= len(a); i += 4 {
+		s0 += int32(a[i]) * int32(b[i])
+		s1 += int32(a[i+1]) * int32(b[i+1])
+		s2 += int32(a[i+2]) * int32(b[i+2])
+		s3 += int32(a[i+3]) * int32(b[i+3])
+	}
+	for ; i < len(a); i++ {
+		s0 += int32(a[i]) * int32(b[i])
+	}
+	return float32(s0 + s1 + s2 + s3)
+}
+
+// quantize 는 v 를 절댓값 최대가 127 이 되게 dst 에 양자화하고, 되돌리는 배율을 돌려준다.
+func quantize(dst []int8, v []float32) float32 {
+	var maxAbs float32
+	for _, x := range v {
+		maxAbs = max(maxAbs, float32(math.Abs(float64(x))))
+	}
+	if maxAbs == 0 {
+		clear(dst)
+		return 0
+	}
+	scale := maxAbs / 127
+	for i, x := range v {
+		dst[i] = int8(math.Round(float64(x / scale)))
+	}
+	return scale
+}
@@ -0,0 +1,179 @@
+package vectorstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestMemorySearch(t *testing.T) {
+	for _, cfg := range []MemoryConfig{{}, {Quantize: true}} {
+		t.Run(fmt.Sprintf("quantize=%v", cfg.Quantize), func(t *testing.T) {
+			testMemorySearch(t, NewMemoryWith(cfg))
+		})
+	}
+}
+
+func testMemorySearch(t *testing.T, m *Memory) {
+	ctx := context.Background()
+	err := m.Upsert(ctx,
+		Document{ID: "a", Content: "A", Vector: []float32{1, 0, 0}, DocID: "x"},
+		Document{ID: "b", Content: "B", Vector: []float32{0.8, 0.6, 0}, DocID: "y"},
+		Document{ID: "c", Content: "C", Vector: []float32{0, 1, 0}, DocID: "y"},
+		Document{ID: "d", Content: "D", Vector: []float32{0, 0, 1}},
+		Document{ID: "a2", Content: "A again", Vector: []float32{2, 0, 0}, DocID: "x"},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		query []float32
+		opts  SearchOptions
+		want  []string
+	}{
+		{"top 2", []float32{1, 0, 0}, SearchOptions{K: 2}, []string{"a", "a2"}},
+		{"default k", []float32{1, 0, 0}, SearchOptions{}, []string{"a", "a2", "b"}},
+		{"threshold", []float32{1, 0, 0}, SearchOptions{K: 10, MinScore: Threshold(0.5)}, []string{"a", "a2", "b"}},
+		{"no relevant context", []float32{0, 0, -1}, SearchOptions{K: 3, MinScore: Threshold(0.1)}, nil},
+		// 임계값이 없으면 반대 방향(음수 유사도)의 문서도 가까운 순서로 돌려준다
+		{"negative scores", []float32{0, 0, -1}, SearchOptions{K: 5}, []string{"a", "a2", "b", "c", "d"}},
+		{"doc filter", []float32{1, 0, 0}, SearchOptions{K: 10, DocIDs: []string{"y"}}, []string{"b", "c"}},
+		{"unknown doc", []float32{1, 0, 0}, SearchOptions{DocIDs: []string{"z"}}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := m.Search(ctx, tt.query, tt.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("Search() = %v, want IDs %v", got, tt.want)
+			}
+			for i, r := range got {
+				if r.ID != tt.want[i] {
+					t.Errorf("result %d = %q, want %q", i, r.ID, tt.want[i])
+				}
+				if i > 0 && r.Score > got[i-1].Score {
+					t.Errorf("results not sorted by score: %v", got)
+				}
+			}
+		})
+	}
+
+	// 같은 ID 는 바꾼다
+	if err := m.Upsert(ctx, Document{ID: "d", Content: "D'", Vector: []float32{1, 0, 0}}); err != nil || m.Len() != 5 {
+		t.Errorf("replace: err=%v len=%d", err, m.Len())
+	}
+	if _, err := m.Search(ctx, []float32{1, 0}, SearchOptions{}); !errors.Is(err, ErrDimensionMismatch) {
+		t.Errorf("Search() with wrong dimension error = %v", err)
+	}
+	if err := m.Upsert(ctx, Document{ID: "e", Vector: []float32{1}}); !errors.Is(err, ErrDimensionMismatch) {
+		t.Errorf("Upsert() with wrong dimension error = %v", err)
+	}
+
+	// 지운 문서는 찾히지 않고, 남은 문서는 그대로 찾힌다 (없는 ID 는 무시)
+	if err := m.Delete(ctx, "a", "d", "missing"); err != nil {
+		t.Fatal(err)
+	}
+	if n, _ := m.Count(ctx); n != 3 {
+		t.Errorf("Count() after delete = %d, want 3", n)
+	}
+	got, err := m.Search(ctx, []float32{1, 0, 0}, SearchOptions{K: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []string
+	for _, r := range got {
+		ids = append(ids, r.ID)
+	}
+	if want := []string{"a2", "b", "c"}; !reflect.DeepEqual(ids, want) {
+		t.Errorf("Search() after delete = %v, want %v", ids, want)
+	}
+	if d, ok := m.Get("c"); !ok || d.Content != "C" {
+		t.Errorf("Get(c) after delete = %+v, %v", d, ok)
+	}
+}
+
+// TestMemoryParallel 은 여러 고루틴으로 나눠 찾아도 한 고루틴과 결과가 같은지 확인한다.
+func TestMemoryParallel(t *testing.T) {
+	ctx := context.Background()
+	docs := randomDocs(5*minParallel, 16, 4)
+	serial := NewMemoryWith(MemoryConfig{Workers: 1})
+	parallel := NewMemoryWith(MemoryConfig{Workers: 4})
+	for _, m := range []*Memory{serial, parallel} {
+		if err := m.Upsert(ctx, docs...); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, q := range randomDocs(20, 16, 5) {
+		for _, opts := range []SearchOptions{{K: 10}, {K: 50, MinScore: Threshold(0.5)}} {
+			want, err := serial.Search(ctx, q.Vector, opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := parallel.Search(ctx, q.Vector, opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Fatalf("parallel Search(%+v) = %v, want %v", opts, got, want)
+			}
+		}
+	}
+}
+
+// TestMemoryQuantize 는 int8 양자화 검색이 정확한 검색과 거의 같은 결과와 점수를 내는지 확인한다.
+func TestMemoryQuantize(t *testing.T) {
+	ctx := context.Background()
+	all := clusteredDocs(5000, 64, 50, 6)
+	docs, queries := all[:4900], all[4900:]
+	exact := NewMemory()
+	quant := NewMemoryWith(MemoryConfig{Quantize: true})
+	for _, m := range []*Memory{exact, quant} {
+		if err := m.Upsert(ctx, docs...); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var sum float64
+	opts := SearchOptions{K: 10}
+	for _, q := range queries {
+		want, _ := exact.Search(ctx, q.Vector, opts)
+		got, err := quant.Search(ctx, q.Vector, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sum += recall(want, got)
+		// 근사 점수는 정확한 점수와 조금만 다르다
+		for _, r := range got {
+			d, _ := exact.Get(r.ID)
+			if e := dotFloat32(normalize(q.Vector), d.Vector); math.Abs(float64(r.Score-e)) > 0.01 {
+				t.Fatalf("score of %s = %v, want about %v", r.ID, r.Score, e)
+			}
+		}
+	}
+	if r := sum / float64(len(queries)); r < 0.95 {
+		t.Errorf("quantized recall@10 = %.3f, want >= 0.95", r)
+	}
+}
+
+// TestEmptyVector 는 빈 벡터가 저장소의 차원을 0 으로 정해 버리지 않는지 확인한다.
+func TestEmptyVector(t *testing.T) {
+	ctx := context.Background()
+	for name, s := range map[string]VectorStore{"memory": NewMemory(), "hnsw": NewHNSW(HNSWConfig{})} {
+		if err := s.Upsert(ctx, Document{ID: "a"}); !errors.Is(err, ErrDimensionMismatch) {
+			t.Errorf("%s: Upsert() without vector error = %v", name, err)
+		}
+		if err := s.Upsert(ctx, Document{ID: "b", Vector: []float32{1, 0, 0}}); err != nil {
+			t.Fatalf("%s: Upsert() = %v", name, err)
+		}
+		if res, err := s.Search(ctx, []float32{1, 0, 0}, SearchOptions{}); err != nil || len(res) != 1 || res[0].ID != "b" {
+			t.Errorf("%s: Search() = %v, %v, want b", name, res, err)
+		}
+	}
+}
@@ -0,0 +1,192 @@
+package vectorstore
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/pgvector/pgvector-go"
+	pgxvec "github.com/pgvector/pgvector-go/pgx"
+)
+
+// DefaultPGTable 은 PGVector 가 쓰는 기본 테이블 이름.
+const DefaultPGTable = "documents"
+
+// EnvDSN 은 PostgreSQL 연결 문자열을 담는 환경 변수. rag 와 rag_ingest 의 -dsn 기본값이다.
+const EnvDSN = "DATABASE_URL"
+
+// PGVector 는 문서를 PostgreSQL 의 pgvector 열에 두고 데이터베이스에서 찾는 저장소.
+// 풀은 ConnectPG 로 만들어 vector 타입이 등록된 것이어야 한다.
+type PGVector struct {
+	DB    *pgxpool.Pool
+	Table string
+	// Dimensionality 는 embedding 열의 차원.
+	Dimensionality int
+}
+
+// ConnectPG 는 vector 확장을 만들고, 연결마다 pgvector 타입을 등록하는 풀을 연다.
+func ConnectPG(ctx context.Context, connString string) (*pgxpool.Pool, error) {
+	// 확장이 없으면 타입을 등록할 수 없으므로 풀을 열기 전에 한 연결로 먼저 만든다
+	conn, err := pgx.Connect(ctx, connString)
+	if err != nil {
+		return nil, fmt.Errorf("vectorstore: connect: %w", err)
+	}
+	_, err = conn.Exec(ctx, "CREATE EXTENSION IF NOT EXISTS vector")
+	conn.Close(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("vectorstore: create extension: %w", err)
+	}
+
+	config, err := pgxpool.ParseConfig(connString)
+	if err != nil {
+		return nil, fmt.Errorf("vectorstore: %w", err)
+	}
+	config.AfterConnect = pgxvec.RegisterTypes
+	pool, err := pgxpool.NewWithConfig(ctx, config)
+	if err != nil {
+		return nil, fmt.Errorf("vectorstore: connect: %w", err)
+	}
+	return pool, nil
+}
+
+// NewPGVector 는 table 이 없으면 dim 차원으로 만들고 PGVector 를 돌려준다.
+// table 이 비어 있으면 DefaultPGTable 을 쓴다.
+func NewPGVector(ctx context.Context, db *pgxpool.Pool, table string, dim int) (*PGVector, error) {
+	if table == "" {
+		table = DefaultPGTable
+	}
+	s := &PGVector{DB: db, Table: table, Dimensionality: dim}
+	name := s.table()
+	for _, q := range []string{
+		// id 는 "문서ID#번호", doc_id 와 오프셋은 원문 위치
+		fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+			id TEXT PRIMARY KEY,
+			content TEXT,
+			embedding VECTOR(%d)
+		)`, name, dim),
+		// 조각 단위 저장 이전에 만든 테이블에는 열이 없으므로 추가한다
+		fmt.Sprintf(`ALTER TABLE %s
+			ADD COLUMN IF NOT EXISTS doc_id TEXT,
+			ADD COLUMN IF NOT EXISTS start_offset INTEGER,
+			ADD COLUMN IF NOT EXISTS end_offset INTEGER`, name),
+		fmt.Sprintf("CREATE INDEX IF NOT EXISTS %s ON %s (doc_id)", pgx.Identifier{table + "_doc_id"}.Sanitize(), name),
+	} {
+		if _, err := db.Exec(ctx, q); err != nil {
+			return nil, fmt.Errorf("vectorstore: create table: %w", err)
+		}
+	}
+	return s, nil
+}
+
+func (s *PGVector) table() string {
+	return pgx.Identifier{s.Table}.Sanitize()
+}
+
+func (s *PGVector) check(what string, v []float32) error {
+	if len(v) != s.Dimensionality {
+		return fmt.Errorf("%w: %s has %d dimensions, store has %d", ErrDimensionMismatch, what, len(v), s.Dimensionality)
+	}
+	return nil
+}
+
+// Upsert 는 문서들을 한 번에 넣는다. 같은 ID 가 있으면 바꾼다.
+func (s *PGVector) Upsert(ctx context.Context, docs ...Document) error {
+	query := fmt.Sprintf(`
+		INSERT INTO %s (id, content, embedding, doc_id, start_offset, end_offset)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (id) DO UPDATE SET content = $2, embedding = $3, doc_id = $4, start_offset = $5, end_offset = $6`,
+		s.table())
+	var batch pgx.Batch
+	for _, d := range docs {
+		if err := s.check(fmt.Sprintf("document %q", d.ID), d.Vector); err != nil {
+			return err
+		}
+		batch.Queue(query, d.ID, d.Content, pgvector.NewVector(d.Vector), d.DocID, d.Start, d.End)
+	}
+	return s.DB.SendBatch(ctx, &batch).Close()
+}
+
+// Delete 는 VectorStore 를 구현한다.
+func (s *PGVector) Delete(ctx context.Context, ids ...string) error {
+	_, err := s.DB.Exec(ctx, fmt.Sprintf("DELETE FROM %s WHERE id = ANY($1)", s.table()), ids)
+	return err
+}
+
+// Prune 은 keep 의 문서(doc_id)마다 조각 ID 가 keep[doc_id] 에 없는 행을 지운다.
+func (s *PGVector) Prune(ctx context.Context, keep map[string][]string) error {
+	query := fmt.Sprintf("DELETE FROM %s WHERE doc_id = $1 AND NOT (id = ANY($2))", s.table())
+	var batch pgx.Batch
+	for docID, ids := range keep {
+		batch.Queue(query, docID, ids)
+	}
+	return s.DB.SendBatch(ctx, &batch).Close()
+}
+
+// Count 는 VectorStore 를 구현한다.
+func (s *PGVector) Count(ctx context.Context) (int, error) {
+	var n int
+	err := s.DB.QueryRow(ctx, fmt.Sprintf("SELECT count(*) FROM %s", s.table())).Scan(&n)
+	return n, err
+}
+
+// Search 는 VectorStore 를 구현한다. 유사도가 같으면 ID 순서를 따른다.
+func (s *PGVector) Search(ctx context.Context, query []float32, opts SearchOptions) ([]Result, error) {
+	if err := s.check("query", query); err != nil {
+		return nil, err
+	}
+	sql, args := searchQuery(s.table(), pgvector.NewVector(query), opts)
+	rows, err := s.DB.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var results []Result
+	for rows.Next() {
+		var (
+			r          Result
+			docID      *string
+			start, end *int
+		)
+		// 조각 단위 저장 이전의 행은 doc_id 와 오프셋이 비어 있다
+		if err := rows.Scan(&r.ID, &r.Content, &docID, &start, &end, &r.Score); err != nil {
+			return nil, err
+		}
+		if docID != nil {
+			r.DocID = *docID
+		}
+		if start != nil && end != nil {
+			r.Start, r.End = *start, *end
+		}
+		results = append(results, r)
+	}
+	return results, rows.Err()
+}
+
+// searchQuery 는 Search 의 SQL 과 인자를 만든다.
+// <=> 는 코사인 거리이므로 1 - 거리가 코사인 유사도다.
+func searchQuery(table string, query any, opts SearchOptions) (string, []any) {
+	args := []any{query}
+	var where []string
+	if opts.MinScore != nil {
+		args = append(args, *opts.MinScore)
+		where = append(where, fmt.Sprintf("1 - (embedding <=> $1) >= $%d", len(args)))
+	}
+	if len(opts.DocIDs) > 0 {
+		args = append(args, opts.DocIDs)
+		where = append(where, fmt.Sprintf("doc_id = ANY($%d)", len(args)))
+	}
+	cond := ""
+	if len(where) > 0 {
+		cond = "WHERE " + strings.Join(where, " AND ")
+	}
+	args = append(args, opts.k())
+	return fmt.Sprintf(`
+		SELECT id, content, doc_id, start_offset, end_offset, 1 - (embedding <=> $1) AS score
+		FROM %s
+		%s
+		ORDER BY embedding <=> $1, id
+		LIMIT $%d`, table, cond, len(args)), args
+}
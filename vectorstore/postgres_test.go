@@ -0,0 +1,37 @@
+package vectorstore
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// 데이터베이스 없이 SQL 조립만 확인한다.
+func TestSearchQuery(t *testing.T) {
+	tests := []struct {
+		name      string
+		opts      SearchOptions
+		wantWhere string
+		wantArgs  []any
+	}{
+		{"defaults", SearchOptions{}, "FROM \"documents\"\n\t\t\n\t\tORDER BY", []any{"q", DefaultK}},
+		{"doc filter only", SearchOptions{DocIDs: []string{"a.md"}}, "WHERE doc_id = ANY($2)\n", []any{"q", []string{"a.md"}, DefaultK}},
+		{"threshold and doc filter", SearchOptions{K: 5, MinScore: Threshold(0.5), DocIDs: []string{"a.md"}},
+			"WHERE 1 - (embedding <=> $1) >= $2 AND doc_id = ANY($3)\n",
+			[]any{"q", float32(0.5), []string{"a.md"}, 5}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sql, args := searchQuery(`"documents"`, "q", tt.opts)
+			if !strings.Contains(sql, tt.wantWhere) {
+				t.Errorf("SQL = %s, want %q", sql, tt.wantWhere)
+			}
+			if want := "LIMIT $" + string(rune('0'+len(tt.wantArgs))); !strings.HasSuffix(sql, want) {
+				t.Errorf("SQL = %s, want suffix %q", sql, want)
+			}
+			if !reflect.DeepEqual(args, tt.wantArgs) {
+				t.Errorf("args = %v, want %v", args, tt.wantArgs)
+			}
+		})
+	}
+}
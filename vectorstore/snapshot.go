@@ -0,0 +1,254 @@
+package vectorstore
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+)
+
+// 스냅숏 파일 형식:
+//
+//	"VSNP" 버전(uint16) 모델(문자열) 차원(uvarint) 문서수(uvarint)
+//	문서마다: ID 내용 DocID(문자열) Start End(uvarint) 정규화한 벡터(float32 × 차원)
+//	CRC-32C(uint32, 앞의 모든 바이트)
+//
+// 문자열은 uvarint 길이 뒤에 UTF-8 바이트, 정수와 실수는 리틀 엔디언이다.
+const (
+	snapshotMagic   = "VSNP"
+	snapshotVersion = 1
+)
+
+// ErrCorruptSnapshot 은 스냅숏이 잘렸거나 체크섬이 맞지 않는 등 읽을 수 없을 때의 오류.
+var ErrCorruptSnapshot = errors.New("vectorstore: corrupt snapshot")
+
+// SnapshotInfo 는 스냅숏 머리에 적힌 정보.
+// 불러온 쪽은 Model 과 Dimensionality 가 지금 임베딩 설정과 같은지 확인해야 한다.
+type SnapshotInfo struct {
+	Model          string
+	Dimensionality int
+	Count          int
+}
+
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+// Save 는 저장된 문서 전부를 model 이름과 함께 w 에 스냅숏으로 쓴다.
+func (m *Memory) Save(w io.Writer, model string) error {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	crc := crc32.New(castagnoli)
+	bw := bufio.NewWriter(io.MultiWriter(w, crc))
+	sw := &snapshotWriter{w: bw}
+	sw.bytes([]byte(snapshotMagic))
+	sw.bytes(binary.LittleEndian.AppendUint16(nil, snapshotVersion))
+	sw.string(model)
+	sw.uvarint(uint64(m.dim))
+	sw.uvarint(uint64(len(m.docs)))
+	for i := range m.docs {
+		d := m.document(i)
+		sw.string(d.ID)
+		sw.string(d.Content)
+		sw.string(d.DocID)
+		sw.uvarint(uint64(d.Start))
+		sw.uvarint(uint64(d.End))
+		for _, x := range d.Vector {
+			sw.bytes(binary.LittleEndian.AppendUint32(nil, math.Float32bits(x)))
+		}
+	}
+	if sw.err != nil {
+		return sw.err
+	}
+	if err := bw.Flush(); err != nil {
+		return err
+	}
+	_, err := w.Write(binary.LittleEndian.AppendUint32(nil, crc.Sum32()))
+	return err
+}
+
+// SaveFile 은 Save 로 path 에 쓴다. 임시 파일에 쓴 뒤 이름을 바꾸므로 쓰다가 멈춰도 이전 스냅숏이 남는다.
+func (m *Memory) SaveFile(path, model string) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".snapshot-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if err := m.Save(tmp, model); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// Load 는 Save 로 쓴 스냅숏을 읽어 기본 설정의 새 Memory 를 만든다.
+func Load(r io.Reader) (*Memory, SnapshotInfo, error) {
+	return LoadWith(r, MemoryConfig{})
+}
+
+// LoadWith 는 Save 로 쓴 스냅숏을 읽어 cfg 설정의 새 Memory 를 만든다.
+// cfg.Quantize 면 읽은 벡터를 int8 로 바꿔 둔다.
+func LoadWith(r io.Reader, cfg MemoryConfig) (*Memory, SnapshotInfo, error) {
+	crc := crc32.New(castagnoli)
+	sr := &snapshotReader{r: bufio.NewReader(r), crc: crc}
+
+	var info SnapshotInfo
+	if magic := sr.bytes(len(snapshotMagic)); sr.err == nil && string(magic) != snapshotMagic {
+		return nil, info, fmt.Errorf("%w: not a snapshot", ErrCorruptSnapshot)
+	}
+	if v := sr.bytes(2); sr.err == nil && binary.LittleEndian.Uint16(v) != snapshotVersion {
+		return nil, info, fmt.Errorf("%w: unsupported version %d", ErrCorruptSnapshot, binary.LittleEndian.Uint16(v))
+	}
+	info.Model = sr.string()
+	info.Dimensionality = int(sr.uvarint())
+	info.Count = int(sr.uvarint())
+	if sr.err != nil {
+		return nil, info, sr.error()
+	}
+	if info.Dimensionality > maxSnapshotDim {
+		return nil, info, fmt.Errorf("%w: %d dimensions", ErrCorruptSnapshot, info.Dimensionality)
+	}
+
+	m := NewMemoryWith(cfg)
+	m.dim = info.Dimensionality
+	for range info.Count {
+		d := Document{
+			ID:      sr.string(),
+			Content: sr.string(),
+			DocID:   sr.string(),
+			Start:   int(sr.uvarint()),
+			End:     int(sr.uvarint()),
+		}
+		raw := sr.bytes(4 * info.Dimensionality)
+		if sr.err != nil {
+			return nil, info, sr.error()
+		}
+		d.Vector = make([]float32, info.Dimensionality)
+		for j := range d.Vector {
+			d.Vector[j] = math.Float32frombits(binary.LittleEndian.Uint32(raw[4*j:]))
+		}
+		if _, ok := m.byID[d.ID]; ok {
+			return nil, info, fmt.Errorf("%w: duplicate id %q", ErrCorruptSnapshot, d.ID)
+		}
+		if err := m.add(d); err != nil {
+			return nil, info, fmt.Errorf("%w: %w", ErrCorruptSnapshot, err)
+		}
+	}
+
+	want := crc.Sum32()
+	sum := make([]byte, 4)
+	if _, err := io.ReadFull(sr.r, sum); err != nil {
+		return nil, info, fmt.Errorf("%w: missing checksum", ErrCorruptSnapshot)
+	}
+	if got := binary.LittleEndian.Uint32(sum); got != want {
+		return nil, info, fmt.Errorf("%w: checksum %08x, want %08x", ErrCorruptSnapshot, got, want)
+	}
+	return m, info, nil
+}
+
+// LoadFile 은 path 의 스냅숏을 Load 로 읽는다.
+func LoadFile(path string) (*Memory, SnapshotInfo, error) {
+	return LoadFileWith(path, MemoryConfig{})
+}
+
+// LoadFileWith 는 path 의 스냅숏을 LoadWith 로 읽는다.
+func LoadFileWith(path string, cfg MemoryConfig) (*Memory, SnapshotInfo, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, SnapshotInfo{}, err
+	}
+	defer f.Close()
+	return LoadWith(f, cfg)
+}
+
+// snapshotWriter 는 첫 오류를 기억하고 이후 쓰기를 무시한다.
+type snapshotWriter struct {
+	w   *bufio.Writer
+	err error
+}
+
+func (w *snapshotWriter) bytes(b []byte) {
+	if w.err == nil {
+		_, w.err = w.w.Write(b)
+	}
+}
+
+func (w *snapshotWriter) uvarint(v uint64) {
+	w.bytes(binary.AppendUvarint(nil, v))
+}
+
+func (w *snapshotWriter) string(s string) {
+	w.uvarint(uint64(len(s)))
+	w.bytes([]byte(s))
+}
+
+// snapshotReader 는 읽은 바이트를 crc 에 더하고, 첫 오류를 기억한다.
+type snapshotReader struct {
+	r   *bufio.Reader
+	crc hash.Hash32
+	err error
+}
+
+// 문자열 길이와 차원의 상한. 깨진 머리의 큰 수로 메모리를 잡지 않기 위한 것이다.
+const (
+	maxSnapshotString = 1 << 28
+	maxSnapshotDim    = 1 << 16
+)
+
+func (r *snapshotReader) bytes(n int) []byte {
+	if r.err != nil {
+		return nil
+	}
+	b := make([]byte, n)
+	if _, r.err = io.ReadFull(r.r, b); r.err != nil {
+		return nil
+	}
+	r.crc.Write(b)
+	return b
+}
+
+func (r *snapshotReader) uvarint() uint64 {
+	if r.err != nil {
+		return 0
+	}
+	var buf [binary.MaxVarintLen64]byte
+	for i := range buf {
+		b, err := r.r.ReadByte()
+		if err != nil {
+			r.err = err
+			return 0
+		}
+		buf[i] = b
+		if b < 0x80 {
+			r.crc.Write(buf[:i+1])
+			v, _ := binary.Uvarint(buf[:i+1])
+			return v
+		}
+	}
+	r.err = errors.New("varint overflow")
+	return 0
+}
+
+func (r *snapshotReader) string() string {
+	n := r.uvarint()
+	if r.err == nil && n > maxSnapshotString {
+		r.err = fmt.Errorf("string length %d", n)
+	}
+	return string(r.bytes(int(n)))
+}
+
+// error 는 읽기 오류를 ErrCorruptSnapshot 으로 감싼다.
+func (r *snapshotReader) error() error {
+	if errors.Is(r.err, io.EOF) || errors.Is(r.err, io.ErrUnexpectedEOF) {
+		return fmt.Errorf("%w: truncated", ErrCorruptSnapshot)
+	}
+	return fmt.Errorf("%w: %v", ErrCorruptSnapshot, r.err)
+}
@@ -0,0 +1,150 @@
+package vectorstore
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestSnapshot(t *testing.T) {
+	ctx := context.Background()
+	m := NewMemory()
+	docs := []Document{
+		{ID: "a.md#0", Content: "첫 조각", Vector: []float32{1, 0, -0.5}, DocID: "a.md", Start: 0, End: 10},
+		{ID: "a.md#1", Content: "둘째 조각", Vector: []float32{0.25, 1, 0}, DocID: "a.md", Start: 8, End: 20},
+		{ID: "b", Content: "", Vector: []float32{0, 0, 1}},
+	}
+	if err := m.Upsert(ctx, docs...); err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(t.TempDir(), "index.vsnap")
+	if err := m.SaveFile(path, "text-multilingual-embedding-002"); err != nil {
+		t.Fatal(err)
+	}
+	loaded, info, err := LoadFile(path)
+	if err != nil {
+		t.Fatalf("LoadFile() error = %v", err)
+	}
+	if want := (SnapshotInfo{Model: "text-multilingual-embedding-002", Dimensionality: 3, Count: 3}); info != want {
+		t.Errorf("info = %+v, want %+v", info, want)
+	}
+	// 저장소는 정규화한 벡터만 두므로 그것이 저장되고 돌아온다
+	for _, d := range docs {
+		d.Vector = normalize(d.Vector)
+		got, ok := loaded.Get(d.ID)
+		if !ok || !reflect.DeepEqual(got, d) {
+			t.Errorf("Get(%q) = %+v, %v, want %+v", d.ID, got, ok, d)
+		}
+	}
+	// 불러온 저장소도 같은 결과를 낸다
+	want, _ := m.Search(ctx, []float32{1, 1, 0}, SearchOptions{K: 3})
+	got, err := loaded.Search(ctx, []float32{1, 1, 0}, SearchOptions{K: 3})
+	if err != nil || !reflect.DeepEqual(got, want) {
+		t.Errorf("Search() after load = %v, %v, want %v", got, err, want)
+	}
+	if err := loaded.Upsert(ctx, Document{ID: "c", Vector: []float32{1, 2}}); !errors.Is(err, ErrDimensionMismatch) {
+		t.Errorf("Upsert() with wrong dimension after load error = %v", err)
+	}
+}
+
+func TestSnapshotEmpty(t *testing.T) {
+	var buf bytes.Buffer
+	if err := NewMemory().Save(&buf, "m"); err != nil {
+		t.Fatal(err)
+	}
+	m, info, err := Load(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Len() != 0 || info.Count != 0 || info.Dimensionality != 0 {
+		t.Errorf("Load() = %d docs, %+v", m.Len(), info)
+	}
+}
+
+func TestSnapshotLoadWith(t *testing.T) {
+	ctx := context.Background()
+	m := NewMemory()
+	if err := m.Upsert(ctx, Document{ID: "a", Vector: []float32{1, 0}}, Document{ID: "b", Vector: []float32{0.6, 0.8}}); err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := m.Save(&buf, "m"); err != nil {
+		t.Fatal(err)
+	}
+	loaded, _, err := LoadWith(&buf, MemoryConfig{Workers: 1, Quantize: true})
+	if err != nil {
+		t.Fatalf("LoadWith() error = %v", err)
+	}
+	if loaded.cfg.Workers != 1 || loaded.qvecs == nil || loaded.vecs != nil {
+		t.Errorf("LoadWith() store config = %+v, want quantized with 1 worker", loaded.cfg)
+	}
+	got, err := loaded.Search(ctx, []float32{1, 0}, SearchOptions{K: 2})
+	if err != nil || len(got) != 2 || got[0].ID != "a" {
+		t.Errorf("Search() after LoadWith = %v, %v", got, err)
+	}
+}
+
+func TestSnapshotCorrupt(t *testing.T) {
+	m := NewMemory()
+	if err := m.Upsert(context.Background(), Document{ID: "a", Content: "내용", Vector: []float32{1, 2, 3}}); err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := m.Save(&buf, "m"); err != nil {
+		t.Fatal(err)
+	}
+	good := buf.Bytes()
+
+	flipped := bytes.Clone(good)
+	flipped[len(flipped)-8] ^= 0x01 // 벡터의 한 비트
+
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"bit flip", flipped},
+		{"truncated", good[:len(good)-6]},
+		{"no checksum", good[:len(good)-4]},
+		{"not a snapshot", []byte("hello world")},
+		{"empty", nil},
+		{"zero dimensions", noDimSnapshot()},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, _, err := Load(bytes.NewReader(tt.data)); !errors.Is(err, ErrCorruptSnapshot) {
+				t.Errorf("Load() error = %v, want ErrCorruptSnapshot", err)
+			}
+		})
+	}
+
+	if _, _, err := LoadFile(filepath.Join(t.TempDir(), "missing")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("LoadFile(missing) error = %v, want ErrNotExist", err)
+	}
+}
+
+// noDimSnapshot 은 체크섬은 맞지만 0 차원 벡터의 문서를 담은 스냅숏. Memory 에 넣을 수 없다.
+func noDimSnapshot() []byte {
+	var buf bytes.Buffer
+	bw := bufio.NewWriter(&buf)
+	sw := &snapshotWriter{w: bw}
+	sw.bytes([]byte(snapshotMagic))
+	sw.bytes(binary.LittleEndian.AppendUint16(nil, snapshotVersion))
+	sw.string("m")
+	sw.uvarint(0) // 차원
+	sw.uvarint(1) // 문서 수
+	sw.string("a")
+	sw.string("")
+	sw.string("")
+	sw.uvarint(0)
+	sw.uvarint(0)
+	bw.Flush()
+	return binary.LittleEndian.AppendUint32(buf.Bytes(), crc32.Checksum(buf.Bytes(), castagnoli))
+}
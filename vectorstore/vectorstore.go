@@ -0,0 +1,104 @@
+// Package vectorstore 는 임베딩된 문서를 저장하고 질의 벡터와 가까운 문서를 찾는다.
+//
+// 저장소 구현은 모두 VectorStore 를 따르므로, 메모리(Memory, HNSW)와 PostgreSQL(PGVector)
+// 중 어디에 둘지는 설정으로 고를 수 있다.
+package vectorstore
+
+import (
+	"context"
+	"errors"
+	"math"
+	"slices"
+)
+
+// DefaultK 는 SearchOptions.K 가 0 일 때 돌려줄 결과 수.
+const DefaultK = 3
+
+// ErrDimensionMismatch 는 벡터 차원이 저장소의 차원과 다르거나 벡터가 비었을 때의 오류.
+var ErrDimensionMismatch = errors.New("vectorstore: dimension mismatch")
+
+// VectorStore 는 문서 벡터 저장소. 구현은 여러 고루틴에서 함께 써도 된다.
+type VectorStore interface {
+	// Upsert 는 문서들을 넣는다. 같은 ID 가 있으면 바꾼다.
+	Upsert(ctx context.Context, docs ...Document) error
+	// Delete 는 ids 문서들을 지운다. 없는 ID 는 무시한다.
+	Delete(ctx context.Context, ids ...string) error
+	// Search 는 query 와 가까운 문서를 유사도 내림차순으로 최대 K 개 돌려준다.
+	Search(ctx context.Context, query []float32, opts SearchOptions) ([]Result, error)
+	// Count 는 저장된 문서 수.
+	Count(ctx context.Context) (int, error)
+}
+
+var (
+	_ VectorStore = (*Memory)(nil)
+	_ VectorStore = (*HNSW)(nil)
+	_ VectorStore = (*PGVector)(nil)
+)
+
+// Document 는 저장할 문서 하나.
+type Document struct {
+	ID      string
+	Content string
+	Vector  []float32
+	// 문서 조각이면 DocID 는 원래 문서의 ID, Start 와 End 는 원래 문서 안의 바이트 위치.
+	DocID      string
+	Start, End int
+}
+
+// Result 는 검색 결과 하나. Score 는 코사인 유사도로, 클수록 가깝다.
+type Result struct {
+	ID      string
+	Content string
+	Score   float32
+	// DocID 는 조각이 나온 원래 문서의 ID, Start 와 End 는 원래 문서 안의 바이트 위치 (출처 표시용).
+	DocID      string
+	Start, End int
+}
+
+// SearchOptions 는 검색 조건.
+type SearchOptions struct {
+	// K 는 돌려줄 최대 결과 수. 0 이면 DefaultK.
+	K int
+	// MinScore 가 nil 이 아니면 유사도가 *MinScore 보다 낮은 문서는 결과에서 뺀다 (Threshold 로 만든다).
+	// 관련 문서가 하나도 없으면 빈 결과가 되며, 호출자는 이를 "답할 근거 없음"으로 다뤄야 한다.
+	// nil 이면 거르지 않으므로 유사도가 음수인 문서도 상위 K 개에 들면 돌려준다.
+	MinScore *float32
+	// DocIDs 가 비어 있지 않으면 이 문서들에서 나온 조각만 찾는다.
+	DocIDs []string
+}
+
+// stale 은 Prune 으로 지울 조각인지: 원래 문서 docID 가 keep 에 있는데 조각 id 는 없다.
+func stale(keep map[string][]string, docID, id string) bool {
+	ids, ok := keep[docID]
+	return ok && !slices.Contains(ids, id)
+}
+
+// Threshold 는 SearchOptions.MinScore 에 넣을 유사도 하한.
+func Threshold(score float32) *float32 {
+	return &score
+}
+
+// minScore 는 거르는 유사도 하한. MinScore 가 nil 이면 -Inf 라 아무것도 거르지 않는다.
+func (o SearchOptions) minScore() float32 {
+	if o.MinScore == nil {
+		return float32(math.Inf(-1))
+	}
+	return *o.MinScore
+}
+
+func (o SearchOptions) k() int {
+	if o.K <= 0 {
+		return DefaultK
+	}
+	return o.K
+}
+
+// match 는 d 가 DocIDs 조건에 맞는지.
+func (o SearchOptions) match(d Document) bool {
+	return len(o.DocIDs) == 0 || slices.Contains(o.DocIDs, d.DocID)
+}
+
+// result 는 d 의 검색 결과.
+func result(d Document, score float32) Result {
+	return Result{ID: d.ID, Content: d.Content, Score: score, DocID: d.DocID, Start: d.Start, End: d.End}
+}
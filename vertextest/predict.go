@@ -0,0 +1,114 @@
+package vertextest
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/png"
+	"math"
+	"math/rand/v2"
+	"strings"
+
+	aiplatformv1pb "cloud.google.com/go/aiplatform/apiv1/aiplatformpb"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// DefaultDimensionality 는 parameters.outputDimensionality 가 없을 때의 임베딩 차원.
+const DefaultDimensionality = 768
+
+// DefaultPredict 는 텍스트 임베딩 모델과 Imagen 모델을 흉내 낸다.
+//
+// {"content": ...} 인스턴스에는 text-multilingual-embedding-002 와 같은 모양의
+// {"embeddings": {"values": [...], "statistics": {"token_count": n, "truncated": false}}} 를,
+// {"prompt": ...} 인스턴스에는 {"bytesBase64Encoded": ..., "mimeType": "image/png"} 를 돌려준다.
+// outputDimensionality 가 양수가 아니면 InvalidArgument 로 실패한다.
+func DefaultPredict(req *aiplatformv1pb.PredictRequest) (*aiplatformv1pb.PredictResponse, error) {
+	params := req.GetParameters().GetStructValue().GetFields()
+	dim := DefaultDimensionality
+	if v, ok := params["outputDimensionality"]; ok {
+		dim = int(v.GetNumberValue())
+		if dim <= 0 {
+			return nil, status.Errorf(codes.InvalidArgument, "vertextest: outputDimensionality must be positive, got %v", v.GetNumberValue())
+		}
+	}
+	samples := 1
+	if v, ok := params["sampleCount"]; ok {
+		samples = int(v.GetNumberValue())
+	}
+
+	resp := &aiplatformv1pb.PredictResponse{DeployedModelId: "vertextest"}
+	for i, inst := range req.GetInstances() {
+		fields := inst.GetStructValue().GetFields()
+		switch {
+		case fields["content"] != nil:
+			text := fields["content"].GetStringValue()
+			resp.Predictions = append(resp.Predictions,
+				EmbeddingPrediction(FakeEmbedding(text, dim), len(strings.Fields(text)), false))
+		case fields["prompt"] != nil:
+			for j := 0; j < samples; j++ {
+				resp.Predictions = append(resp.Predictions, ImagePrediction(fakePNG))
+			}
+		default:
+			return nil, status.Errorf(codes.InvalidArgument, "vertextest: instance %d has neither content nor prompt", i)
+		}
+	}
+	return resp, nil
+}
+
+// EmbeddingPrediction 은 텍스트 임베딩 모델의 prediction 하나를 만든다.
+func EmbeddingPrediction(values []float32, tokenCount int, truncated bool) *structpb.Value {
+	list := make([]*structpb.Value, len(values))
+	for i, v := range values {
+		list[i] = structpb.NewNumberValue(float64(v))
+	}
+	return structpb.NewStructValue(&structpb.Struct{Fields: map[string]*structpb.Value{
+		"embeddings": structpb.NewStructValue(&structpb.Struct{Fields: map[string]*structpb.Value{
+			"values": structpb.NewListValue(&structpb.ListValue{Values: list}),
+			"statistics": structpb.NewStructValue(&structpb.Struct{Fields: map[string]*structpb.Value{
+				"token_count": structpb.NewNumberValue(float64(tokenCount)),
+				"truncated":   structpb.NewBoolValue(truncated),
+			}}),
+		}}),
+	}})
+}
+
+// ImagePrediction 은 Imagen 모델의 prediction 하나를 만든다.
+func ImagePrediction(pngData []byte) *structpb.Value {
+	return structpb.NewStructValue(&structpb.Struct{Fields: map[string]*structpb.Value{
+		"bytesBase64Encoded": structpb.NewStringValue(base64.StdEncoding.EncodeToString(pngData)),
+		"mimeType":           structpb.NewStringValue("image/png"),
+	}})
+}
+
+// FakeEmbedding 은 text 로부터 결정되는 길이 1 의 dim 차원 벡터.
+// 같은 텍스트는 항상 같은 벡터가 되지만 의미적 유사도는 반영하지 않는다.
+func FakeEmbedding(text string, dim int) []float32 {
+	sum := sha256.Sum256([]byte(text))
+	rng := rand.New(rand.NewPCG(binary.LittleEndian.Uint64(sum[:8]), binary.LittleEndian.Uint64(sum[8:16])))
+	v := make([]float32, dim)
+	var norm float64
+	for i := range v {
+		x := rng.NormFloat64()
+		v[i] = float32(x)
+		norm += x * x
+	}
+	norm = math.Sqrt(norm)
+	for i := range v {
+		v[i] = float32(float64(v[i]) / norm)
+	}
+	return v
+}
+
+// fakePNG 는 Imagen 응답에 쓰는 1x1 PNG.
+var fakePNG = func() []byte {
+	img := image.NewRGBA(image.Rect(0, 0, 1, 1))
+	img.Set(0, 0, color.RGBA{R: 0x1e, G: 0x3a, B: 0x8a, A: 0xff})
+	var buf bytes.Buffer
+	png.Encode(&buf, img)
+	return buf.Bytes()
+}()
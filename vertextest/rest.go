@@ -0,0 +1,155 @@
+package vertextest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	aiplatformv1pb "cloud.google.com/go/aiplatform/apiv1/aiplatformpb"
+	pb "cloud.google.com/go/aiplatform/apiv1beta1/aiplatformpb"
+	"cloud.google.com/go/auth"
+	"google.golang.org/genai"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// GenAIConfig 는 google.golang.org/genai 클라이언트가 이 서버의 REST 엔드포인트를 쓰도록 하는 설정.
+func (s *Server) GenAIConfig(project, location string) *genai.ClientConfig {
+	return &genai.ClientConfig{
+		Backend:     genai.BackendVertexAI,
+		Project:     project,
+		Location:    location,
+		Credentials: auth.NewCredentials(&auth.CredentialsOptions{TokenProvider: staticToken{}}),
+		HTTPClient:  s.httpServer.Client(),
+		HTTPOptions: genai.HTTPOptions{BaseURL: s.URL},
+	}
+}
+
+type staticToken struct{}
+
+func (staticToken) Token(context.Context) (*auth.Token, error) {
+	return &auth.Token{Value: "vertextest", Type: "Bearer"}, nil
+}
+
+// restHandler 는 POST /{version}/{resource}:{method} 형식의 REST 요청을 처리한다.
+func (s *Server) restHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			writeStatus(w, status.Error(codes.Unimplemented, "vertextest: only POST is supported"))
+			return
+		}
+		_, rest, _ := strings.Cut(strings.TrimLeft(r.URL.Path, "/"), "/")
+		resource, method, ok := strings.Cut(rest, ":")
+		if !ok {
+			writeStatus(w, status.Errorf(codes.NotFound, "vertextest: no method in %q", r.URL.Path))
+			return
+		}
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			writeStatus(w, status.Errorf(codes.InvalidArgument, "vertextest: %v", err))
+			return
+		}
+
+		switch method {
+		case "generateContent", "streamGenerateContent":
+			var req pb.GenerateContentRequest
+			if err := unmarshalJSON(body, &req); err != nil {
+				writeStatus(w, err)
+				return
+			}
+			req.Model = resource
+			resp, err := s.handleGenerate(&req)
+			if err != nil {
+				writeStatus(w, err)
+				return
+			}
+			if method == "streamGenerateContent" {
+				data, _ := protojson.Marshal(resp)
+				w.Header().Set("Content-Type", "text/event-stream")
+				fmt.Fprintf(w, "data: %s\n\n", data)
+				return
+			}
+			writeProto(w, resp)
+		case "predict":
+			var req aiplatformv1pb.PredictRequest
+			if err := unmarshalJSON(body, &req); err != nil {
+				writeStatus(w, err)
+				return
+			}
+			req.Endpoint = resource
+			resp, err := s.handlePredict(&req)
+			if err != nil {
+				writeStatus(w, err)
+				return
+			}
+			writeProto(w, resp)
+		default:
+			writeStatus(w, status.Errorf(codes.Unimplemented, "vertextest: method %q not implemented", method))
+		}
+	})
+}
+
+func unmarshalJSON(body []byte, m proto.Message) error {
+	if len(body) == 0 {
+		return nil
+	}
+	if err := (protojson.UnmarshalOptions{DiscardUnknown: true}).Unmarshal(body, m); err != nil {
+		return status.Errorf(codes.InvalidArgument, "vertextest: invalid request body: %v", err)
+	}
+	return nil
+}
+
+func writeProto(w http.ResponseWriter, m proto.Message) {
+	data, err := protojson.Marshal(m)
+	if err != nil {
+		writeStatus(w, status.Errorf(codes.Internal, "vertextest: %v", err))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(data)
+}
+
+// httpStatus 는 gRPC 상태 코드에 대응하는 HTTP 상태 코드.
+var httpStatus = map[codes.Code]int{
+	codes.InvalidArgument:   http.StatusBadRequest,
+	codes.Unauthenticated:   http.StatusUnauthorized,
+	codes.PermissionDenied:  http.StatusForbidden,
+	codes.NotFound:          http.StatusNotFound,
+	codes.ResourceExhausted: http.StatusTooManyRequests,
+	codes.Unimplemented:     http.StatusNotImplemented,
+	codes.Unavailable:       http.StatusServiceUnavailable,
+	codes.DeadlineExceeded:  http.StatusGatewayTimeout,
+}
+
+// writeStatus 는 Google API 형식의 오류 본문을 쓴다.
+func writeStatus(w http.ResponseWriter, err error) {
+	st := status.Convert(err)
+	code, ok := httpStatus[st.Code()]
+	if !ok {
+		code = http.StatusInternalServerError
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(map[string]any{"error": map[string]any{
+		"code":    code,
+		"message": st.Message(),
+		"status":  strings.ToUpper(codeName(st.Code())),
+	}})
+}
+
+func codeName(c codes.Code) string {
+	// codes.Code 의 String 은 "InvalidArgument" 형식이므로 API 의 "INVALID_ARGUMENT" 형식으로 바꾼다
+	var b strings.Builder
+	for i, r := range c.String() {
+		if i > 0 && r >= 'A' && r <= 'Z' {
+			b.WriteByte('_')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
@@ -0,0 +1,300 @@
+// Package vertextest 는 Vertex AI 의 generateContent / predict 를 흉내 내는 프로세스 내 가짜 서버.
+//
+// gRPC(cloud.google.com/go/vertexai/genai, aiplatform.PredictionClient)와
+// REST(google.golang.org/genai) 두 가지 접근 방식을 모두 제공하며,
+// 미리 넣어 둔 응답을 순서대로 돌려주므로 자격 증명 없이 결정적인 테스트를 할 수 있다.
+//
+//	srv, err := vertextest.NewServer()
+//	defer srv.Close()
+//	srv.AddFunctionCalls(vertextest.Call{Name: "getProductSku", Args: map[string]any{"productName": "Pixel"}})
+//	srv.AddText("재고가 있습니다.")
+//	client, err := genai.NewClient(ctx, "test-project", "us-central1", srv.ClientOptions()...)
+package vertextest
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http/httptest"
+	"strings"
+	"sync"
+
+	aiplatformv1pb "cloud.google.com/go/aiplatform/apiv1/aiplatformpb"
+	pb "cloud.google.com/go/aiplatform/apiv1beta1/aiplatformpb"
+	"google.golang.org/api/option"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// GenerateFunc 는 스크립트된 응답이 없을 때 generateContent 요청에 응답한다.
+type GenerateFunc func(req *pb.GenerateContentRequest) (*pb.GenerateContentResponse, error)
+
+// PredictFunc 는 predict 요청에 응답한다. 기본값은 DefaultPredict.
+type PredictFunc func(req *aiplatformv1pb.PredictRequest) (*aiplatformv1pb.PredictResponse, error)
+
+// Server 는 가짜 Vertex AI 엔드포인트.
+type Server struct {
+	// Addr 는 gRPC 서버 주소 (host:port).
+	Addr string
+	// URL 은 REST 서버의 기본 URL.
+	URL string
+
+	mu       sync.Mutex
+	replies  []reply
+	generate GenerateFunc
+	predict  PredictFunc
+	genReqs  []*pb.GenerateContentRequest
+	predReqs []*aiplatformv1pb.PredictRequest
+
+	grpcServer *grpc.Server
+	httpServer *httptest.Server
+}
+
+type reply struct {
+	resp *pb.GenerateContentResponse
+	err  error
+}
+
+// NewServer 는 gRPC 와 REST 서버를 로컬 포트에서 시작한다. 다 쓰면 Close 해야 한다.
+func NewServer() (*Server, error) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, fmt.Errorf("vertextest: listen: %w", err)
+	}
+	s := &Server{
+		Addr:       lis.Addr().String(),
+		predict:    DefaultPredict,
+		grpcServer: grpc.NewServer(),
+	}
+	pb.RegisterPredictionServiceServer(s.grpcServer, &v1beta1Service{s: s})
+	aiplatformv1pb.RegisterPredictionServiceServer(s.grpcServer, &v1Service{s: s})
+	go s.grpcServer.Serve(lis)
+
+	s.httpServer = httptest.NewServer(s.restHandler())
+	s.URL = s.httpServer.URL
+	return s, nil
+}
+
+// Close 는 두 서버를 모두 종료한다.
+func (s *Server) Close() {
+	s.grpcServer.Stop()
+	s.httpServer.Close()
+}
+
+// ClientOptions 는 genai.NewClient 나 aiplatform.NewPredictionClient 가 이 서버에 인증 없이 접속하도록 하는 옵션.
+func (s *Server) ClientOptions() []option.ClientOption {
+	return []option.ClientOption{
+		option.WithEndpoint(s.Addr),
+		option.WithoutAuthentication(),
+		option.WithGRPCDialOption(grpc.WithTransportCredentials(insecure.NewCredentials())),
+	}
+}
+
+// AddReply 는 다음 generateContent 요청에 돌려줄 응답을 큐에 넣는다.
+func (s *Server) AddReply(resp *pb.GenerateContentResponse) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.replies = append(s.replies, reply{resp: resp})
+}
+
+// AddError 는 다음 generateContent 요청을 code 상태로 실패시킨다.
+func (s *Server) AddError(code codes.Code, msg string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.replies = append(s.replies, reply{err: status.Error(code, msg)})
+}
+
+// AddText 는 텍스트 파트 하나로 된 응답을 큐에 넣는다.
+func (s *Server) AddText(text string) {
+	s.AddReply(Candidate(pb.Candidate_STOP, TextPart(text)))
+}
+
+// Call 은 스크립트할 함수 호출 하나.
+type Call struct {
+	Name string
+	Args map[string]any
+}
+
+// AddFunctionCalls 는 calls 를 담은 응답을 큐에 넣는다.
+func (s *Server) AddFunctionCalls(calls ...Call) {
+	parts := make([]*pb.Part, len(calls))
+	for i, c := range calls {
+		parts[i] = FunctionCallPart(c.Name, c.Args)
+	}
+	s.AddReply(Candidate(pb.Candidate_STOP, parts...))
+}
+
+// HandleGenerate 는 큐가 비었을 때 쓸 응답 함수를 정한다.
+func (s *Server) HandleGenerate(fn GenerateFunc) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.generate = fn
+}
+
+// HandlePredict 는 predict 응답 함수를 바꾼다.
+func (s *Server) HandlePredict(fn PredictFunc) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.predict = fn
+}
+
+// GenerateRequests 는 지금까지 받은 generateContent 요청.
+func (s *Server) GenerateRequests() []*pb.GenerateContentRequest {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]*pb.GenerateContentRequest(nil), s.genReqs...)
+}
+
+// PredictRequests 는 지금까지 받은 predict 요청.
+func (s *Server) PredictRequests() []*aiplatformv1pb.PredictRequest {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]*aiplatformv1pb.PredictRequest(nil), s.predReqs...)
+}
+
+// Pending 은 아직 소비되지 않은 스크립트 응답 수.
+func (s *Server) Pending() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.replies)
+}
+
+func (s *Server) handleGenerate(req *pb.GenerateContentRequest) (*pb.GenerateContentResponse, error) {
+	if err := checkResourceName(req.GetModel(), "models"); err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	s.genReqs = append(s.genReqs, proto.Clone(req).(*pb.GenerateContentRequest))
+	if len(s.replies) > 0 {
+		r := s.replies[0]
+		s.replies = s.replies[1:]
+		s.mu.Unlock()
+		return r.resp, r.err
+	}
+	fn := s.generate
+	s.mu.Unlock()
+	if fn == nil {
+		return nil, status.Error(codes.Unavailable, "vertextest: no scripted response left")
+	}
+	return fn(req)
+}
+
+func (s *Server) handlePredict(req *aiplatformv1pb.PredictRequest) (*aiplatformv1pb.PredictResponse, error) {
+	if err := checkResourceName(req.GetEndpoint(), "models"); err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	s.predReqs = append(s.predReqs, proto.Clone(req).(*aiplatformv1pb.PredictRequest))
+	fn := s.predict
+	s.mu.Unlock()
+	return fn(req)
+}
+
+// checkResourceName 은 projects/{p}/locations/{l}/publishers/{pub}/models/{m} 형식을 검사한다.
+// 실제 API 처럼 프로젝트가 비어 있으면 InvalidArgument 로 실패한다.
+func checkResourceName(name, kind string) error {
+	seg := strings.Split(name, "/")
+	if len(seg) != 8 || seg[0] != "projects" || seg[2] != "locations" || seg[4] != "publishers" || seg[6] != kind {
+		return status.Errorf(codes.InvalidArgument, "vertextest: malformed resource name %q", name)
+	}
+	for _, i := range []int{1, 3, 5, 7} {
+		if seg[i] == "" {
+			return status.Errorf(codes.InvalidArgument, "vertextest: empty segment in resource name %q", name)
+		}
+	}
+	return nil
+}
+
+// v1beta1Service 는 vertexai/genai 가 쓰는 v1beta1 PredictionService.
+type v1beta1Service struct {
+	pb.UnimplementedPredictionServiceServer
+	s *Server
+}
+
+func (v *v1beta1Service) GenerateContent(ctx context.Context, req *pb.GenerateContentRequest) (*pb.GenerateContentResponse, error) {
+	return v.s.handleGenerate(req)
+}
+
+func (v *v1beta1Service) StreamGenerateContent(req *pb.GenerateContentRequest, stream pb.PredictionService_StreamGenerateContentServer) error {
+	resp, err := v.s.handleGenerate(req)
+	if err != nil {
+		return err
+	}
+	return stream.Send(resp)
+}
+
+func (v *v1beta1Service) CountTokens(ctx context.Context, req *pb.CountTokensRequest) (*pb.CountTokensResponse, error) {
+	var n int32
+	for _, c := range req.GetContents() {
+		for _, p := range c.GetParts() {
+			n += int32(len(strings.Fields(p.GetText())))
+		}
+	}
+	return &pb.CountTokensResponse{TotalTokens: n}, nil
+}
+
+func (v *v1beta1Service) Predict(ctx context.Context, req *pb.PredictRequest) (*pb.PredictResponse, error) {
+	// v1 과 v1beta1 의 PredictRequest 는 와이어 형식이 같으므로 변환해서 처리한다
+	var v1req aiplatformv1pb.PredictRequest
+	if err := convert(req, &v1req); err != nil {
+		return nil, err
+	}
+	v1resp, err := v.s.handlePredict(&v1req)
+	if err != nil {
+		return nil, err
+	}
+	var resp pb.PredictResponse
+	if err := convert(v1resp, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// v1Service 는 aiplatform/apiv1 PredictionClient 가 쓰는 v1 PredictionService.
+type v1Service struct {
+	aiplatformv1pb.UnimplementedPredictionServiceServer
+	s *Server
+}
+
+func (v *v1Service) Predict(ctx context.Context, req *aiplatformv1pb.PredictRequest) (*aiplatformv1pb.PredictResponse, error) {
+	return v.s.handlePredict(req)
+}
+
+func convert(from, to proto.Message) error {
+	data, err := proto.Marshal(from)
+	if err != nil {
+		return status.Errorf(codes.Internal, "vertextest: %v", err)
+	}
+	if err := proto.Unmarshal(data, to); err != nil {
+		return status.Errorf(codes.Internal, "vertextest: %v", err)
+	}
+	return nil
+}
+
+// Candidate 는 파트들로 된 후보 하나짜리 응답을 만든다.
+func Candidate(reason pb.Candidate_FinishReason, parts ...*pb.Part) *pb.GenerateContentResponse {
+	return &pb.GenerateContentResponse{
+		Candidates: []*pb.Candidate{{
+			Content:      &pb.Content{Role: "model", Parts: parts},
+			FinishReason: reason,
+		}},
+	}
+}
+
+// TextPart 는 텍스트 파트.
+func TextPart(text string) *pb.Part {
+	return &pb.Part{Data: &pb.Part_Text{Text: text}}
+}
+
+// FunctionCallPart 는 함수 호출 파트. args 는 structpb 로 변환 가능한 값이어야 한다.
+func FunctionCallPart(name string, args map[string]any) *pb.Part {
+	st, err := structpb.NewStruct(args)
+	if err != nil {
+		panic(fmt.Sprintf("vertextest: function call args: %v", err))
+	}
+	return &pb.Part{Data: &pb.Part_FunctionCall{FunctionCall: &pb.FunctionCall{Name: name, Args: st}}}
+}
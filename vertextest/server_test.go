@@ -0,0 +1,114 @@
+package vertextest
+
+import (
+	"context"
+	"testing"
+
+	aiplatform "cloud.google.com/go/aiplatform/apiv1"
+	"cloud.google.com/go/aiplatform/apiv1/aiplatformpb"
+	"google.golang.org/genai"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+func TestREST(t *testing.T) {
+	srv, err := NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Close()
+	ctx := context.Background()
+
+	client, err := genai.NewClient(ctx, srv.GenAIConfig("test-project", "us-central1"))
+	if err != nil {
+		t.Fatalf("genai.NewClient() error = %v", err)
+	}
+
+	srv.AddText("서울은 대한민국의 수도입니다.")
+	result, err := client.Models.GenerateContent(ctx, "gemini-2.0-flash", genai.Text("서울에 대해 알려줘"), nil)
+	if err != nil {
+		t.Fatalf("GenerateContent() error = %v", err)
+	}
+	if got := result.Text(); got != "서울은 대한민국의 수도입니다." {
+		t.Errorf("GenerateContent() text = %q", got)
+	}
+	if model := srv.GenerateRequests()[0].GetModel(); model != "projects/test-project/locations/us-central1/publishers/google/models/gemini-2.0-flash" {
+		t.Errorf("request model = %q", model)
+	}
+
+	images, err := client.Models.GenerateImages(ctx, "imagen-3.0-generate-002", "고래", &genai.GenerateImagesConfig{NumberOfImages: 2})
+	if err != nil {
+		t.Fatalf("GenerateImages() error = %v", err)
+	}
+	if len(images.GeneratedImages) != 2 || len(images.GeneratedImages[0].Image.ImageBytes) == 0 {
+		t.Errorf("GenerateImages() = %+v", images)
+	}
+
+	srv.AddError(codes.ResourceExhausted, "quota")
+	if _, err := client.Models.GenerateContent(ctx, "gemini-2.0-flash", genai.Text("again"), nil); err == nil {
+		t.Error("GenerateContent() with scripted error = nil")
+	}
+}
+
+func TestPredictEmbeddings(t *testing.T) {
+	srv, err := NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Close()
+	ctx := context.Background()
+
+	client, err := aiplatform.NewPredictionClient(ctx, srv.ClientOptions()...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	instance := func(text string) *structpb.Value {
+		return structpb.NewStructValue(&structpb.Struct{Fields: map[string]*structpb.Value{
+			"content": structpb.NewStringValue(text),
+		}})
+	}
+	resp, err := client.Predict(ctx, &aiplatformpb.PredictRequest{
+		Endpoint:  "projects/p/locations/us-central1/publishers/google/models/text-multilingual-embedding-002",
+		Instances: []*structpb.Value{instance("문서 하나"), instance("문서 하나")},
+		Parameters: structpb.NewStructValue(&structpb.Struct{Fields: map[string]*structpb.Value{
+			"outputDimensionality": structpb.NewNumberValue(8),
+		}}),
+	})
+	if err != nil {
+		t.Fatalf("Predict() error = %v", err)
+	}
+	if len(resp.Predictions) != 2 {
+		t.Fatalf("got %d predictions", len(resp.Predictions))
+	}
+	emb := resp.Predictions[0].GetStructValue().GetFields()["embeddings"].GetStructValue().GetFields()
+	values := emb["values"].GetListValue().GetValues()
+	if len(values) != 8 || emb["statistics"].GetStructValue().GetFields()["token_count"].GetNumberValue() != 2 {
+		t.Errorf("embedding prediction = %v", emb)
+	}
+	// 같은 텍스트는 같은 벡터
+	other := resp.Predictions[1].GetStructValue().GetFields()["embeddings"].GetStructValue().GetFields()["values"].GetListValue().GetValues()
+	if values[3].GetNumberValue() != other[3].GetNumberValue() {
+		t.Error("FakeEmbedding is not deterministic")
+	}
+
+	if _, err := client.Predict(ctx, &aiplatformpb.PredictRequest{Endpoint: "projects//locations/x/publishers/google/models/m"}); err == nil {
+		t.Error("Predict() with empty project error = nil")
+	}
+
+	// 0 차원은 NaN 벡터, 음수는 panic 이 되던 값
+	for _, dim := range []float64{0, -8} {
+		_, err := client.Predict(ctx, &aiplatformpb.PredictRequest{
+			Endpoint:  "projects/p/locations/us-central1/publishers/google/models/text-multilingual-embedding-002",
+			Instances: []*structpb.Value{instance("문서 하나")},
+			Parameters: structpb.NewStructValue(&structpb.Struct{Fields: map[string]*structpb.Value{
+				"outputDimensionality": structpb.NewNumberValue(dim),
+			}}),
+		})
+		if status.Code(err) != codes.InvalidArgument {
+			t.Errorf("Predict(outputDimensionality=%v) error = %v, want InvalidArgument", dim, err)
+		}
+	}
+}